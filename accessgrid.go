@@ -45,6 +45,12 @@ type (
 	// Device represents a device associated with an access pass
 	Device = models.Device
 
+	// DevicePlatform identifies the wallet platform a device belongs to
+	DevicePlatform = models.DevicePlatform
+
+	// DeviceType identifies the kind of device an access pass is installed on
+	DeviceType = models.DeviceType
+
 	// Card represents an NFC key or access pass
 	Card = models.Card
 
@@ -78,9 +84,24 @@ type (
 	// EventLogFilters defines parameters for filtering event logs
 	EventLogFilters = models.EventLogFilters
 
+	// AccountEventLogFilters defines parameters for filtering event logs across templates
+	AccountEventLogFilters = models.AccountEventLogFilters
+
 	// Event represents an event in the event log
 	Event = models.Event
 
+	// NormalizedEvent is an event log entry with consistent types
+	NormalizedEvent = models.NormalizedEvent
+
+	// EventID identifies an event regardless of how the API encoded it
+	EventID = models.EventID
+
+	// EventType is the kind of an event log entry
+	EventType = models.EventType
+
+	// EventDetails is the typed detail payload of an event
+	EventDetails = models.EventDetails
+
 	// Pagination represents pagination metadata in list responses
 	Pagination = models.Pagination
 
@@ -99,6 +120,9 @@ type (
 	// CreatePassTemplatePairParams defines parameters for creating a pass template pair
 	CreatePassTemplatePairParams = models.CreatePassTemplatePairParams
 
+	// UpdatePassTemplatePairParams defines parameters for updating a pass template pair
+	UpdatePassTemplatePairParams = models.UpdatePassTemplatePairParams
+
 	// LedgerItemPassTemplate represents a pass template reference within a ledger item's access pass
 	LedgerItemPassTemplate = models.LedgerItemPassTemplate
 
@@ -108,6 +132,9 @@ type (
 	// LedgerItem represents a billing ledger item
 	LedgerItem = models.LedgerItem
 
+	// Decimal is an exact monetary amount
+	Decimal = models.Decimal
+
 	// LedgerItemsResponse represents the response from listing ledger items
 	LedgerItemsResponse = models.LedgerItemsResponse
 
@@ -147,6 +174,24 @@ type (
 	// UpdateLandingPageParams defines parameters for updating a landing page
 	UpdateLandingPageParams = models.UpdateLandingPageParams
 
+	// KeyType identifies the cipher of a credential profile key
+	KeyType = models.KeyType
+
+	// SEOSKeyRole identifies a key of a SEOS key set
+	SEOSKeyRole = models.SEOSKeyRole
+
+	// AccessRights lists the key number required for each operation on a file
+	AccessRights = models.AccessRights
+
+	// CardStorage describes the storage a credential profile occupies on the card
+	CardStorage = models.CardStorage
+
+	// CredentialProfileKey describes a key slot of a credential profile
+	CredentialProfileKey = models.CredentialProfileKey
+
+	// CredentialProfileFile describes a file stored by a credential profile
+	CredentialProfileFile = models.CredentialProfileFile
+
 	// CredentialProfile represents a credential profile
 	CredentialProfile = models.CredentialProfile
 
@@ -155,4 +200,10 @@ type (
 
 	// CreateCredentialProfileParams defines parameters for creating a credential profile
 	CreateCredentialProfileParams = models.CreateCredentialProfileParams
+
+	// UpdateCredentialProfileParams defines parameters for updating a credential profile
+	UpdateCredentialProfileParams = models.UpdateCredentialProfileParams
+
+	// ValidationError lists every problem found when validating parameters
+	ValidationError = models.ValidationError
 )
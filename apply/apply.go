@@ -0,0 +1,239 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// Apply performs the changes of a plan in order and records every
+// successful change in the state. It stops at the first error; the state
+// then reflects the changes applied so far and should still be saved.
+func (r *Reconciler) Apply(ctx context.Context, plan *Plan, state *State) error {
+	// created holds the IDs of templates created by this run, which are not
+	// published yet
+	created := map[string]bool{}
+	for _, c := range plan.Changes {
+		if err := r.applyChange(ctx, c, state, created); err != nil {
+			return fmt.Errorf("error applying %s of %s %q: %w", c.Action, c.Kind, c.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) applyChange(ctx context.Context, c Change, state *State, created map[string]bool) error {
+	if c.Action == ActionAdopt {
+		state.Set(c.Kind, c.Name, StateEntry{ID: c.ID, Digest: c.digest})
+		return nil
+	}
+	if c.Action == ActionDelete {
+		if err := r.delete(ctx, c); err != nil && !isNotFound(err) {
+			return err
+		}
+		state.Remove(c.Kind, c.Name)
+		return nil
+	}
+
+	var id string
+	var err error
+	switch spec := c.spec.(type) {
+	case models.CreateCredentialProfileParams:
+		id, err = r.applyCredentialProfile(ctx, c, spec)
+	case models.CreateTemplateParams:
+		id, err = r.applyTemplate(ctx, c, spec)
+		if err == nil && c.Action == ActionCreate {
+			created[id] = true
+		}
+	case models.CreateLandingPageParams:
+		id, err = r.applyLandingPage(ctx, c, spec)
+	case models.CreateWebhookParams:
+		id, err = r.applyWebhook(ctx, c, spec)
+	case TemplatePairSpec:
+		id, err = r.applyTemplatePair(ctx, c, spec, state, created)
+	default:
+		err = fmt.Errorf("unknown resource spec %T", c.spec)
+	}
+	if err != nil {
+		return err
+	}
+
+	state.Set(c.Kind, c.Name, StateEntry{ID: id, Digest: c.digest})
+	return nil
+}
+
+func (r *Reconciler) delete(ctx context.Context, c Change) error {
+	switch c.Kind {
+	case KindTemplate:
+		return r.console.DeleteTemplate(ctx, c.ID)
+	case KindWebhook:
+		return r.console.Webhooks.Delete(ctx, c.ID)
+	case KindTemplatePair:
+		return r.console.DeletePassTemplatePair(ctx, c.ID)
+	case KindLandingPage:
+		return r.console.DeleteLandingPage(ctx, c.ID)
+	case KindCredentialProfile:
+		return r.console.CredentialProfiles.Delete(ctx, c.ID)
+	default:
+		return fmt.Errorf("unknown resource kind %s", c.Kind)
+	}
+}
+
+func (r *Reconciler) applyCredentialProfile(ctx context.Context, c Change, spec models.CreateCredentialProfileParams) (string, error) {
+	if c.Action == ActionCreate {
+		profile, err := r.console.CredentialProfiles.Create(ctx, spec)
+		if err != nil {
+			return "", err
+		}
+		return profile.ID, nil
+	}
+
+	_, err := r.console.CredentialProfiles.Update(ctx, models.UpdateCredentialProfileParams{
+		CredentialProfileID: c.ID,
+		Name:                spec.Name,
+		Keys:                spec.Keys,
+	})
+	if err != nil {
+		return "", err
+	}
+	return c.ID, nil
+}
+
+func (r *Reconciler) applyTemplate(ctx context.Context, c Change, spec models.CreateTemplateParams) (string, error) {
+	if c.Action == ActionCreate {
+		template, err := r.console.CreateTemplate(ctx, spec)
+		if err != nil {
+			return "", err
+		}
+		return template.ID, nil
+	}
+
+	allowMultiple := spec.AllowOnMultipleDevices
+	_, err := r.console.UpdateTemplate(ctx, models.UpdateTemplateParams{
+		CardTemplateID:         c.ID,
+		Name:                   spec.Name,
+		AllowOnMultipleDevices: &allowMultiple,
+		WatchCount:             spec.WatchCount,
+		IPhoneCount:            spec.IPhoneCount,
+		BackgroundColor:        spec.BackgroundColor,
+		LabelColor:             spec.LabelColor,
+		LabelSecondaryColor:    spec.LabelSecondaryColor,
+		SupportURL:             spec.SupportURL,
+		SupportPhoneNumber:     spec.SupportPhoneNumber,
+		SupportEmail:           spec.SupportEmail,
+		PrivacyPolicyURL:       spec.PrivacyPolicyURL,
+		TermsAndConditionsURL:  spec.TermsAndConditionsURL,
+		Metadata:               spec.Metadata,
+	})
+	if err != nil {
+		return "", err
+	}
+	return c.ID, nil
+}
+
+func (r *Reconciler) applyLandingPage(ctx context.Context, c Change, spec models.CreateLandingPageParams) (string, error) {
+	if c.Action == ActionCreate {
+		page, err := r.console.CreateLandingPage(ctx, spec)
+		if err != nil {
+			return "", err
+		}
+		return page.ID, nil
+	}
+
+	allowImmediateDownload := spec.AllowImmediateDownload
+	is2FAEnabled := spec.Is2FAEnabled
+	_, err := r.console.UpdateLandingPage(ctx, models.UpdateLandingPageParams{
+		LandingPageID:          c.ID,
+		Name:                   spec.Name,
+		AdditionalText:         spec.AdditionalText,
+		BgColor:                spec.BgColor,
+		AllowImmediateDownload: &allowImmediateDownload,
+		Password:               spec.Password,
+		Is2FAEnabled:           &is2FAEnabled,
+		Logo:                   spec.Logo,
+	})
+	if err != nil {
+		return "", err
+	}
+	return c.ID, nil
+}
+
+func (r *Reconciler) applyWebhook(ctx context.Context, c Change, spec models.CreateWebhookParams) (string, error) {
+	if c.Action == ActionReplace {
+		if err := r.console.Webhooks.Delete(ctx, c.ID); err != nil && !isNotFound(err) {
+			return "", err
+		}
+	}
+	webhook, err := r.console.Webhooks.Create(ctx, spec)
+	if err != nil {
+		return "", err
+	}
+	return webhook.ID, nil
+}
+
+// applyTemplatePair creates or updates a pair. The templates are checked
+// with PreflightPassTemplatePair when the pair's templates change, unless
+// one was created by this run: it cannot be published yet, and Spec.Validate
+// has already checked the platforms and protocols.
+func (r *Reconciler) applyTemplatePair(ctx context.Context, c Change, spec TemplatePairSpec, state *State, created map[string]bool) (string, error) {
+	apple, ok := state.Get(KindTemplate, spec.AppleTemplate)
+	if !ok {
+		return "", fmt.Errorf("template %q has not been applied", spec.AppleTemplate)
+	}
+	google, ok := state.Get(KindTemplate, spec.GoogleTemplate)
+	if !ok {
+		return "", fmt.Errorf("template %q has not been applied", spec.GoogleTemplate)
+	}
+	if pairTemplatesChanged(c) && !created[apple.ID] && !created[google.ID] {
+		if err := r.console.PreflightPassTemplatePair(ctx, apple.ID, google.ID); err != nil {
+			return "", err
+		}
+	}
+
+	if c.Action == ActionCreate {
+		pair, err := r.console.CreatePassTemplatePair(ctx, models.CreatePassTemplatePairParams{
+			Name:                 spec.Name,
+			AppleCardTemplateID:  apple.ID,
+			GoogleCardTemplateID: google.ID,
+		})
+		if err != nil {
+			return "", err
+		}
+		return pair.ID, nil
+	}
+
+	_, err := r.console.UpdatePassTemplatePair(ctx, models.UpdatePassTemplatePairParams{
+		PassTemplatePairID:   c.ID,
+		Name:                 spec.Name,
+		AppleCardTemplateID:  apple.ID,
+		GoogleCardTemplateID: google.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return c.ID, nil
+}
+
+// pairTemplatesChanged reports whether a pair change links templates the
+// pair does not already have
+func pairTemplatesChanged(c Change) bool {
+	if c.Action == ActionCreate {
+		return true
+	}
+	for _, d := range c.Diffs {
+		if d.Field == "apple_card_template_id" || d.Field == "google_card_template_id" {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotFound reports whether err is a 404 from the API, which a delete
+// treats as success
+func isNotFound(err error) bool {
+	var apiErr *client.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
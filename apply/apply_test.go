@@ -0,0 +1,432 @@
+package apply
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// fakeConsole is an in-memory console API
+type fakeConsole struct {
+	mu        sync.Mutex
+	nextID    int
+	templates map[string]map[string]interface{}
+	pages     map[string]map[string]interface{}
+	webhooks  map[string]map[string]interface{}
+	pairs     map[string]map[string]interface{}
+	requests  []string
+}
+
+func newFakeConsole() *fakeConsole {
+	return &fakeConsole{
+		templates: map[string]map[string]interface{}{},
+		pages:     map[string]map[string]interface{}{},
+		webhooks:  map[string]map[string]interface{}{},
+		pairs:     map[string]map[string]interface{}{},
+	}
+}
+
+func (f *fakeConsole) id(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s_%d", prefix, f.nextID)
+}
+
+func values(m map[string]map[string]interface{}) []map[string]interface{} {
+	out := []map[string]interface{}{}
+	for _, v := range m {
+		out = append(out, v)
+	}
+	return out
+}
+
+func (f *fakeConsole) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method != http.MethodGet {
+		f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	}
+
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	w.Header().Set("Content-Type", "application/json")
+	respond := func(v interface{}) { json.NewEncoder(w).Encode(v) }
+
+	path := r.URL.Path
+	switch {
+	case path == "/v1/console/credential-profiles":
+		respond([]interface{}{})
+	case path == "/v1/console/card-templates" && r.Method == http.MethodGet:
+		respond(values(f.templates))
+	case path == "/v1/console/card-templates" && r.Method == http.MethodPost:
+		body["id"] = f.id("tmpl")
+		f.templates[body["id"].(string)] = body
+		respond(body)
+	case strings.HasPrefix(path, "/v1/console/card-templates/"):
+		id := strings.TrimPrefix(path, "/v1/console/card-templates/")
+		t, ok := f.templates[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			respond(map[string]string{"message": "not found"})
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			for k, v := range body {
+				t[k] = v
+			}
+		case http.MethodDelete:
+			delete(f.templates, id)
+		}
+		respond(t)
+	case path == "/v1/console/landing-pages" && r.Method == http.MethodGet:
+		respond(values(f.pages))
+	case path == "/v1/console/landing-pages" && r.Method == http.MethodPost:
+		body["id"] = f.id("lp")
+		f.pages[body["id"].(string)] = body
+		respond(body)
+	case strings.HasPrefix(path, "/v1/console/landing-pages/"):
+		id := strings.TrimPrefix(path, "/v1/console/landing-pages/")
+		page := f.pages[id]
+		if r.Method == http.MethodDelete {
+			delete(f.pages, id)
+		}
+		respond(page)
+	case path == "/v1/console/webhooks" && r.Method == http.MethodGet:
+		respond(map[string]interface{}{"webhooks": values(f.webhooks)})
+	case path == "/v1/console/webhooks" && r.Method == http.MethodPost:
+		body["id"] = f.id("wh")
+		f.webhooks[body["id"].(string)] = body
+		respond(body)
+	case strings.HasPrefix(path, "/v1/console/webhooks/"):
+		delete(f.webhooks, strings.TrimPrefix(path, "/v1/console/webhooks/"))
+		respond(map[string]string{})
+	case path == "/v1/console/card-template-pairs" && r.Method == http.MethodGet:
+		respond(map[string]interface{}{
+			"card_template_pairs": values(f.pairs),
+			"pagination":          map[string]int{"current_page": 1, "total_pages": 1},
+		})
+	case path == "/v1/console/card-template-pairs" && r.Method == http.MethodPost:
+		pair := map[string]interface{}{
+			"id":               f.id("pair"),
+			"name":             body["name"],
+			"ios_template":     map[string]interface{}{"id": body["apple_card_template_id"]},
+			"android_template": map[string]interface{}{"id": body["google_card_template_id"]},
+		}
+		f.pairs[pair["id"].(string)] = pair
+		respond(pair)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		respond(map[string]string{"message": "unexpected request " + r.Method + " " + path})
+	}
+}
+
+func setupReconciler(t *testing.T) (*fakeConsole, *Reconciler) {
+	t.Helper()
+	fake := newFakeConsole()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+	return fake, NewReconciler(services.NewConsoleService(c))
+}
+
+func testSpec() *Spec {
+	return &Spec{
+		Templates: []models.CreateTemplateParams{
+			{Name: "Badge iOS", Platform: "apple", UseCase: "employee_badge", Protocol: "desfire", WatchCount: 1, IPhoneCount: 1},
+			{Name: "Badge Android", Platform: "android", UseCase: "employee_badge", Protocol: "desfire", WatchCount: 1, IPhoneCount: 1},
+		},
+		TemplatePairs: []TemplatePairSpec{
+			{Name: "Badge", AppleTemplate: "Badge iOS", GoogleTemplate: "Badge Android"},
+		},
+		LandingPages: []models.CreateLandingPageParams{
+			{Name: "Lobby", Kind: "universal"},
+		},
+		Webhooks: []models.CreateWebhookParams{
+			{Name: "Audit", URL: "https://example.com/hook", SubscribedEvents: []string{"ag.access_pass.issued"}},
+		},
+	}
+}
+
+func actions(plan *Plan) []string {
+	var out []string
+	for _, c := range plan.Changes {
+		out = append(out, fmt.Sprintf("%s %s %s", c.Action, c.Kind, c.Name))
+	}
+	return out
+}
+
+func TestReconciler_CreateThenNoChanges(t *testing.T) {
+	fake, reconciler := setupReconciler(t)
+	ctx := context.Background()
+	spec := testSpec()
+	state := NewState()
+
+	plan, err := reconciler.Plan(ctx, spec, state)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	want := []string{
+		"create template Badge iOS",
+		"create template Badge Android",
+		"create landing_page Lobby",
+		"create webhook Audit",
+		"create template_pair Badge",
+	}
+	if got := actions(plan); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Plan() = %v, want %v", got, want)
+	}
+
+	if err := reconciler.Apply(ctx, plan, state); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(fake.templates) != 2 || len(fake.pairs) != 1 || len(fake.webhooks) != 1 || len(fake.pages) != 1 {
+		t.Fatalf("unexpected resources after apply: %v", fake.requests)
+	}
+	pair, _ := state.Get(KindTemplatePair, "Badge")
+	ios, _ := state.Get(KindTemplate, "Badge iOS")
+	if fake.pairs[pair.ID]["ios_template"].(map[string]interface{})["id"] != ios.ID {
+		t.Errorf("pair was not linked to the applied iOS template")
+	}
+
+	plan, err = reconciler.Plan(ctx, spec, state)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(plan.Changes) != 0 {
+		t.Errorf("second Plan() = %v, want no changes", actions(plan))
+	}
+}
+
+func TestReconciler_UpdateReplaceAndDelete(t *testing.T) {
+	fake, reconciler := setupReconciler(t)
+	ctx := context.Background()
+	spec := testSpec()
+	state := NewState()
+
+	plan, _ := reconciler.Plan(ctx, spec, state)
+	if err := reconciler.Apply(ctx, plan, state); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	spec.Templates[0].WatchCount = 2
+	spec.Webhooks[0].URL = "https://example.com/new-hook"
+	spec.LandingPages = nil
+
+	plan, err := reconciler.Plan(ctx, spec, state)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	want := []string{
+		"update template Badge iOS",
+		"replace webhook Audit",
+		"delete landing_page Lobby",
+	}
+	if got := actions(plan); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Plan() = %v, want %v", got, want)
+	}
+
+	diffs := plan.Changes[0].Diffs
+	if len(diffs) != 1 || diffs[0].Field != "watch_count" || diffs[0].Old != "1" || diffs[0].New != "2" {
+		t.Errorf("template diffs = %+v", diffs)
+	}
+
+	var out bytes.Buffer
+	plan.Write(&out)
+	if !strings.Contains(out.String(), `watch_count: "1" => "2"`) {
+		t.Errorf("Write() output missing diff:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Plan: 1 to update, 1 to replace, 1 to delete.") {
+		t.Errorf("Write() output missing summary:\n%s", out.String())
+	}
+
+	if err := reconciler.Apply(ctx, plan, state); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	webhook, _ := state.Get(KindWebhook, "Audit")
+	if fake.webhooks[webhook.ID]["url"] != "https://example.com/new-hook" {
+		t.Errorf("webhook was not replaced, requests: %v", fake.requests)
+	}
+	if len(fake.pages) != 0 {
+		t.Errorf("landing page was not deleted, requests: %v", fake.requests)
+	}
+	if _, ok := state.Get(KindLandingPage, "Lobby"); ok {
+		t.Error("deleted landing page is still in the state")
+	}
+}
+
+func TestReconciler_PreflightPairTemplateSwap(t *testing.T) {
+	fake, reconciler := setupReconciler(t)
+	ctx := context.Background()
+	spec := testSpec()
+	spec.Templates = append(spec.Templates, models.CreateTemplateParams{Name: "Badge Android 2", Platform: "android", UseCase: "employee_badge", Protocol: "desfire", WatchCount: 1, IPhoneCount: 1})
+	state := NewState()
+
+	// Templates created by the run are paired without a published check
+	plan, _ := reconciler.Plan(ctx, spec, state)
+	if err := reconciler.Apply(ctx, plan, state); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	for id := range fake.templates {
+		fake.templates[id]["status"] = "ready"
+	}
+	draft, _ := state.Get(KindTemplate, "Badge Android 2")
+	fake.templates[draft.ID]["status"] = "draft"
+
+	spec.TemplatePairs[0].GoogleTemplate = "Badge Android 2"
+	plan, err := reconciler.Plan(ctx, spec, state)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	err = reconciler.Apply(ctx, plan, state)
+	var pairErr *services.PassTemplatePairError
+	if !errors.As(err, &pairErr) || !strings.Contains(err.Error(), draft.ID+" is not published") {
+		t.Errorf("Apply() error = %v, want unpublished template error", err)
+	}
+}
+
+func TestReconciler_AdoptByName(t *testing.T) {
+	fake, reconciler := setupReconciler(t)
+	fake.pages["lp_existing"] = map[string]interface{}{"id": "lp_existing", "name": "Lobby", "kind": "universal"}
+
+	spec := &Spec{LandingPages: []models.CreateLandingPageParams{{Name: "Lobby", Kind: "universal"}}}
+	state := NewState()
+
+	plan, err := reconciler.Plan(context.Background(), spec, state)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if got := actions(plan); len(got) != 1 || got[0] != "adopt landing_page Lobby" {
+		t.Fatalf("Plan() = %v, want adopt", got)
+	}
+
+	if err := reconciler.Apply(context.Background(), plan, state); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if entry, _ := state.Get(KindLandingPage, "Lobby"); entry.ID != "lp_existing" {
+		t.Errorf("state ID = %q, want lp_existing", entry.ID)
+	}
+}
+
+func TestReconciler_ImmutableField(t *testing.T) {
+	_, reconciler := setupReconciler(t)
+	ctx := context.Background()
+	spec := &Spec{Templates: testSpec().Templates[:1]}
+	state := NewState()
+
+	plan, _ := reconciler.Plan(ctx, spec, state)
+	reconciler.Apply(ctx, plan, state)
+
+	spec.Templates[0].Protocol = "seos"
+	_, err := reconciler.Plan(ctx, spec, state)
+	if err == nil || !strings.Contains(err.Error(), "protocol cannot be changed") {
+		t.Errorf("Plan() error = %v, want immutable field error", err)
+	}
+}
+
+func TestSpec_Validate(t *testing.T) {
+	spec := testSpec()
+	spec.Webhooks = append(spec.Webhooks, spec.Webhooks[0])
+	if err := spec.Validate(); err == nil || !strings.Contains(err.Error(), `duplicate webhook "Audit"`) {
+		t.Errorf("Validate() error = %v, want duplicate error", err)
+	}
+
+	spec = testSpec()
+	spec.Templates[1].Protocol = "seos"
+	if err := spec.Validate(); err == nil || !strings.Contains(err.Error(), "protocols differ") {
+		t.Errorf("Validate() error = %v, want protocol error", err)
+	}
+
+	spec = testSpec()
+	spec.TemplatePairs[0].GoogleTemplate = "Missing"
+	if err := spec.Validate(); err == nil || !strings.Contains(err.Error(), `unknown template "Missing"`) {
+		t.Errorf("Validate() error = %v, want unknown template error", err)
+	}
+}
+
+func TestState_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState() on missing file error = %v", err)
+	}
+	state.Set(KindTemplate, "Badge", StateEntry{ID: "tmpl_1", Digest: "abc"})
+	if err := state.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if entry, ok := loaded.Get(KindTemplate, "Badge"); !ok || entry.ID != "tmpl_1" {
+		t.Errorf("loaded entry = %+v, %v", entry, ok)
+	}
+}
+
+func TestLoadSpec(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "accessgrid.json")
+	data, _ := json.Marshal(testSpec())
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if spec, err := LoadSpec(path); err != nil || len(spec.Templates) != 2 {
+		t.Errorf("LoadSpec() = %v, %v", spec, err)
+	}
+
+	yamlPath := filepath.Join(dir, "accessgrid.yaml")
+	yamlSpec := `# Badge templates
+templates:
+  - name: Badge iOS
+    platform: apple
+    use_case: employee_badge
+    protocol: desfire
+    watch_count: 1
+    iphone_count: 1
+  - name: Badge Android
+    platform: android
+    use_case: employee_badge
+    protocol: desfire
+    watch_count: 1
+    iphone_count: 1
+template_pairs:
+- {name: Badge, apple_template: Badge iOS, google_template: Badge Android}
+webhooks:
+  - name: Audit
+    url: "https://example.com/hook"
+    subscribed_events: [ag.access_pass.issued]
+`
+	if err := os.WriteFile(yamlPath, []byte(yamlSpec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := LoadSpec(yamlPath)
+	if err != nil {
+		t.Fatalf("LoadSpec() error = %v", err)
+	}
+	if len(spec.Templates) != 2 || spec.Templates[1].WatchCount != 1 || spec.TemplatePairs[0].GoogleTemplate != "Badge Android" ||
+		spec.Webhooks[0].SubscribedEvents[0] != "ag.access_pass.issued" {
+		t.Errorf("LoadSpec() = %+v", spec)
+	}
+
+	os.WriteFile(yamlPath, []byte("templates:\n  - name: [Badge\n"), 0o644)
+	if _, err := LoadSpec(yamlPath); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("LoadSpec() error = %v, want a line number", err)
+	}
+}
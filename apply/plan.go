@@ -0,0 +1,483 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// Action is the operation a change performs on a resource
+type Action string
+
+// Actions a plan can contain
+const (
+	// ActionCreate creates a resource that does not exist yet
+	ActionCreate Action = "create"
+	// ActionAdopt records an existing resource with a matching name in the state
+	ActionAdopt Action = "adopt"
+	// ActionUpdate updates a resource in place
+	ActionUpdate Action = "update"
+	// ActionReplace deletes a resource and creates it again, for resources
+	// the API cannot update
+	ActionReplace Action = "replace"
+	// ActionDelete deletes a resource that was removed from the spec
+	ActionDelete Action = "delete"
+)
+
+// pendingID stands in for the ID of a resource that is created by the same plan
+const pendingID = "(known after apply)"
+
+// FieldDiff is a difference between the live and desired value of a field
+type FieldDiff struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Change is a single planned operation
+type Change struct {
+	Kind   Kind
+	Name   string
+	Action Action
+	ID     string
+	Diffs  []FieldDiff
+	// SpecChanged is set when the spec differs from the one last applied,
+	// which may involve write-only fields that do not show up in Diffs
+	SpecChanged bool
+
+	spec   interface{}
+	digest string
+}
+
+// Plan is the ordered list of changes that brings an account in line with
+// a spec. Creates and updates come first in dependency order, followed by
+// deletes in reverse dependency order.
+type Plan struct {
+	Changes []Change
+}
+
+// Write prints a human readable diff of the plan
+func (p *Plan) Write(w io.Writer) error {
+	if len(p.Changes) == 0 {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+
+	symbols := map[Action]string{
+		ActionCreate:  "+",
+		ActionAdopt:   "=",
+		ActionUpdate:  "~",
+		ActionReplace: "-/+",
+		ActionDelete:  "-",
+	}
+	counts := map[Action]int{}
+
+	for _, c := range p.Changes {
+		counts[c.Action]++
+		line := fmt.Sprintf("%s %s %s %q", symbols[c.Action], c.Action, c.Kind, c.Name)
+		if c.ID != "" {
+			line += fmt.Sprintf(" (%s)", c.ID)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+		for _, d := range c.Diffs {
+			if _, err := fmt.Fprintf(w, "    %s: %q => %q\n", d.Field, d.Old, d.New); err != nil {
+				return err
+			}
+		}
+		if c.SpecChanged && len(c.Diffs) == 0 {
+			if _, err := fmt.Fprintln(w, "    (spec changed since last apply)"); err != nil {
+				return err
+			}
+		}
+	}
+
+	var summary []string
+	for _, a := range []Action{ActionCreate, ActionAdopt, ActionUpdate, ActionReplace, ActionDelete} {
+		if counts[a] > 0 {
+			summary = append(summary, fmt.Sprintf("%d to %s", counts[a], a))
+		}
+	}
+	_, err := fmt.Fprintf(w, "\nPlan: %s.\n", strings.Join(summary, ", "))
+	return err
+}
+
+// Reconciler plans and applies spec changes using the console services
+type Reconciler struct {
+	console *services.ConsoleService
+}
+
+// NewReconciler creates a new Reconciler
+func NewReconciler(console *services.ConsoleService) *Reconciler {
+	return &Reconciler{console: console}
+}
+
+// liveResource is the comparable view of a resource that exists in the account
+type liveResource struct {
+	id     string
+	name   string
+	fields map[string]string
+}
+
+// desiredResource is the comparable view of a resource in the spec
+type desiredResource struct {
+	name      string
+	fields    map[string]string
+	immutable []string
+	spec      interface{}
+}
+
+// Plan compares the spec with the live account and the state and returns
+// the changes needed to reconcile them. It does not modify anything.
+func (r *Reconciler) Plan(ctx context.Context, spec *Spec, state *State) (*Plan, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	var changes, deletes []Change
+	add := func(kind Kind, want []desiredResource, live []liveResource, update Action) (map[string]string, error) {
+		c, d, ids, err := planKind(kind, want, live, state, update)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c...)
+		deletes = append(d, deletes...)
+		return ids, nil
+	}
+
+	live, err := r.liveCredentialProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := add(KindCredentialProfile, desiredCredentialProfiles(spec), live, ActionUpdate); err != nil {
+		return nil, err
+	}
+
+	live, err = r.liveTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	templateIDs, err := add(KindTemplate, desiredTemplates(spec), live, ActionUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err = r.liveLandingPages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := add(KindLandingPage, desiredLandingPages(spec), live, ActionUpdate); err != nil {
+		return nil, err
+	}
+
+	live, err = r.liveWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := add(KindWebhook, desiredWebhooks(spec), live, ActionReplace); err != nil {
+		return nil, err
+	}
+
+	live, err = r.liveTemplatePairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := add(KindTemplatePair, desiredTemplatePairs(spec, templateIDs), live, ActionUpdate); err != nil {
+		return nil, err
+	}
+
+	return &Plan{Changes: append(changes, deletes...)}, nil
+}
+
+// planKind plans the changes for one kind of resource. It returns the
+// create and update changes, the delete changes, and the ID each desired
+// name resolves to.
+func planKind(kind Kind, want []desiredResource, live []liveResource, state *State, update Action) ([]Change, []Change, map[string]string, error) {
+	byID := map[string]liveResource{}
+	byName := map[string][]liveResource{}
+	for _, l := range live {
+		byID[l.id] = l
+		byName[l.name] = append(byName[l.name], l)
+	}
+
+	var changes, deletes []Change
+	ids := map[string]string{}
+	wanted := map[string]bool{}
+
+	for _, d := range want {
+		wanted[d.name] = true
+		dg := digest(d.spec)
+
+		entry, managed := state.Get(kind, d.name)
+		var cur liveResource
+		var found bool
+		if managed {
+			cur, found = byID[entry.ID]
+		} else if matches := byName[d.name]; len(matches) > 1 {
+			return nil, nil, nil, fmt.Errorf("cannot adopt %s %q: %d existing resources share that name", kind, d.name, len(matches))
+		} else if len(matches) == 1 {
+			cur, found = matches[0], true
+		}
+
+		if !found {
+			ids[d.name] = pendingID
+			changes = append(changes, Change{Kind: kind, Name: d.name, Action: ActionCreate, spec: d.spec, digest: dg})
+			continue
+		}
+		ids[d.name] = cur.id
+
+		diffs := diffFields(cur.fields, d.fields)
+		for _, diff := range diffs {
+			for _, field := range d.immutable {
+				if diff.Field == field {
+					return nil, nil, nil, fmt.Errorf("%s %q: %s cannot be changed from %q to %q; give it a new name to create a new %s",
+						kind, d.name, field, diff.Old, diff.New, kind)
+				}
+			}
+		}
+
+		change := Change{Kind: kind, Name: d.name, ID: cur.id, Diffs: diffs, spec: d.spec, digest: dg}
+		change.SpecChanged = managed && entry.Digest != dg
+		switch {
+		case len(diffs) > 0 || change.SpecChanged:
+			change.Action = update
+		case !managed:
+			change.Action = ActionAdopt
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	var stale []string
+	for name := range state.Resources[kind] {
+		if !wanted[name] {
+			stale = append(stale, name)
+		}
+	}
+	sort.Strings(stale)
+	for _, name := range stale {
+		entry, _ := state.Get(kind, name)
+		deletes = append(deletes, Change{Kind: kind, Name: name, Action: ActionDelete, ID: entry.ID})
+	}
+
+	return changes, deletes, ids, nil
+}
+
+// diffFields compares the desired fields with the live ones
+func diffFields(live, desired map[string]string) []FieldDiff {
+	var keys []string
+	for k := range desired {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var diffs []FieldDiff
+	for _, k := range keys {
+		if live[k] != desired[k] {
+			diffs = append(diffs, FieldDiff{Field: k, Old: live[k], New: desired[k]})
+		}
+	}
+	return diffs
+}
+
+// setIfNotEmpty adds a desired field only when the spec sets it, because
+// omitted fields are left untouched by the API
+func setIfNotEmpty(fields map[string]string, key, value string) {
+	if value != "" {
+		fields[key] = value
+	}
+}
+
+func desiredCredentialProfiles(spec *Spec) []desiredResource {
+	var want []desiredResource
+	for _, p := range spec.CredentialProfiles {
+		want = append(want, desiredResource{name: p.Name, fields: map[string]string{}, spec: p})
+	}
+	return want
+}
+
+func (r *Reconciler) liveCredentialProfiles(ctx context.Context) ([]liveResource, error) {
+	profiles, err := r.console.CredentialProfiles.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var live []liveResource
+	for _, p := range profiles {
+		live = append(live, liveResource{id: p.ID, name: p.Name, fields: map[string]string{}})
+	}
+	return live, nil
+}
+
+func desiredTemplates(spec *Spec) []desiredResource {
+	var want []desiredResource
+	for _, t := range spec.Templates {
+		fields := map[string]string{
+			"platform":                  t.Platform,
+			"use_case":                  t.UseCase,
+			"protocol":                  t.Protocol,
+			"allow_on_multiple_devices": strconv.FormatBool(t.AllowOnMultipleDevices),
+			"watch_count":               strconv.Itoa(t.WatchCount),
+			"iphone_count":              strconv.Itoa(t.IPhoneCount),
+		}
+		setIfNotEmpty(fields, "background_color", t.BackgroundColor)
+		setIfNotEmpty(fields, "label_color", t.LabelColor)
+		setIfNotEmpty(fields, "label_secondary_color", t.LabelSecondaryColor)
+		setIfNotEmpty(fields, "support_url", t.SupportURL)
+		setIfNotEmpty(fields, "support_phone_number", t.SupportPhoneNumber)
+		setIfNotEmpty(fields, "support_email", t.SupportEmail)
+		setIfNotEmpty(fields, "privacy_policy_url", t.PrivacyPolicyURL)
+		setIfNotEmpty(fields, "terms_and_conditions_url", t.TermsAndConditionsURL)
+
+		want = append(want, desiredResource{
+			name:      t.Name,
+			fields:    fields,
+			immutable: []string{"platform", "use_case", "protocol"},
+			spec:      t,
+		})
+	}
+	return want
+}
+
+func (r *Reconciler) liveTemplates(ctx context.Context) ([]liveResource, error) {
+	templates, err := r.console.ListTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var live []liveResource
+	for _, summary := range templates {
+		// The list omits design and support details, so read each template
+		t, err := r.console.ReadTemplate(ctx, summary.ID)
+		if err != nil {
+			return nil, err
+		}
+		live = append(live, liveResource{
+			id:   t.ID,
+			name: t.Name,
+			fields: map[string]string{
+				"platform":                  t.Platform,
+				"use_case":                  t.UseCase,
+				"protocol":                  t.Protocol,
+				"allow_on_multiple_devices": strconv.FormatBool(t.AllowOnMultipleDevices),
+				"watch_count":               strconv.Itoa(t.WatchCount),
+				"iphone_count":              strconv.Itoa(t.IPhoneCount),
+				"background_color":          t.Design.BackgroundColor,
+				"label_color":               t.Design.LabelColor,
+				"label_secondary_color":     t.Design.LabelSecondaryColor,
+				"support_url":               t.SupportInfo.SupportURL,
+				"support_phone_number":      t.SupportInfo.SupportPhoneNumber,
+				"support_email":             t.SupportInfo.SupportEmail,
+				"privacy_policy_url":        t.SupportInfo.PrivacyPolicyURL,
+				"terms_and_conditions_url":  t.SupportInfo.TermsAndConditionsURL,
+			},
+		})
+	}
+	return live, nil
+}
+
+func desiredLandingPages(spec *Spec) []desiredResource {
+	var want []desiredResource
+	for _, p := range spec.LandingPages {
+		want = append(want, desiredResource{
+			name:      p.Name,
+			fields:    map[string]string{"kind": p.Kind},
+			immutable: []string{"kind"},
+			spec:      p,
+		})
+	}
+	return want
+}
+
+func (r *Reconciler) liveLandingPages(ctx context.Context) ([]liveResource, error) {
+	pages, err := r.console.ListLandingPages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var live []liveResource
+	for _, p := range pages {
+		live = append(live, liveResource{id: p.ID, name: p.Name, fields: map[string]string{"kind": p.Kind}})
+	}
+	return live, nil
+}
+
+func webhookFields(url, authMethod string, events []string) map[string]string {
+	if authMethod == "" {
+		authMethod = "bearer_token"
+	}
+	sorted := append([]string(nil), events...)
+	sort.Strings(sorted)
+	return map[string]string{
+		"url":               url,
+		"auth_method":       authMethod,
+		"subscribed_events": strings.Join(sorted, ","),
+	}
+}
+
+func desiredWebhooks(spec *Spec) []desiredResource {
+	var want []desiredResource
+	for _, w := range spec.Webhooks {
+		want = append(want, desiredResource{
+			name:   w.Name,
+			fields: webhookFields(w.URL, w.AuthMethod, w.SubscribedEvents),
+			spec:   w,
+		})
+	}
+	return want
+}
+
+func (r *Reconciler) liveWebhooks(ctx context.Context) ([]liveResource, error) {
+	response, err := r.console.Webhooks.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var live []liveResource
+	for _, w := range response.Webhooks {
+		live = append(live, liveResource{id: w.ID, name: w.Name, fields: webhookFields(w.URL, w.AuthMethod, w.SubscribedEvents)})
+	}
+	return live, nil
+}
+
+func desiredTemplatePairs(spec *Spec, templateIDs map[string]string) []desiredResource {
+	var want []desiredResource
+	for _, p := range spec.TemplatePairs {
+		want = append(want, desiredResource{
+			name: p.Name,
+			fields: map[string]string{
+				"apple_card_template_id":  templateIDs[p.AppleTemplate],
+				"google_card_template_id": templateIDs[p.GoogleTemplate],
+			},
+			spec: p,
+		})
+	}
+	return want
+}
+
+func (r *Reconciler) liveTemplatePairs(ctx context.Context) ([]liveResource, error) {
+	var live []liveResource
+	for page := 1; ; page++ {
+		response, err := r.console.ListPassTemplatePairs(ctx, models.ListPassTemplatePairsParams{Page: page})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range response.PassTemplatePairs {
+			fields := map[string]string{}
+			if p.IOSTemplate != nil {
+				fields["apple_card_template_id"] = p.IOSTemplate.ID
+			}
+			if p.AndroidTemplate != nil {
+				fields["google_card_template_id"] = p.AndroidTemplate.ID
+			}
+			live = append(live, liveResource{id: p.ID, name: p.Name, fields: fields})
+		}
+		if page >= response.Pagination.TotalPages {
+			return live, nil
+		}
+	}
+}
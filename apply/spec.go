@@ -0,0 +1,126 @@
+// Package apply reconciles console resources against a declarative spec.
+//
+// A spec lists the credential profiles, templates, pass template pairs,
+// landing pages and webhooks an account should have. Resources are
+// identified by name; a state file records the ID each name was created
+// or adopted under so later runs can update or delete it.
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Access-Grid/accessgrid-go/internal/yaml"
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// Spec describes the desired console resources of an account. Resource
+// fields use the same JSON names as the API.
+type Spec struct {
+	CredentialProfiles []models.CreateCredentialProfileParams `json:"credential_profiles,omitempty"`
+	Templates          []models.CreateTemplateParams          `json:"templates,omitempty"`
+	TemplatePairs      []TemplatePairSpec                     `json:"template_pairs,omitempty"`
+	LandingPages       []models.CreateLandingPageParams       `json:"landing_pages,omitempty"`
+	Webhooks           []models.CreateWebhookParams           `json:"webhooks,omitempty"`
+}
+
+// TemplatePairSpec describes a pass template pair. The templates are
+// referenced by their name in the same spec.
+type TemplatePairSpec struct {
+	Name           string `json:"name"`
+	AppleTemplate  string `json:"apple_template"`
+	GoogleTemplate string `json:"google_template"`
+}
+
+// LoadSpec reads and validates a spec file. Files named .yaml or .yml are
+// read as YAML, anything else as JSON.
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading spec: %w", err)
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yaml.ToJSON(data); err != nil {
+			return nil, fmt.Errorf("error parsing spec %s: %w", path, err)
+		}
+	}
+
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("error parsing spec %s: %w", path, err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Validate checks that every resource has a unique name and that template
+// pairs reference an Apple and a Google template defined in the spec that
+// use the same protocol
+func (s *Spec) Validate() error {
+	names := map[Kind]map[string]bool{}
+	check := func(kind Kind, name string) error {
+		if name == "" {
+			return fmt.Errorf("%s without a name", kind)
+		}
+		if names[kind] == nil {
+			names[kind] = map[string]bool{}
+		}
+		if names[kind][name] {
+			return fmt.Errorf("duplicate %s %q", kind, name)
+		}
+		names[kind][name] = true
+		return nil
+	}
+
+	for _, p := range s.CredentialProfiles {
+		if err := check(KindCredentialProfile, p.Name); err != nil {
+			return err
+		}
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("%s %q: %w", KindCredentialProfile, p.Name, err)
+		}
+	}
+	templates := map[string]models.CreateTemplateParams{}
+	for _, t := range s.Templates {
+		if err := check(KindTemplate, t.Name); err != nil {
+			return err
+		}
+		templates[t.Name] = t
+	}
+	for _, p := range s.TemplatePairs {
+		if err := check(KindTemplatePair, p.Name); err != nil {
+			return err
+		}
+		for _, ref := range []string{p.AppleTemplate, p.GoogleTemplate} {
+			if !names[KindTemplate][ref] {
+				return fmt.Errorf("%s %q references unknown template %q", KindTemplatePair, p.Name, ref)
+			}
+		}
+		apple, google := templates[p.AppleTemplate], templates[p.GoogleTemplate]
+		if apple.Platform != "apple" {
+			return fmt.Errorf("%s %q: Apple template %q has platform %q, want apple", KindTemplatePair, p.Name, apple.Name, apple.Platform)
+		}
+		if google.Platform != "android" && google.Platform != "google" {
+			return fmt.Errorf("%s %q: Google template %q has platform %q, want android", KindTemplatePair, p.Name, google.Name, google.Platform)
+		}
+		if apple.Protocol != google.Protocol {
+			return fmt.Errorf("%s %q: protocols differ: Apple template uses %q, Google template uses %q", KindTemplatePair, p.Name, apple.Protocol, google.Protocol)
+		}
+	}
+	for _, p := range s.LandingPages {
+		if err := check(KindLandingPage, p.Name); err != nil {
+			return err
+		}
+	}
+	for _, w := range s.Webhooks {
+		if err := check(KindWebhook, w.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
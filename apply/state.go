@@ -0,0 +1,95 @@
+package apply
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Access-Grid/accessgrid-go/internal/atomicfile"
+)
+
+// Kind identifies a type of console resource
+type Kind string
+
+// Resource kinds managed by the reconciler
+const (
+	KindCredentialProfile Kind = "credential_profile"
+	KindTemplate          Kind = "template"
+	KindTemplatePair      Kind = "template_pair"
+	KindLandingPage       Kind = "landing_page"
+	KindWebhook           Kind = "webhook"
+)
+
+// StateEntry records the ID of a managed resource and a digest of the spec
+// it was last applied from
+type StateEntry struct {
+	ID     string `json:"id"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// State maps resource names to the IDs of the resources they manage
+type State struct {
+	Resources map[Kind]map[string]StateEntry `json:"resources"`
+}
+
+// NewState returns an empty state
+func NewState() *State {
+	return &State{Resources: map[Kind]map[string]StateEntry{}}
+}
+
+// LoadState reads a state file. A missing file yields an empty state.
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewState(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state: %w", err)
+	}
+
+	state := NewState()
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("error parsing state %s: %w", path, err)
+	}
+	if state.Resources == nil {
+		state.Resources = map[Kind]map[string]StateEntry{}
+	}
+	return state, nil
+}
+
+// Save writes the state file atomically
+func (s *State) Save(path string) error {
+	if err := atomicfile.WriteJSON(path, s); err != nil {
+		return fmt.Errorf("error writing state: %w", err)
+	}
+	return nil
+}
+
+// Get returns the state entry of a named resource
+func (s *State) Get(kind Kind, name string) (StateEntry, bool) {
+	entry, ok := s.Resources[kind][name]
+	return entry, ok
+}
+
+// Set records the state entry of a named resource
+func (s *State) Set(kind Kind, name string, entry StateEntry) {
+	if s.Resources[kind] == nil {
+		s.Resources[kind] = map[string]StateEntry{}
+	}
+	s.Resources[kind][name] = entry
+}
+
+// Remove forgets a named resource
+func (s *State) Remove(kind Kind, name string) {
+	delete(s.Resources[kind], name)
+}
+
+// digest returns a stable fingerprint of a resource spec. It lets the
+// planner notice changes to write-only fields such as passwords and images
+// that cannot be compared with the live resource.
+func digest(spec interface{}) string {
+	data, _ := json.Marshal(spec)
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
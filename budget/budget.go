@@ -0,0 +1,341 @@
+// Package budget guards provisioning with spend and pass count limits.
+//
+// A Guard wraps AccessCardsService.Provision, counts the passes issued and
+// their estimated cost in the current period, and refuses to provision
+// once a limit would be crossed unless the call carries an explicit
+// override. Counters are seeded from the ledger with Sync, so passes
+// issued by other processes count too.
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/ledger"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// ErrBudgetExceeded is matched by errors.Is for a refused provision
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Provisioner provisions cards. *services.AccessCardsService and *Guard
+// implement it.
+type Provisioner interface {
+	Provision(ctx context.Context, params models.ProvisionParams) (*models.CardProvisionResponse, error)
+}
+
+var (
+	_ Provisioner = (*services.AccessCardsService)(nil)
+	_ Provisioner = (*Guard)(nil)
+)
+
+// Period is the interval a budget applies to
+type Period string
+
+// Supported periods
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+)
+
+// start returns the beginning of the period containing t, in UTC. Weeks
+// start on Monday.
+func (p Period) start(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case PeriodDay:
+		return day
+	case PeriodWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// next returns the beginning of the following period
+func (p Period) next(start time.Time) time.Time {
+	switch p {
+	case PeriodDay:
+		return start.AddDate(0, 0, 1)
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+// AlertLevel classifies an Alert
+type AlertLevel string
+
+const (
+	// AlertWarning is sent once per period when usage crosses WarnFraction
+	AlertWarning AlertLevel = "warning"
+	// AlertExceeded is sent for each refused provision
+	AlertExceeded AlertLevel = "exceeded"
+	// AlertOverride is sent for each provision allowed past a limit
+	AlertOverride AlertLevel = "override"
+)
+
+// Alert reports budget usage to Config.OnAlert
+type Alert struct {
+	Level       AlertLevel
+	PeriodStart time.Time
+	Usage       Usage
+	// EmployeeID and CardTemplateID describe the provision that raised
+	// the alert
+	EmployeeID     string
+	CardTemplateID string
+	// Reason is the override reason for AlertOverride
+	Reason  string
+	Message string
+}
+
+// Usage is the pass count and spend of the current period
+type Usage struct {
+	Passes int
+	Spend  models.Decimal
+}
+
+// Config sets the limits of a Guard. A zero limit is not enforced.
+type Config struct {
+	// Period defaults to PeriodMonth
+	Period    Period
+	MaxPasses int
+	// MaxSpend is the most that may be spent per period, as a positive
+	// amount
+	MaxSpend models.Decimal
+	// PassCost is the estimated cost of one pass, counted locally until
+	// the next Sync replaces it with ledger data
+	PassCost models.Decimal
+	// WarnFraction is the share of a limit that triggers AlertWarning.
+	// Defaults to 0.8.
+	WarnFraction float64
+	// OnAlert receives alerts synchronously, after the guard is unlocked, so
+	// it may call the guard; keep it fast
+	OnAlert func(Alert)
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+type overrideKey struct{}
+
+// WithOverride returns a context that lets a provision exceed the budget.
+// The reason is reported in an AlertOverride.
+func WithOverride(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, overrideKey{}, reason)
+}
+
+func overrideReason(ctx context.Context) (string, bool) {
+	reason, ok := ctx.Value(overrideKey{}).(string)
+	return reason, ok
+}
+
+// ExceededError describes a refused provision
+type ExceededError struct {
+	Limit string
+	Usage Usage
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("budget exceeded: %s (used %d passes, %s spent this period)", e.Limit, e.Usage.Passes, e.Usage.Spend)
+}
+
+// Is matches ErrBudgetExceeded
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// Guard enforces a budget around a Provisioner
+type Guard struct {
+	next    Provisioner
+	console *services.ConsoleService
+	config  Config
+
+	mu          sync.Mutex
+	periodStart time.Time
+	usage       Usage
+	warned      bool
+}
+
+// NewGuard wraps next with a budget. console is used by Sync to read the
+// ledger and may be nil to rely on local counters only.
+func NewGuard(next Provisioner, console *services.ConsoleService, config Config) *Guard {
+	if config.Period == "" {
+		config.Period = PeriodMonth
+	}
+	if config.WarnFraction <= 0 {
+		config.WarnFraction = 0.8
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Guard{next: next, console: console, config: config}
+}
+
+// rollPeriod resets the counters when a new period has started. The
+// caller must hold g.mu.
+func (g *Guard) rollPeriod() {
+	start := g.config.Period.start(g.config.Now())
+	if !start.Equal(g.periodStart) {
+		g.periodStart = start
+		g.usage = Usage{}
+		g.warned = false
+	}
+}
+
+// Usage returns the usage of the current period
+func (g *Guard) Usage() Usage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollPeriod()
+	return g.usage
+}
+
+// Sync reads the current period's ledger and raises the counters to match
+// it. Counters are never lowered, so passes provisioned since the ledger
+// was last updated still count.
+func (g *Guard) Sync(ctx context.Context) error {
+	if g.console == nil {
+		return errors.New("budget: Sync requires a console service")
+	}
+
+	g.mu.Lock()
+	g.rollPeriod()
+	start := g.periodStart
+	g.mu.Unlock()
+
+	items, err := ledger.Collect(ctx, g.console, start, g.config.Period.next(start))
+	if err != nil {
+		return err
+	}
+
+	var billed Usage
+	for _, item := range items {
+		amount, err := item.AmountDecimal()
+		if err != nil {
+			return fmt.Errorf("ledger item %s: %w", item.ID, err)
+		}
+		if amount.Sign() >= 0 {
+			continue
+		}
+		if billed.Spend, err = billed.Spend.Add(amount.Neg()); err != nil {
+			return fmt.Errorf("ledger item %s: %w", item.ID, err)
+		}
+		if item.AccessPass != nil {
+			billed.Passes++
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.periodStart.Equal(start) {
+		// The period ended while the ledger was read
+		return nil
+	}
+	if billed.Passes > g.usage.Passes {
+		g.usage.Passes = billed.Passes
+	}
+	if billed.Spend.Cmp(g.usage.Spend) > 0 {
+		g.usage.Spend = billed.Spend
+	}
+	return nil
+}
+
+// exceeded names the limit the usage crosses, if any
+func (g *Guard) exceeded(usage Usage) string {
+	if g.config.MaxPasses > 0 && usage.Passes > g.config.MaxPasses {
+		return fmt.Sprintf("more than %d passes per %s", g.config.MaxPasses, g.config.Period)
+	}
+	if !g.config.MaxSpend.IsZero() && usage.Spend.Cmp(g.config.MaxSpend) > 0 {
+		return fmt.Sprintf("more than %s spent per %s", g.config.MaxSpend, g.config.Period)
+	}
+	return ""
+}
+
+// nearLimit reports whether the usage has crossed WarnFraction of a limit
+func (g *Guard) nearLimit(usage Usage) bool {
+	if g.config.MaxPasses > 0 && float64(usage.Passes) >= g.config.WarnFraction*float64(g.config.MaxPasses) {
+		return true
+	}
+	if !g.config.MaxSpend.IsZero() && usage.Spend.Float64() >= g.config.WarnFraction*g.config.MaxSpend.Float64() {
+		return true
+	}
+	return false
+}
+
+// newAlert describes an alert. The caller must hold g.mu.
+func (g *Guard) newAlert(level AlertLevel, params models.ProvisionParams, usage Usage, reason, message string) Alert {
+	return Alert{
+		Level:          level,
+		PeriodStart:    g.periodStart,
+		Usage:          usage,
+		EmployeeID:     params.EmployeeID,
+		CardTemplateID: params.CardTemplateID,
+		Reason:         reason,
+		Message:        message,
+	}
+}
+
+// notify passes alerts to OnAlert. It is called without g.mu held, so the
+// callback may use the guard.
+func (g *Guard) notify(alerts []Alert) {
+	if g.config.OnAlert == nil {
+		return
+	}
+	for _, alert := range alerts {
+		g.config.OnAlert(alert)
+	}
+}
+
+// Provision provisions a card if the budget allows it. The pass is counted
+// before the request is sent, so concurrent callers cannot overshoot the
+// limit together, and uncounted again if the request fails.
+func (g *Guard) Provision(ctx context.Context, params models.ProvisionParams) (*models.CardProvisionResponse, error) {
+	var alerts []Alert
+	g.mu.Lock()
+	g.rollPeriod()
+	period := g.periodStart
+	spend, err := g.usage.Spend.Add(g.config.PassCost)
+	if err != nil {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("budget: %w", err)
+	}
+	projected := Usage{Passes: g.usage.Passes + 1, Spend: spend}
+	if limit := g.exceeded(projected); limit != "" {
+		reason, ok := overrideReason(ctx)
+		if !ok {
+			usage := g.usage
+			alerts = append(alerts, g.newAlert(AlertExceeded, params, usage, "", limit))
+			g.mu.Unlock()
+			g.notify(alerts)
+			return nil, &ExceededError{Limit: limit, Usage: usage}
+		}
+		alerts = append(alerts, g.newAlert(AlertOverride, params, projected, reason, limit))
+	}
+	g.usage = projected
+	if !g.warned && g.nearLimit(projected) {
+		g.warned = true
+		alerts = append(alerts, g.newAlert(AlertWarning, params, projected, "", fmt.Sprintf("%d passes and %s spent this %s", projected.Passes, projected.Spend, g.config.Period)))
+	}
+	g.mu.Unlock()
+	g.notify(alerts)
+
+	card, err := g.next.Provision(ctx, params)
+	if err != nil {
+		g.mu.Lock()
+		if g.periodStart.Equal(period) {
+			g.usage.Passes--
+			// Taking back a cost that was added cannot overflow
+			g.usage.Spend, _ = g.usage.Spend.Add(g.config.PassCost.Neg())
+		}
+		g.mu.Unlock()
+		return nil, err
+	}
+	return card, nil
+}
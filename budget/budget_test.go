@@ -0,0 +1,208 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// fakeProvisioner records provisions and fails when told to
+type fakeProvisioner struct {
+	mu    sync.Mutex
+	calls int
+	fail  bool
+}
+
+func (f *fakeProvisioner) Provision(ctx context.Context, params models.ProvisionParams) (*models.CardProvisionResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return nil, errors.New("provision failed")
+	}
+	f.calls++
+	return &models.CardProvisionResponse{ID: fmt.Sprintf("card_%d", f.calls), EmployeeID: params.EmployeeID}, nil
+}
+
+func decimal(t *testing.T, s string) models.Decimal {
+	t.Helper()
+	d, err := models.ParseDecimal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestPeriodStart(t *testing.T) {
+	at := time.Date(2026, 5, 14, 15, 30, 0, 0, time.UTC) // a Thursday
+	tests := []struct {
+		period Period
+		want   time.Time
+	}{
+		{PeriodDay, time.Date(2026, 5, 14, 0, 0, 0, 0, time.UTC)},
+		{PeriodWeek, time.Date(2026, 5, 11, 0, 0, 0, 0, time.UTC)},
+		{PeriodMonth, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := tt.period.start(at); !got.Equal(tt.want) {
+			t.Errorf("%s start = %v, want %v", tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestGuard_PassLimit(t *testing.T) {
+	now := time.Date(2026, 5, 14, 12, 0, 0, 0, time.UTC)
+	var alerts []Alert
+	next := &fakeProvisioner{}
+	guard := NewGuard(next, nil, Config{
+		Period:    PeriodDay,
+		MaxPasses: 5,
+		OnAlert:   func(a Alert) { alerts = append(alerts, a) },
+		Now:       func() time.Time { return now },
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if _, err := guard.Provision(ctx, models.ProvisionParams{EmployeeID: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Provision(%d) error = %v", i, err)
+		}
+	}
+	if len(alerts) != 1 || alerts[0].Level != AlertWarning || alerts[0].Usage.Passes != 4 {
+		t.Errorf("alerts = %+v, want one warning at 4 passes", alerts)
+	}
+
+	_, err := guard.Provision(ctx, models.ProvisionParams{EmployeeID: "6"})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Provision() error = %v, want ErrBudgetExceeded", err)
+	}
+	if last := alerts[len(alerts)-1]; last.Level != AlertExceeded || last.EmployeeID != "6" {
+		t.Errorf("last alert = %+v, want exceeded for employee 6", last)
+	}
+	if next.calls != 5 {
+		t.Errorf("provisioner called %d times, want 5", next.calls)
+	}
+
+	_, err = guard.Provision(WithOverride(ctx, "CEO onboarding"), models.ProvisionParams{EmployeeID: "6"})
+	if err != nil {
+		t.Fatalf("Provision() with override error = %v", err)
+	}
+	if last := alerts[len(alerts)-1]; last.Level != AlertOverride || last.Reason != "CEO onboarding" {
+		t.Errorf("last alert = %+v, want override", last)
+	}
+
+	// A new period starts with fresh counters
+	now = now.Add(24 * time.Hour)
+	if _, err := guard.Provision(ctx, models.ProvisionParams{}); err != nil {
+		t.Errorf("Provision() in new period error = %v", err)
+	}
+	if usage := guard.Usage(); usage.Passes != 1 {
+		t.Errorf("Usage().Passes = %d, want 1", usage.Passes)
+	}
+}
+
+func TestGuard_AlertCallbackMayUseGuard(t *testing.T) {
+	var guard *Guard
+	var usage []Usage
+	guard = NewGuard(&fakeProvisioner{}, nil, Config{
+		MaxPasses: 1,
+		OnAlert:   func(a Alert) { usage = append(usage, guard.Usage()) },
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		guard.Provision(context.Background(), models.ProvisionParams{})
+		guard.Provision(context.Background(), models.ProvisionParams{})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnAlert calling Usage deadlocked")
+	}
+	if len(usage) != 2 || usage[1].Passes != 1 {
+		t.Errorf("usage seen by alerts = %+v, want a warning and an exceeded alert", usage)
+	}
+}
+
+func TestGuard_SpendLimitAndFailedProvision(t *testing.T) {
+	next := &fakeProvisioner{}
+	guard := NewGuard(next, nil, Config{MaxSpend: decimal(t, "5.00"), PassCost: decimal(t, "1.50")})
+	ctx := context.Background()
+
+	next.fail = true
+	if _, err := guard.Provision(ctx, models.ProvisionParams{}); err == nil {
+		t.Fatal("expected provision error, got nil")
+	}
+	if usage := guard.Usage(); usage.Passes != 0 || !usage.Spend.IsZero() {
+		t.Errorf("Usage() = %+v after a failed provision, want zero", usage)
+	}
+
+	next.fail = false
+	for i := 0; i < 3; i++ {
+		if _, err := guard.Provision(ctx, models.ProvisionParams{}); err != nil {
+			t.Fatalf("Provision(%d) error = %v", i, err)
+		}
+	}
+	if _, err := guard.Provision(ctx, models.ProvisionParams{}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Provision() error = %v, want ErrBudgetExceeded at 6.00 of 5.00", err)
+	}
+}
+
+func TestGuard_ConcurrentProvisionsDoNotOvershoot(t *testing.T) {
+	next := &fakeProvisioner{}
+	guard := NewGuard(next, nil, Config{MaxPasses: 10})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			guard.Provision(context.Background(), models.ProvisionParams{})
+		}()
+	}
+	wg.Wait()
+
+	if next.calls != 10 {
+		t.Errorf("provisioner called %d times, want 10", next.calls)
+	}
+}
+
+func TestGuard_Sync(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ledger_items": [
+			{"id": "li_1", "created_at": "2026-05-02T10:00:00Z", "amount": -1.50, "kind": "access_pass_debit", "access_pass": {"id": "ap_1"}},
+			{"id": "li_2", "created_at": "2026-05-03T10:00:00Z", "amount": -1.50, "kind": "access_pass_debit", "access_pass": {"id": "ap_2"}},
+			{"id": "li_3", "created_at": "2026-05-04T10:00:00Z", "amount": 100, "kind": "credit", "access_pass": null}
+		], "pagination": {"current_page": 1, "total_pages": 1}}`))
+	}))
+	defer server.Close()
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+
+	guard := NewGuard(&fakeProvisioner{}, services.NewConsoleService(c), Config{
+		MaxPasses: 3,
+		Now:       func() time.Time { return time.Date(2026, 5, 14, 0, 0, 0, 0, time.UTC) },
+	})
+	if err := guard.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	usage := guard.Usage()
+	if usage.Passes != 2 || usage.Spend.String() != "3.00" {
+		t.Errorf("Usage() = %d passes, %s spent, want 2 and 3.00", usage.Passes, usage.Spend)
+	}
+	if _, err := guard.Provision(context.Background(), models.ProvisionParams{}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if _, err := guard.Provision(context.Background(), models.ProvisionParams{}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Provision() error = %v, want ErrBudgetExceeded", err)
+	}
+}
@@ -0,0 +1,370 @@
+// Package cardnumber allocates card numbers and site codes.
+//
+// An Allocator hands out numbers from ranges configured per site code and
+// card template. It skips numbers reserved in its Store and numbers already
+// carried by a card, and keeps every number it hands out reserved until it
+// is released. Allocations are serialized, so one Allocator can be shared
+// by concurrent provisioning goroutines.
+package cardnumber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/budget"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+var (
+	// ErrExhausted is returned when every number of a template's ranges
+	// is taken
+	ErrExhausted = errors.New("no card numbers left")
+	// ErrInUse is returned when reserving a number that is reserved or
+	// carried by a card
+	ErrInUse = errors.New("card number in use")
+)
+
+var (
+	_ services.CardNumberAllocator = (*Allocator)(nil)
+	_ budget.Provisioner           = (*Allocator)(nil)
+)
+
+// DefaultPath is the file of the default FileStore
+const DefaultPath = "card-numbers.json"
+
+// Range is a block of card numbers under one site code
+type Range struct {
+	// TemplateID limits the range to one card template. Ranges without a
+	// TemplateID serve templates that have no range of their own.
+	TemplateID string
+	SiteCode   string
+	// First and Last bound the card numbers, inclusive
+	First, Last uint64
+}
+
+// Config configures an Allocator
+type Config struct {
+	// Ranges are used in order, lowest free number first
+	Ranges []Range
+	// Store defaults to a FileStore at DefaultPath
+	Store Store
+	// Provisioner provisions the passes of Provision. Defaults to the cards
+	// service.
+	Provisioner budget.Provisioner
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Allocator hands out card numbers and site codes
+type Allocator struct {
+	cards  *services.AccessCardsService
+	config Config
+	mu     sync.Mutex
+}
+
+// New creates an allocator. Numbers are checked against the cards of the
+// account before they are handed out; a nil cards service skips the check.
+func New(cards *services.AccessCardsService, config Config) (*Allocator, error) {
+	if len(config.Ranges) == 0 {
+		return nil, errors.New("at least one range is required")
+	}
+	for _, r := range config.Ranges {
+		if r.Last < r.First {
+			return nil, fmt.Errorf("range %d-%d of site code %q ends before it starts", r.First, r.Last, r.SiteCode)
+		}
+	}
+	if config.Store == nil {
+		config.Store = &FileStore{Path: DefaultPath}
+	}
+	if config.Provisioner == nil && cards != nil {
+		config.Provisioner = cards
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Allocator{cards: cards, config: config}, nil
+}
+
+// Allocate reserves the lowest free card number of the template's ranges
+// and returns it with its site code
+func (a *Allocator) Allocate(ctx context.Context, templateID string) (cardNumber, siteCode string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ranges := a.ranges(templateID)
+	if len(ranges) == 0 {
+		return "", "", fmt.Errorf("no card number range for template %s", templateID)
+	}
+	state, reserved, err := a.load(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Numbers found on cards are saved along the way so they are skipped
+	// without another lookup next time
+	found := 0
+	for _, r := range ranges {
+		for n := r.First; ; n++ {
+			number := strconv.FormatUint(n, 10)
+			if !reserved[key(r.SiteCode, number)] {
+				card, err := a.inUse(ctx, r.SiteCode, number)
+				if err != nil {
+					a.saveFound(ctx, state, found)
+					return "", "", err
+				}
+				reservation := Reservation{SiteCode: r.SiteCode, CardNumber: number, TemplateID: templateID, ReservedAt: a.config.Now().UTC()}
+				if card == nil {
+					state.Reservations = append(state.Reservations, reservation)
+					if err := a.config.Store.Save(ctx, state); err != nil {
+						return "", "", err
+					}
+					return number, r.SiteCode, nil
+				}
+				reservation.TemplateID, reservation.CardID = card.CardTemplateID, card.ID
+				state.Reservations = append(state.Reservations, reservation)
+				reserved[key(r.SiteCode, number)] = true
+				found++
+			}
+			if n == r.Last {
+				break
+			}
+		}
+	}
+	a.saveFound(ctx, state, found)
+	return "", "", fmt.Errorf("%w for template %s", ErrExhausted, templateID)
+}
+
+// Reserve claims a specific card number, for example one assigned outside
+// the allocator. It returns ErrInUse if the number is reserved or carried
+// by a card.
+func (a *Allocator) Reserve(ctx context.Context, templateID, cardNumber, siteCode string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.reserve(ctx, templateID, cardNumber, siteCode)
+}
+
+func (a *Allocator) reserve(ctx context.Context, templateID, cardNumber, siteCode string) error {
+	state, reserved, err := a.load(ctx)
+	if err != nil {
+		return err
+	}
+	if reserved[key(siteCode, cardNumber)] {
+		return fmt.Errorf("%w: card number %s of site code %q is reserved", ErrInUse, cardNumber, siteCode)
+	}
+
+	card, err := a.inUse(ctx, siteCode, cardNumber)
+	if err != nil {
+		return err
+	}
+	reservation := Reservation{SiteCode: siteCode, CardNumber: cardNumber, TemplateID: templateID, ReservedAt: a.config.Now().UTC()}
+	if card != nil {
+		reservation.TemplateID, reservation.CardID = card.CardTemplateID, card.ID
+	}
+	state.Reservations = append(state.Reservations, reservation)
+	if err := a.config.Store.Save(ctx, state); err != nil {
+		return err
+	}
+	if card != nil {
+		return fmt.Errorf("%w: card number %s of site code %q is on card %s", ErrInUse, cardNumber, siteCode, card.ID)
+	}
+	return nil
+}
+
+// Release frees a card number so it can be allocated again. Releasing a
+// number that is not reserved is not an error.
+func (a *Allocator) Release(ctx context.Context, templateID, cardNumber, siteCode string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, _, err := a.load(ctx)
+	if err != nil {
+		return err
+	}
+	k := key(siteCode, cardNumber)
+	kept := state.Reservations[:0]
+	for _, r := range state.Reservations {
+		if key(r.SiteCode, r.CardNumber) != k {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(state.Reservations) {
+		return nil
+	}
+	state.Reservations = kept
+	return a.config.Store.Save(ctx, state)
+}
+
+// Reservations returns the reserved card numbers
+func (a *Allocator) Reservations(ctx context.Context) ([]Reservation, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, _, err := a.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return state.Reservations, nil
+}
+
+// Sync reserves the card numbers of every card in the account, such as
+// those assigned before the allocator was used, and returns how many were
+// added
+func (a *Allocator) Sync(ctx context.Context) (int, error) {
+	if a.cards == nil {
+		return 0, errors.New("Sync needs a cards service")
+	}
+	cards, err := a.cards.List(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, reserved, err := a.load(ctx)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, card := range cards {
+		k := key(card.SiteCode, card.CardNumber)
+		if card.State == "deleted" || card.CardNumber == "" || reserved[k] {
+			continue
+		}
+		state.Reservations = append(state.Reservations, Reservation{
+			SiteCode:   card.SiteCode,
+			CardNumber: card.CardNumber,
+			TemplateID: card.CardTemplateID,
+			CardID:     card.ID,
+			ReservedAt: a.config.Now().UTC(),
+		})
+		reserved[k] = true
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	return added, a.config.Store.Save(ctx, state)
+}
+
+// Provision provisions a pass with Config.Provisioner. A pass without a
+// card number or site code gets one allocated; an explicit one is reserved
+// first. The number is released if provisioning fails.
+func (a *Allocator) Provision(ctx context.Context, params models.ProvisionParams) (*models.CardProvisionResponse, error) {
+	if a.config.Provisioner == nil {
+		return nil, errors.New("no Provisioner configured")
+	}
+
+	if params.CardNumber == "" && params.SiteCode == "" {
+		number, site, err := a.Allocate(ctx, params.CardTemplateID)
+		if err != nil {
+			return nil, err
+		}
+		params.CardNumber, params.SiteCode = number, site
+	} else if err := a.Reserve(ctx, params.CardTemplateID, params.CardNumber, params.SiteCode); err != nil {
+		return nil, err
+	}
+
+	card, err := a.config.Provisioner.Provision(ctx, params)
+	if err != nil {
+		a.Release(context.WithoutCancel(ctx), params.CardTemplateID, params.CardNumber, params.SiteCode)
+		return nil, err
+	}
+	a.assign(ctx, params.SiteCode, params.CardNumber, card.ID)
+	return card, nil
+}
+
+// assign records the card that carries a reserved number. It is best
+// effort: the number stays reserved either way.
+func (a *Allocator) assign(ctx context.Context, siteCode, cardNumber, cardID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, _, err := a.load(ctx)
+	if err != nil {
+		return
+	}
+	k := key(siteCode, cardNumber)
+	for i := range state.Reservations {
+		if key(state.Reservations[i].SiteCode, state.Reservations[i].CardNumber) == k {
+			state.Reservations[i].CardID = cardID
+			a.config.Store.Save(ctx, state)
+			return
+		}
+	}
+}
+
+// ranges returns the ranges serving a template
+func (a *Allocator) ranges(templateID string) []Range {
+	var own, shared []Range
+	for _, r := range a.config.Ranges {
+		switch r.TemplateID {
+		case templateID:
+			own = append(own, r)
+		case "":
+			shared = append(shared, r)
+		}
+	}
+	if len(own) > 0 {
+		return own
+	}
+	return shared
+}
+
+// load reads the state and indexes its reservations
+func (a *Allocator) load(ctx context.Context) (*State, map[string]bool, error) {
+	state, err := a.config.Store.Load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if state == nil {
+		state = &State{}
+	}
+	reserved := make(map[string]bool, len(state.Reservations))
+	for _, r := range state.Reservations {
+		reserved[key(r.SiteCode, r.CardNumber)] = true
+	}
+	return state, reserved, nil
+}
+
+// saveFound saves the numbers found on cards during a failed allocation
+func (a *Allocator) saveFound(ctx context.Context, state *State, found int) {
+	if found > 0 {
+		a.config.Store.Save(ctx, state)
+	}
+}
+
+// inUse returns the card carrying a number, or nil
+func (a *Allocator) inUse(ctx context.Context, siteCode, cardNumber string) (*models.Card, error) {
+	if a.cards == nil {
+		return nil, nil
+	}
+	cards, err := a.cards.List(ctx, &models.ListKeysParams{CardNumber: cardNumber, SiteCode: siteCode})
+	if err != nil {
+		return nil, fmt.Errorf("error checking card number %s: %w", cardNumber, err)
+	}
+	k := key(siteCode, cardNumber)
+	for _, card := range cards {
+		if card.State != "deleted" && key(card.SiteCode, card.CardNumber) == k {
+			return &card, nil
+		}
+	}
+	return nil, nil
+}
+
+// key identifies a number within its site code. Numeric values are
+// compared without leading zeros.
+func key(siteCode, cardNumber string) string {
+	return canonical(siteCode) + ":" + canonical(cardNumber)
+}
+
+func canonical(value string) string {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return strconv.FormatUint(n, 10)
+	}
+	return value
+}
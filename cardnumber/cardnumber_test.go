@@ -0,0 +1,247 @@
+package cardnumber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// cardServer lists cards, filtered like the API, and provisions new ones
+type cardServer struct {
+	mu            sync.Mutex
+	cards         []models.Card
+	lookups       int
+	failProvision bool
+}
+
+func (s *cardServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		if query.Get("card_number") != "" {
+			s.lookups++
+		}
+		keys := []models.Card{}
+		for _, card := range s.cards {
+			if n := query.Get("card_number"); n != "" && n != card.CardNumber {
+				continue
+			}
+			if site := query.Get("site_code"); site != "" && site != card.SiteCode {
+				continue
+			}
+			keys = append(keys, card)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+		return
+	}
+
+	if s.failProvision {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"message": "invalid card"}`))
+		return
+	}
+	var params models.ProvisionParams
+	json.NewDecoder(r.Body).Decode(&params)
+	card := models.Card{ID: fmt.Sprintf("card_%d", len(s.cards)+1), CardNumber: params.CardNumber, SiteCode: params.SiteCode, State: "active"}
+	s.cards = append(s.cards, card)
+	json.NewEncoder(w).Encode(card)
+}
+
+func newTestAllocator(t *testing.T, server *cardServer, config Config) *Allocator {
+	t.Helper()
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	c, err := client.NewClient("test-account", "test-secret", client.WithBaseURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Store == nil {
+		config.Store = &MemoryStore{}
+	}
+	a, err := New(services.NewAccessCardsService(c), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAllocate(t *testing.T) {
+	server := &cardServer{cards: []models.Card{
+		{ID: "existing", CardTemplateID: "tmpl_a", SiteCode: "7", CardNumber: "2", State: "active"},
+		{ID: "gone", SiteCode: "7", CardNumber: "3", State: "deleted"},
+	}}
+	a := newTestAllocator(t, server, Config{Ranges: []Range{
+		{TemplateID: "tmpl_a", SiteCode: "7", First: 1, Last: 3},
+		{SiteCode: "9", First: 100, Last: 199},
+	}})
+	ctx := context.Background()
+	if err := a.Reserve(ctx, "tmpl_a", "1", "7"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 is reserved, 2 is on a card and 3 is free because its card is deleted
+	number, site, err := a.Allocate(ctx, "tmpl_a")
+	if err != nil || number != "3" || site != "7" {
+		t.Fatalf("Allocate = %s/%s, %v; want 7/3", site, number, err)
+	}
+	if _, _, err := a.Allocate(ctx, "tmpl_a"); !errors.Is(err, ErrExhausted) {
+		t.Fatalf("err = %v, want ErrExhausted", err)
+	}
+
+	// The card found on 2 was recorded, so it is not looked up again
+	lookups := server.lookups
+	if _, _, err := a.Allocate(ctx, "tmpl_a"); !errors.Is(err, ErrExhausted) {
+		t.Fatal(err)
+	}
+	if server.lookups != lookups {
+		t.Errorf("made %d lookups for recorded numbers", server.lookups-lookups)
+	}
+	reservations, _ := a.Reservations(ctx)
+	if len(reservations) != 3 || reservations[1].CardID != "existing" {
+		t.Errorf("reservations = %+v", reservations)
+	}
+
+	// Other templates use the shared range
+	if number, site, err := a.Allocate(ctx, "tmpl_b"); err != nil || number != "100" || site != "9" {
+		t.Errorf("Allocate(tmpl_b) = %s/%s, %v; want 9/100", site, number, err)
+	}
+
+	// A released number is handed out again
+	if err := a.Release(ctx, "tmpl_a", "3", "7"); err != nil {
+		t.Fatal(err)
+	}
+	if number, _, err := a.Allocate(ctx, "tmpl_a"); err != nil || number != "3" {
+		t.Errorf("Allocate after release = %s, %v; want 3", number, err)
+	}
+}
+
+func TestReserveInUse(t *testing.T) {
+	server := &cardServer{cards: []models.Card{{ID: "existing", SiteCode: "7", CardNumber: "5", State: "active"}}}
+	a := newTestAllocator(t, server, Config{Ranges: []Range{{SiteCode: "7", First: 1, Last: 10}}})
+	ctx := context.Background()
+
+	if err := a.Reserve(ctx, "tmpl", "5", "7"); !errors.Is(err, ErrInUse) {
+		t.Errorf("reserving a carried number: err = %v, want ErrInUse", err)
+	}
+	if err := a.Reserve(ctx, "tmpl", "6", "7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Reserve(ctx, "tmpl", "6", "7"); !errors.Is(err, ErrInUse) {
+		t.Errorf("reserving twice: err = %v, want ErrInUse", err)
+	}
+}
+
+func TestAllocateConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "numbers.json")
+	a := newTestAllocator(t, &cardServer{}, Config{
+		Ranges: []Range{{SiteCode: "1", First: 1, Last: 1000}},
+		Store:  &FileStore{Path: path},
+	})
+
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			number, _, err := a.Allocate(context.Background(), "tmpl")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[number] {
+				t.Errorf("number %s allocated twice", number)
+			}
+			seen[number] = true
+		}()
+	}
+	wg.Wait()
+
+	// The reservations survive a restart
+	b := newTestAllocator(t, &cardServer{}, Config{
+		Ranges: []Range{{SiteCode: "1", First: 1, Last: 1000}},
+		Store:  &FileStore{Path: path},
+	})
+	if number, _, err := b.Allocate(context.Background(), "tmpl"); err != nil || number != "51" {
+		t.Errorf("Allocate after restart = %s, %v; want 51", number, err)
+	}
+}
+
+func TestProvision(t *testing.T) {
+	server := &cardServer{}
+	a := newTestAllocator(t, server, Config{Ranges: []Range{{SiteCode: "7", First: 1, Last: 10}}})
+	ctx := context.Background()
+
+	card, err := a.Provision(ctx, models.ProvisionParams{CardTemplateID: "tmpl", FullName: "Employee name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.CardNumber != "1" || card.SiteCode != "7" {
+		t.Errorf("card = %s/%s, want 7/1", card.SiteCode, card.CardNumber)
+	}
+	reservations, _ := a.Reservations(ctx)
+	if len(reservations) != 1 || reservations[0].CardID != card.ID {
+		t.Errorf("reservations = %+v", reservations)
+	}
+
+	// A failed provision releases its number
+	server.failProvision = true
+	if _, err := a.Provision(ctx, models.ProvisionParams{CardTemplateID: "tmpl"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if reservations, _ := a.Reservations(ctx); len(reservations) != 1 {
+		t.Errorf("reservations after failure = %+v", reservations)
+	}
+
+	// An explicit number already in use is refused
+	server.failProvision = false
+	if _, err := a.Provision(ctx, models.ProvisionParams{CardTemplateID: "tmpl", CardNumber: "1", SiteCode: "7"}); !errors.Is(err, ErrInUse) {
+		t.Errorf("err = %v, want ErrInUse", err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	server := &cardServer{cards: []models.Card{
+		{ID: "a", SiteCode: "07", CardNumber: "0001", State: "active"},
+		{ID: "b", SiteCode: "7", CardNumber: "2", State: "suspended"},
+		{ID: "c", SiteCode: "7", CardNumber: "3", State: "deleted"},
+		{ID: "d", State: "active"},
+	}}
+	a := newTestAllocator(t, server, Config{Ranges: []Range{{SiteCode: "7", First: 1, Last: 10}}})
+	ctx := context.Background()
+
+	if added, err := a.Sync(ctx); err != nil || added != 2 {
+		t.Fatalf("Sync = %d, %v; want 2", added, err)
+	}
+	if added, _ := a.Sync(ctx); added != 0 {
+		t.Errorf("second Sync added %d", added)
+	}
+	if number, _, _ := a.Allocate(ctx, "tmpl"); number != "3" {
+		t.Errorf("Allocate = %s, want 3", number)
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	if _, err := New(nil, Config{}); err == nil {
+		t.Error("expected an error without ranges")
+	}
+	if _, err := New(nil, Config{Ranges: []Range{{First: 10, Last: 1}}}); err == nil {
+		t.Error("expected an error for a backwards range")
+	}
+}
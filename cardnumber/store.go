@@ -0,0 +1,89 @@
+package cardnumber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/internal/atomicfile"
+)
+
+// Reservation is a card number held by the allocator
+type Reservation struct {
+	SiteCode   string `json:"site_code"`
+	CardNumber string `json:"card_number"`
+	TemplateID string `json:"template_id,omitempty"`
+	// CardID is set for numbers found on existing cards
+	CardID     string    `json:"card_id,omitempty"`
+	ReservedAt time.Time `json:"reserved_at"`
+}
+
+// State is what an Allocator persists
+type State struct {
+	Reservations []Reservation `json:"reservations"`
+}
+
+// Store persists the allocator state between runs
+type Store interface {
+	// Load returns the saved state, or nil if there is none
+	Load(ctx context.Context) (*State, error)
+	Save(ctx context.Context, state *State) error
+}
+
+// MemoryStore is a Store that keeps the state in memory
+type MemoryStore struct {
+	mu    sync.Mutex
+	state *State
+}
+
+// Load returns a copy of the saved state
+func (m *MemoryStore) Load(ctx context.Context) (*State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state == nil {
+		return nil, nil
+	}
+	return &State{Reservations: append([]Reservation(nil), m.state.Reservations...)}, nil
+}
+
+// Save keeps a copy of state
+func (m *MemoryStore) Save(ctx context.Context, state *State) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = &State{Reservations: append([]Reservation(nil), state.Reservations...)}
+	return nil
+}
+
+// FileStore keeps the state in a JSON file
+type FileStore struct {
+	Path string
+}
+
+// Load reads the state file. A missing file returns a nil state.
+func (f *FileStore) Load(ctx context.Context) (*State, error) {
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading card numbers: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error parsing card numbers %s: %w", f.Path, err)
+	}
+	return &state, nil
+}
+
+// Save replaces the state file atomically
+func (f *FileStore) Save(ctx context.Context, state *State) error {
+	if err := atomicfile.WriteJSON(f.Path, state); err != nil {
+		return fmt.Errorf("error writing card numbers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package accessgrid
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Access-Grid/accessgrid-go/internal/fetch"
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// CloneTemplateOptions controls how a template is cloned
+type CloneTemplateOptions struct {
+	// Name overrides the name of the cloned template
+	Name string
+	// SkipImages leaves out the background, logo and icon images
+	SkipImages bool
+	// IncludePair also clones the template paired with the source template
+	// and recreates the pass template pair between the two clones
+	IncludePair bool
+}
+
+// CloneTemplateResult holds the resources created by CloneTemplate
+type CloneTemplateResult struct {
+	Template *Template
+	// PairedTemplate and Pair are set when IncludePair is used
+	PairedTemplate *Template
+	Pair           *PassTemplatePair
+}
+
+// CloneTemplate reads a card template from src and creates an equivalent
+// template in dst, for example to promote a template designed in a sandbox
+// account to production. Images are downloaded from src and uploaded to dst.
+func CloneTemplate(ctx context.Context, src, dst *Client, templateID string, opts CloneTemplateOptions) (*CloneTemplateResult, error) {
+	template, err := cloneTemplate(ctx, src, dst, templateID, opts.Name, opts.SkipImages)
+	if err != nil {
+		return nil, err
+	}
+	result := &CloneTemplateResult{Template: template}
+	if !opts.IncludePair {
+		return result, nil
+	}
+
+	pair, err := findPassTemplatePair(ctx, src, templateID)
+	if err != nil {
+		return result, err
+	}
+	if pair.IOSTemplate == nil || pair.AndroidTemplate == nil {
+		return result, fmt.Errorf("pass template pair %s is incomplete", pair.ID)
+	}
+
+	pairedID := pair.AndroidTemplate.ID
+	if pairedID == templateID {
+		pairedID = pair.IOSTemplate.ID
+	}
+	result.PairedTemplate, err = cloneTemplate(ctx, src, dst, pairedID, "", opts.SkipImages)
+	if err != nil {
+		return result, err
+	}
+
+	params := models.CreatePassTemplatePairParams{Name: pair.Name}
+	if pair.IOSTemplate.ID == templateID {
+		params.AppleCardTemplateID = result.Template.ID
+		params.GoogleCardTemplateID = result.PairedTemplate.ID
+	} else {
+		params.AppleCardTemplateID = result.PairedTemplate.ID
+		params.GoogleCardTemplateID = result.Template.ID
+	}
+	result.Pair, err = dst.Console.CreatePassTemplatePair(ctx, params)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func cloneTemplate(ctx context.Context, src, dst *Client, templateID, name string, skipImages bool) (*Template, error) {
+	template, err := src.Console.ReadTemplate(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := createParamsFromTemplate(template)
+	if name != "" {
+		params.Name = name
+	}
+
+	if !skipImages {
+		images := []struct {
+			value string
+			field *string
+		}{
+			{template.Design.BackgroundImage, &params.BackgroundImage},
+			{template.Design.LogoImage, &params.LogoImage},
+			{template.Design.IconImage, &params.IconImage},
+		}
+		// Google templates take their logo in the Logo field
+		if template.Platform != "apple" {
+			images[1].field = &params.Logo
+		}
+		for _, img := range images {
+			if *img.field, err = fetch.Base64(ctx, src.client.HTTPClient, img.value); err != nil {
+				return nil, fmt.Errorf("error copying template image: %w", err)
+			}
+		}
+	}
+
+	return dst.Console.CreateTemplate(ctx, params)
+}
+
+// createParamsFromTemplate maps a template read from the API back to the
+// parameters that create it. Images are left for the caller to fill in.
+func createParamsFromTemplate(t *Template) CreateTemplateParams {
+	return CreateTemplateParams{
+		Name:                   t.Name,
+		Platform:               t.Platform,
+		UseCase:                t.UseCase,
+		Protocol:               t.Protocol,
+		AllowOnMultipleDevices: t.AllowOnMultipleDevices,
+		WatchCount:             t.WatchCount,
+		IPhoneCount:            t.IPhoneCount,
+		BackgroundColor:        t.Design.BackgroundColor,
+		LabelColor:             t.Design.LabelColor,
+		LabelSecondaryColor:    t.Design.LabelSecondaryColor,
+		SupportURL:             t.SupportInfo.SupportURL,
+		SupportPhoneNumber:     t.SupportInfo.SupportPhoneNumber,
+		SupportEmail:           t.SupportInfo.SupportEmail,
+		PrivacyPolicyURL:       t.SupportInfo.PrivacyPolicyURL,
+		TermsAndConditionsURL:  t.SupportInfo.TermsAndConditionsURL,
+	}
+}
+
+// findPassTemplatePair returns the pass template pair containing a template
+func findPassTemplatePair(ctx context.Context, c *Client, templateID string) (*PassTemplatePair, error) {
+	for page := 1; ; page++ {
+		response, err := c.Console.ListPassTemplatePairs(ctx, ListPassTemplatePairsParams{Page: page})
+		if err != nil {
+			return nil, err
+		}
+		for i, pair := range response.PassTemplatePairs {
+			if (pair.IOSTemplate != nil && pair.IOSTemplate.ID == templateID) ||
+				(pair.AndroidTemplate != nil && pair.AndroidTemplate.ID == templateID) {
+				return &response.PassTemplatePairs[i], nil
+			}
+		}
+		if page >= response.Pagination.TotalPages {
+			return nil, fmt.Errorf("no pass template pair contains template %s", templateID)
+		}
+	}
+}
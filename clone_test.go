@@ -0,0 +1,101 @@
+package accessgrid
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCloneTemplate(t *testing.T) {
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/images/logo.png":
+			w.Write([]byte("logo-bytes"))
+		case "/v1/console/card-templates/tmpl_ios":
+			w.Write([]byte(`{
+				"id": "tmpl_ios",
+				"name": "Badge iOS",
+				"platform": "apple",
+				"use_case": "employee_badge",
+				"protocol": "desfire",
+				"allow_on_multiple_devices": true,
+				"watch_count": 2,
+				"iphone_count": 3,
+				"design": {"background_color": "#FFFFFF", "logo_image": "` + "http://" + r.Host + `/images/logo.png"},
+				"support_info": {"support_email": "help@example.com"}
+			}`))
+		case "/v1/console/card-templates/tmpl_android":
+			w.Write([]byte(`{"id": "tmpl_android", "name": "Badge Android", "platform": "android", "protocol": "desfire"}`))
+		case "/v1/console/card-template-pairs":
+			w.Write([]byte(`{
+				"card_template_pairs": [{
+					"id": "pair_1",
+					"name": "Badge",
+					"ios_template": {"id": "tmpl_ios"},
+					"android_template": {"id": "tmpl_android"}
+				}],
+				"pagination": {"current_page": 1, "total_pages": 1}
+			}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer src.Close()
+
+	var created []map[string]interface{}
+	var pairBody map[string]interface{}
+	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/console/card-templates":
+			var params map[string]interface{}
+			json.Unmarshal(body, &params)
+			created = append(created, params)
+			if params["platform"] == "apple" {
+				w.Write([]byte(`{"id": "prod_ios", "name": "Badge iOS", "platform": "apple"}`))
+			} else {
+				w.Write([]byte(`{"id": "prod_android", "name": "Badge Android", "platform": "android"}`))
+			}
+		case "/v1/console/card-template-pairs":
+			json.Unmarshal(body, &pairBody)
+			w.Write([]byte(`{"id": "prod_pair", "name": "Badge"}`))
+		}
+	}))
+	defer dst.Close()
+
+	srcClient, _ := NewClient("sandbox", "secret", WithBaseURL(src.URL))
+	dstClient, _ := NewClient("production", "secret", WithBaseURL(dst.URL))
+
+	result, err := CloneTemplate(context.Background(), srcClient, dstClient, "tmpl_ios", CloneTemplateOptions{IncludePair: true})
+	if err != nil {
+		t.Fatalf("CloneTemplate() error = %v", err)
+	}
+
+	if result.Template.ID != "prod_ios" || result.PairedTemplate.ID != "prod_android" || result.Pair.ID != "prod_pair" {
+		t.Errorf("CloneTemplate() result = %+v", result)
+	}
+	if len(created) != 2 {
+		t.Fatalf("created %d templates, want 2", len(created))
+	}
+
+	ios := created[0]
+	if ios["watch_count"] != float64(2) || ios["allow_on_multiple_devices"] != true {
+		t.Errorf("cloned template lost settings: %v", ios)
+	}
+	if ios["background_color"] != "#FFFFFF" || ios["support_email"] != "help@example.com" {
+		t.Errorf("cloned template lost design or support info: %v", ios)
+	}
+	if ios["logo_image"] != base64.StdEncoding.EncodeToString([]byte("logo-bytes")) {
+		t.Errorf("logo_image = %v, want downloaded image in base64", ios["logo_image"])
+	}
+
+	if pairBody["apple_card_template_id"] != "prod_ios" || pairBody["google_card_template_id"] != "prod_android" {
+		t.Errorf("pair created with %v", pairBody)
+	}
+}
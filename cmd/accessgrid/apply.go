@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Access-Grid/accessgrid-go/apply"
+)
+
+// runApply implements the plan and apply commands
+func runApply(args []string, execute bool) error {
+	name := "plan"
+	if execute {
+		name = "apply"
+	}
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	specPath := flags.String("spec", "accessgrid.json", "path to the JSON or YAML spec file")
+	statePath := flags.String("state", "accessgrid.state.json", "path to the state file")
+	baseURL := flags.String("base-url", "", "custom API base URL")
+	flags.Parse(args)
+
+	spec, err := apply.LoadSpec(*specPath)
+	if err != nil {
+		return err
+	}
+	state, err := apply.LoadState(*statePath)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(*baseURL)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	reconciler := apply.NewReconciler(c.Console)
+	plan, err := reconciler.Plan(ctx, spec, state)
+	if err != nil {
+		return err
+	}
+	if err := plan.Write(os.Stdout); err != nil {
+		return err
+	}
+	if !execute || len(plan.Changes) == 0 {
+		return nil
+	}
+
+	applyErr := reconciler.Apply(ctx, plan, state)
+	if err := state.Save(*statePath); err != nil {
+		return err
+	}
+	if applyErr != nil {
+		return applyErr
+	}
+	fmt.Println("Apply complete.")
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/export"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// runExport implements the export command
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", string(export.FormatJSONLines), "output format: jsonl or csv")
+	outPath := flags.String("out", "", "file to append events to (default stdout)")
+	cursorPath := flags.String("cursor", "", "file holding the export watermark, for incremental exports")
+	templates := flags.String("templates", "", "comma separated template IDs (default all templates)")
+	since := flags.String("since", "", "RFC 3339 time to export from when there is no cursor")
+	eventType := flags.String("event-type", "", "only export events of this type")
+	baseURL := flags.String("base-url", "", "custom API base URL")
+	flags.Parse(args)
+
+	filters := models.AccountEventLogFilters{
+		EventLogFilters: models.EventLogFilters{EventType: *eventType},
+	}
+	if *templates != "" {
+		filters.TemplateIDs = strings.Split(*templates, ",")
+	}
+	if *since != "" {
+		start, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+		filters.StartDate = &start
+	}
+
+	c, err := newClient(*baseURL)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	header := true
+	if *outPath != "" {
+		file, err := os.OpenFile(*outPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if info, err := file.Stat(); err == nil && info.Size() > 0 {
+			header = false
+		}
+		out = file
+	}
+
+	writer, err := export.NewWriter(out, export.Format(*format), header)
+	if err != nil {
+		return err
+	}
+	exporter := &export.Exporter{Console: c.Console, Filters: filters}
+	if *cursorPath != "" {
+		exporter.CursorStore = services.FileCursorStore{Path: *cursorPath}
+	}
+
+	n, err := exporter.Export(context.Background(), writer)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d events.\n", n)
+	return nil
+}
@@ -0,0 +1,64 @@
+// Command accessgrid manages AccessGrid console resources and exports event
+// logs from the command line.
+//
+// Credentials are read from the ACCOUNT_ID and SECRET_KEY environment variables.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Access-Grid/accessgrid-go"
+	"github.com/Access-Grid/accessgrid-go/client"
+)
+
+const usage = `Usage: accessgrid <command> [flags]
+
+Commands:
+  plan     show the changes needed to reconcile the account with a spec
+  apply    reconcile the account with a spec
+  export   write event logs as JSON Lines or CSV
+
+Run "accessgrid <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "plan":
+		err = runApply(os.Args[2:], false)
+	case "apply":
+		err = runApply(os.Args[2:], true)
+	case "export":
+		err = runExport(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "accessgrid: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newClient creates an API client from the environment
+func newClient(baseURL string) (*accessgrid.Client, error) {
+	accountID := os.Getenv("ACCOUNT_ID")
+	secretKey := os.Getenv("SECRET_KEY")
+	if accountID == "" || secretKey == "" {
+		return nil, errors.New("ACCOUNT_ID and SECRET_KEY must be set")
+	}
+
+	var options []client.Option
+	if baseURL != "" {
+		options = append(options, accessgrid.WithBaseURL(baseURL))
+	}
+	return accessgrid.NewClient(accountID, secretKey, options...)
+}
@@ -0,0 +1,217 @@
+// Package delivery sends install links to pass holders by email and SMS.
+//
+// A Deliverer renders a provisioned card into a message per channel with
+// Go templates, sends it with the configured Senders, retries failed sends
+// and records every delivery in a Tracker. SMTPSender sends email with
+// net/smtp and SMSGateway posts text messages to an HTTP gateway.
+package delivery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// ErrNoRecipient is returned when a card has no email address or phone
+// number for any configured sender
+var ErrNoRecipient = errors.New("no recipient for any sender")
+
+// Channel is a delivery medium
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+)
+
+// Message is a rendered install link message
+type Message struct {
+	// To is an email address or a phone number
+	To string
+	// Subject and HTML are only used by email. HTML is optional.
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender sends messages on one channel
+type Sender interface {
+	Channel() Channel
+	Send(ctx context.Context, msg Message) error
+}
+
+// Status is the outcome of a delivery
+type Status string
+
+const (
+	StatusSent   Status = "sent"
+	StatusFailed Status = "failed"
+)
+
+// Delivery records one install link sent, or failed to send, to a holder
+type Delivery struct {
+	ID       string    `json:"id"`
+	CardID   string    `json:"card_id"`
+	Channel  Channel   `json:"channel"`
+	To       string    `json:"to"`
+	Status   Status    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Attempts int       `json:"attempts"`
+	At       time.Time `json:"at"`
+}
+
+// Config configures a Deliverer
+type Config struct {
+	// Senders are each used for cards with a recipient on their channel
+	Senders []Sender
+	// Templates defaults to DefaultTemplates
+	Templates *Templates
+	// Tracker records deliveries. Defaults to a MemoryTracker.
+	Tracker Tracker
+	// Attempts is how many times a send is tried. Defaults to 3.
+	Attempts int
+	// RetryInterval is the delay after the first failed send, doubled on
+	// each retry. Defaults to 2 seconds.
+	RetryInterval time.Duration
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Deliverer sends install links
+type Deliverer struct {
+	config Config
+}
+
+// New creates a deliverer
+func New(config Config) (*Deliverer, error) {
+	if len(config.Senders) == 0 {
+		return nil, errors.New("at least one sender is required")
+	}
+	if config.Templates == nil {
+		config.Templates = DefaultTemplates()
+	}
+	if config.Tracker == nil {
+		config.Tracker = &MemoryTracker{}
+	}
+	if config.Attempts <= 0 {
+		config.Attempts = 3
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = 2 * time.Second
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Deliverer{config: config}, nil
+}
+
+// Deliver sends a card's install link with every sender that has a
+// recipient on the card: its email address for email and its phone number
+// for SMS. It returns the deliveries made, and the failures joined in one
+// error.
+func (d *Deliverer) Deliver(ctx context.Context, card *models.CardProvisionResponse) ([]Delivery, error) {
+	data := Data{CardProvisionResponse: *card, InstallURL: card.URL}
+	if data.InstallURL == "" {
+		data.InstallURL = card.DirectInstallUrl
+	}
+	if data.InstallURL == "" {
+		return nil, fmt.Errorf("card %s has no install link", card.ID)
+	}
+
+	var deliveries []Delivery
+	var errs []error
+	for _, sender := range d.config.Senders {
+		to := recipient(card, sender.Channel())
+		if to == "" {
+			continue
+		}
+		msg, err := d.config.Templates.Render(sender.Channel(), data)
+		if err != nil {
+			return deliveries, err
+		}
+		msg.To = to
+
+		delivery := d.send(ctx, sender, msg)
+		delivery.CardID = card.ID
+		if err := d.config.Tracker.Record(ctx, delivery); err != nil {
+			errs = append(errs, err)
+		}
+		if delivery.Status == StatusFailed {
+			errs = append(errs, fmt.Errorf("error sending %s to %s: %s", sender.Channel(), to, delivery.Error))
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("card %s: %w", card.ID, ErrNoRecipient)
+	}
+	return deliveries, errors.Join(errs...)
+}
+
+// Deliveries returns the recorded deliveries of a card, or of every card
+// when cardID is empty
+func (d *Deliverer) Deliveries(ctx context.Context, cardID string) ([]Delivery, error) {
+	return d.config.Tracker.Deliveries(ctx, cardID)
+}
+
+// send sends a message, retrying failures with backoff
+func (d *Deliverer) send(ctx context.Context, sender Sender, msg Message) Delivery {
+	delivery := Delivery{ID: newID(), Channel: sender.Channel(), To: msg.To}
+	wait := d.config.RetryInterval
+	var err error
+	for delivery.Attempts < d.config.Attempts {
+		if delivery.Attempts > 0 {
+			if err = sleep(ctx, wait); err != nil {
+				break
+			}
+			wait *= 2
+		}
+		delivery.Attempts++
+		if err = sender.Send(ctx, msg); err == nil {
+			break
+		}
+	}
+
+	delivery.At = d.config.Now().UTC()
+	delivery.Status = StatusSent
+	if err != nil {
+		delivery.Status = StatusFailed
+		delivery.Error = err.Error()
+	}
+	return delivery
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// recipient returns a card's address on a channel
+func recipient(card *models.CardProvisionResponse, channel Channel) string {
+	switch channel {
+	case ChannelEmail:
+		return card.Email
+	case ChannelSMS:
+		return card.PhoneNumber
+	default:
+		return ""
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,423 @@
+package delivery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// smtpSink is a local SMTP server that keeps the messages it receives
+type smtpSink struct {
+	addr string
+
+	mu       sync.Mutex
+	messages []sunkMessage
+	auth     []string
+	// rejectRcpt refuses every recipient
+	rejectRcpt bool
+}
+
+type sunkMessage struct {
+	from, to string
+	data     []byte
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	sink := &smtpSink{addr: ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go sink.serve(conn)
+		}
+	}()
+	return sink
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 sink ESMTP")
+
+	var msg sunkMessage
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			tp.PrintfLine("250-sink")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			s.mu.Lock()
+			s.auth = append(s.auth, arg)
+			s.mu.Unlock()
+			tp.PrintfLine("235 2.7.0 Authenticated")
+		case "MAIL":
+			msg = sunkMessage{from: arg}
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.mu.Lock()
+			reject := s.rejectRcpt
+			s.mu.Unlock()
+			if reject {
+				tp.PrintfLine("550 No such user")
+				continue
+			}
+			msg.to = arg
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = data
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case "RSET", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func (s *smtpSink) received() []sunkMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sunkMessage(nil), s.messages...)
+}
+
+// parts reads a message and returns its subject and its body by content
+// type
+func parts(t *testing.T, data []byte) (string, map[string]string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := map[string]string{}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %s", mediaType)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part)
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[partType] = string(body)
+	}
+	return subject, bodies
+}
+
+func TestSMTPSender(t *testing.T) {
+	sink := newSMTPSink(t)
+	sender, err := NewSMTPSender(SMTPConfig{
+		Addr: sink.addr,
+		From: "Access Team <access@example.com>",
+		Auth: smtp.PlainAuth("", "user", "secret", "127.0.0.1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sender.Send(context.Background(), Message{
+		To:      "Zoë Example <zoe@example.com>",
+		Subject: "Your pass — ready",
+		Text:    "Open https://accessgrid.com/install/0xc4rd1d to add it.",
+		HTML:    `<p><a href="https://accessgrid.com/install/0xc4rd1d">Add to wallet</a></p>`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := sink.received()
+	if len(messages) != 1 {
+		t.Fatalf("received %d messages", len(messages))
+	}
+	if messages[0].from != "FROM:<access@example.com>" || messages[0].to != "TO:<zoe@example.com>" {
+		t.Errorf("envelope = %s, %s", messages[0].from, messages[0].to)
+	}
+	if want := "PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00user\x00secret")); len(sink.auth) != 1 || sink.auth[0] != want {
+		t.Errorf("auth = %v", sink.auth)
+	}
+
+	subject, bodies := parts(t, messages[0].data)
+	if subject != "Your pass — ready" {
+		t.Errorf("subject = %q", subject)
+	}
+	if !strings.Contains(bodies["text/plain"], "https://accessgrid.com/install/0xc4rd1d") {
+		t.Errorf("text = %q", bodies["text/plain"])
+	}
+	if !strings.Contains(bodies["text/html"], `href="https://accessgrid.com/install/0xc4rd1d"`) {
+		t.Errorf("html = %q", bodies["text/html"])
+	}
+}
+
+func TestSMTPSenderRejected(t *testing.T) {
+	sink := newSMTPSink(t)
+	sink.rejectRcpt = true
+	sender, err := NewSMTPSender(SMTPConfig{Addr: sink.addr, From: "access@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sender.Send(context.Background(), Message{To: "nobody@example.com", Subject: "s", Text: "t"})
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("err = %v, want the 550 reply", err)
+	}
+	if _, err := NewSMTPSender(SMTPConfig{Addr: sink.addr, From: "not an address"}); err == nil {
+		t.Error("expected an error for an invalid from address")
+	}
+}
+
+func TestSMTPSenderRequiresTLS(t *testing.T) {
+	sink := newSMTPSink(t)
+	requireTLS := true
+	sender, err := NewSMTPSender(SMTPConfig{Addr: sink.addr, From: "access@example.com", RequireTLS: &requireTLS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sender.Send(context.Background(), Message{To: "zoe@example.com", Subject: "s", Text: "https://accessgrid.com/install/0xc4rd1d"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("err = %v, want a STARTTLS error", err)
+	}
+	if len(sink.received()) != 0 {
+		t.Error("a message was sent without TLS")
+	}
+
+	// Remote hosts require TLS by default
+	remote, err := NewSMTPSender(SMTPConfig{Addr: "smtp.example.com:587", From: "access@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !remote.requireTLS {
+		t.Error("RequireTLS should default to true for remote hosts")
+	}
+}
+
+func TestSMSGateway(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		if strings.Contains(string(body), "invalid") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid number"}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	// JSON with the default fields
+	gateway, err := NewSMSGateway(SMSGatewayConfig{URL: server.URL, Header: http.Header{"Authorization": {"Bearer token"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.Send(ctx, Message{To: "+15555550100", Text: "Your pass: https://accessgrid.com/install/0xc4rd1d"}); err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]string
+	json.Unmarshal([]byte(bodies[0]), &payload)
+	if payload["to"] != "+15555550100" || !strings.HasPrefix(payload["text"], "Your pass") {
+		t.Errorf("payload = %v", payload)
+	}
+	if requests[0].Header.Get("Authorization") != "Bearer token" || requests[0].Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", requests[0].Header)
+	}
+
+	// Form encoded with gateway-specific fields
+	gateway, _ = NewSMSGateway(SMSGatewayConfig{URL: server.URL, ToField: "To", TextField: "Body", Fields: map[string]string{"From": "+15555550199"}, Form: true})
+	if err := gateway.Send(ctx, Message{To: "+15555550100", Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if bodies[1] != "Body=hello&From=%2B15555550199&To=%2B15555550100" {
+		t.Errorf("form = %s", bodies[1])
+	}
+
+	err = gateway.Send(ctx, Message{To: "invalid", Text: "hello"})
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "invalid number") {
+		t.Errorf("err = %v, want the gateway's 400", err)
+	}
+}
+
+// flakySender fails a number of times before succeeding
+type flakySender struct {
+	channel  Channel
+	failures int
+	sent     []Message
+}
+
+func (f *flakySender) Channel() Channel { return f.channel }
+
+func (f *flakySender) Send(ctx context.Context, msg Message) error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("gateway unavailable")
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func testCard() *models.CardProvisionResponse {
+	return &models.CardProvisionResponse{
+		ID:             "0xc4rd1d",
+		FullName:       "Ada <Lovelace>",
+		Email:          "ada@example.com",
+		PhoneNumber:    "+15555550100",
+		URL:            "https://accessgrid.com/install/0xc4rd1d",
+		ExpirationDate: time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	sink := newSMTPSink(t)
+	email, err := NewSMTPSender(SMTPConfig{Addr: sink.addr, From: "access@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sms := &flakySender{channel: ChannelSMS}
+	tracker := &FileTracker{Path: filepath.Join(t.TempDir(), "deliveries.jsonl")}
+	d, err := New(Config{Senders: []Sender{email, sms}, Tracker: tracker})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := d.Deliver(context.Background(), testCard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 2 || deliveries[0].Status != StatusSent || deliveries[1].Channel != ChannelSMS {
+		t.Errorf("deliveries = %+v", deliveries)
+	}
+
+	subject, bodies := parts(t, sink.received()[0].data)
+	if subject != "Your access pass is ready" {
+		t.Errorf("subject = %q", subject)
+	}
+	if text := bodies["text/plain"]; !strings.Contains(text, "Hi Ada <Lovelace>,") || !strings.Contains(text, "valid until March 1, 2027") {
+		t.Errorf("text = %q", text)
+	}
+	if html := bodies["text/html"]; !strings.Contains(html, "Hi Ada &lt;Lovelace&gt;,") {
+		t.Errorf("html is not escaped: %q", html)
+	}
+	if len(sms.sent) != 1 || sms.sent[0].To != "+15555550100" || sms.sent[0].Text != "Your access pass is ready: https://accessgrid.com/install/0xc4rd1d" {
+		t.Errorf("sms = %+v", sms.sent)
+	}
+
+	recorded, err := d.Deliveries(context.Background(), "0xc4rd1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 2 || recorded[0].To != "ada@example.com" || recorded[0].CardID != "0xc4rd1d" {
+		t.Errorf("recorded = %+v", recorded)
+	}
+	if other, _ := d.Deliveries(context.Background(), "0xother"); len(other) != 0 {
+		t.Errorf("deliveries of another card = %+v", other)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	sms := &flakySender{channel: ChannelSMS, failures: 2}
+	d, err := New(Config{Senders: []Sender{sms}, RetryInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := d.Deliver(context.Background(), testCard())
+	if err != nil || deliveries[0].Attempts != 3 || deliveries[0].Status != StatusSent {
+		t.Fatalf("deliveries = %+v, err = %v", deliveries, err)
+	}
+
+	sms.failures = 5
+	deliveries, err = d.Deliver(context.Background(), testCard())
+	if err == nil || deliveries[0].Status != StatusFailed || deliveries[0].Error != "gateway unavailable" {
+		t.Errorf("deliveries = %+v, err = %v", deliveries, err)
+	}
+	recorded, _ := d.Deliveries(context.Background(), "")
+	if len(recorded) != 2 || recorded[1].Status != StatusFailed {
+		t.Errorf("recorded = %+v", recorded)
+	}
+}
+
+func TestDeliverNoRecipient(t *testing.T) {
+	d, _ := New(Config{Senders: []Sender{&flakySender{channel: ChannelSMS}}})
+	card := testCard()
+	card.PhoneNumber = ""
+	if _, err := d.Deliver(context.Background(), card); !errors.Is(err, ErrNoRecipient) {
+		t.Errorf("err = %v, want ErrNoRecipient", err)
+	}
+	card.URL = ""
+	if _, err := d.Deliver(context.Background(), card); err == nil {
+		t.Error("expected an error for a card without an install link")
+	}
+}
+
+func TestParseTemplates(t *testing.T) {
+	templates, err := ParseTemplates("Pass for {{.FullName}}\n", "{{.InstallURL}}", "", "{{.FullName}}: {{.InstallURL}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := Data{CardProvisionResponse: *testCard(), InstallURL: "https://example.com/i"}
+	msg, err := templates.Render(ChannelEmail, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Pass for Ada <Lovelace>" || msg.HTML != "" {
+		t.Errorf("email = %+v", msg)
+	}
+	if _, err := ParseTemplates("{{.FullName", "t", "", "s"); err == nil {
+		t.Error("expected a parse error")
+	}
+}
@@ -0,0 +1,116 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SMSGatewayConfig configures an SMSGateway
+type SMSGatewayConfig struct {
+	// URL receives a POST per message
+	URL string
+	// Header is added to each request, such as an Authorization header
+	Header http.Header
+	// ToField and TextField name the recipient and text fields of the
+	// request. Default to "to" and "text".
+	ToField   string
+	TextField string
+	// Fields are sent with every message, such as the sending number
+	Fields map[string]string
+	// Form sends the fields form encoded instead of as a JSON object
+	Form bool
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// SMSGateway sends text messages through an HTTP gateway. Most gateways
+// take a POST with the recipient, the text and a few fixed fields, as JSON
+// or form encoded, which the config describes.
+type SMSGateway struct {
+	config SMSGatewayConfig
+}
+
+var _ Sender = (*SMSGateway)(nil)
+
+// NewSMSGateway creates a text message sender
+func NewSMSGateway(config SMSGatewayConfig) (*SMSGateway, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid gateway URL %q", config.URL)
+	}
+	if config.ToField == "" {
+		config.ToField = "to"
+	}
+	if config.TextField == "" {
+		config.TextField = "text"
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &SMSGateway{config: config}, nil
+}
+
+// Channel returns ChannelSMS
+func (g *SMSGateway) Channel() Channel {
+	return ChannelSMS
+}
+
+// Send posts a message to the gateway. Any status other than 2xx is an
+// error.
+func (g *SMSGateway) Send(ctx context.Context, msg Message) error {
+	if msg.To == "" {
+		return errors.New("phone number is required")
+	}
+
+	fields := make(map[string]string, len(g.config.Fields)+2)
+	for key, value := range g.config.Fields {
+		fields[key] = value
+	}
+	fields[g.config.ToField] = msg.To
+	fields[g.config.TextField] = msg.Text
+
+	var body []byte
+	var contentType string
+	if g.config.Form {
+		form := url.Values{}
+		for key, value := range fields {
+			form.Set(key, value)
+		}
+		body, contentType = []byte(form.Encode()), "application/x-www-form-urlencoded"
+	} else {
+		var err error
+		if body, err = json.Marshal(fields); err != nil {
+			return fmt.Errorf("error encoding text message: %w", err)
+		}
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating gateway request: %w", err)
+	}
+	for key, values := range g.config.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := g.config.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending text message to %s: %w", msg.To, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("error sending text message to %s: gateway returned %d: %s", msg.To, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
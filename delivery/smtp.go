@@ -0,0 +1,232 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// SMTPConfig configures an SMTPSender
+type SMTPConfig struct {
+	// Addr is the server's host:port
+	Addr string
+	// From is the sender, optionally with a name such as
+	// "Access Team <access@example.com>"
+	From string
+	// Auth is used when set. smtp.PlainAuth only sends credentials over
+	// TLS or to localhost.
+	Auth smtp.Auth
+	// TLSConfig is used for STARTTLS and ImplicitTLS. Defaults to
+	// verifying the host of Addr.
+	TLSConfig *tls.Config
+	// ImplicitTLS connects over TLS from the start, as on port 465.
+	// Otherwise STARTTLS is used whenever the server offers it.
+	ImplicitTLS bool
+	// RequireTLS fails a send when the server does not offer STARTTLS,
+	// rather than sending the install link, which installs the pass for
+	// whoever opens it, in cleartext. Defaults to true unless the host is
+	// localhost or a loopback address.
+	RequireTLS *bool
+	// LocalName is sent with EHLO. Defaults to "localhost".
+	LocalName string
+	// Timeout bounds a whole send. Defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+// SMTPSender sends email with net/smtp
+type SMTPSender struct {
+	config     SMTPConfig
+	host       string
+	from       *mail.Address
+	requireTLS bool
+}
+
+var _ Sender = (*SMTPSender)(nil)
+
+// NewSMTPSender creates an email sender. The server is dialed for each
+// message.
+func NewSMTPSender(config SMTPConfig) (*SMTPSender, error) {
+	host, _, err := net.SplitHostPort(config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %q: %w", config.Addr, err)
+	}
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", config.From, err)
+	}
+	if config.TLSConfig == nil {
+		config.TLSConfig = &tls.Config{ServerName: host}
+	}
+	if config.LocalName == "" {
+		config.LocalName = "localhost"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	requireTLS := !isLoopback(host)
+	if config.RequireTLS != nil {
+		requireTLS = *config.RequireTLS
+	}
+	return &SMTPSender{config: config, host: host, from: from, requireTLS: requireTLS}, nil
+}
+
+// isLoopback reports whether a host is this machine, where a relay
+// without TLS does not expose messages to the network
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Channel returns ChannelEmail
+func (s *SMTPSender) Channel() Channel {
+	return ChannelEmail
+}
+
+// Send delivers a message to one recipient
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid email address %q: %w", msg.To, err)
+	}
+	data, err := s.compose(to, msg)
+	if err != nil {
+		return err
+	}
+
+	if err := s.send(ctx, to, data); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("error sending email to %s: %w", to.Address, err)
+	}
+	return nil
+}
+
+func (s *SMTPSender) send(ctx context.Context, to *mail.Address, data []byte) error {
+	dialer := &net.Dialer{Timeout: s.config.Timeout}
+	var conn net.Conn
+	var err error
+	if s.config.ImplicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.config.TLSConfig}).DialContext(ctx, "tcp", s.config.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.config.Addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(s.config.Timeout))
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if err := c.Hello(s.config.LocalName); err != nil {
+		return err
+	}
+	if !s.config.ImplicitTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(s.config.TLSConfig); err != nil {
+				return err
+			}
+		} else if s.requireTLS {
+			return errors.New("server does not support STARTTLS and RequireTLS is set")
+		}
+	}
+	if s.config.Auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("server does not support AUTH")
+		}
+		if err := c.Auth(s.config.Auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// compose builds a MIME message: text only, or text and HTML alternatives
+func (s *SMTPSender) compose(to *mail.Address, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	domain := s.from.Address[strings.LastIndex(s.from.Address, "@")+1:]
+	fmt.Fprintf(&buf, "From: %s\r\n", s.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", newID(), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
@@ -0,0 +1,131 @@
+package delivery
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// Data is what message templates are executed with. Card fields are
+// promoted, so templates can use {{.FullName}} or {{.ExpirationDate}}.
+type Data struct {
+	models.CardProvisionResponse
+	// InstallURL is the card's install link, or its direct install link
+	// when it has no other
+	InstallURL string
+}
+
+// Templates render install link messages
+type Templates struct {
+	// Subject, Text and HTML make up the email. HTML is optional.
+	Subject *texttemplate.Template
+	Text    *texttemplate.Template
+	HTML    *htmltemplate.Template
+	// SMS is the text message
+	SMS *texttemplate.Template
+}
+
+const (
+	defaultSubject = `Your access pass is ready`
+	defaultText    = `Hi {{.FullName}},
+
+Your access pass is ready. Open this link on your phone to add it to your wallet:
+
+{{.InstallURL}}
+{{if not .ExpirationDate.IsZero}}
+The pass is valid until {{.ExpirationDate.Format "January 2, 2006"}}.
+{{end}}`
+	defaultHTML = `<p>Hi {{.FullName}},</p>
+<p>Your access pass is ready. Open this link on your phone to add it to your wallet:</p>
+<p><a href="{{.InstallURL}}">Add to wallet</a></p>
+{{- if not .ExpirationDate.IsZero}}
+<p>The pass is valid until {{.ExpirationDate.Format "January 2, 2006"}}.</p>
+{{- end}}
+`
+	defaultSMS = `Your access pass is ready: {{.InstallURL}}`
+)
+
+// DefaultTemplates returns plain English messages with the holder's name,
+// the install link and the expiration date
+func DefaultTemplates() *Templates {
+	t, err := ParseTemplates(defaultSubject, defaultText, defaultHTML, defaultSMS)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// ParseTemplates parses message templates. html may be empty for text
+// only email.
+func ParseTemplates(subject, text, html, sms string) (*Templates, error) {
+	if subject == "" || text == "" || sms == "" {
+		return nil, errors.New("subject, text and sms templates are required")
+	}
+
+	t := &Templates{}
+	var err error
+	if t.Subject, err = texttemplate.New("subject").Parse(subject); err != nil {
+		return nil, fmt.Errorf("error parsing subject template: %w", err)
+	}
+	if t.Text, err = texttemplate.New("text").Parse(text); err != nil {
+		return nil, fmt.Errorf("error parsing text template: %w", err)
+	}
+	if html != "" {
+		if t.HTML, err = htmltemplate.New("html").Parse(html); err != nil {
+			return nil, fmt.Errorf("error parsing html template: %w", err)
+		}
+	}
+	if t.SMS, err = texttemplate.New("sms").Parse(sms); err != nil {
+		return nil, fmt.Errorf("error parsing sms template: %w", err)
+	}
+	return t, nil
+}
+
+// Render executes the templates of a channel. The returned message has no
+// recipient.
+func (t *Templates) Render(channel Channel, data Data) (Message, error) {
+	var msg Message
+	var err error
+	switch channel {
+	case ChannelEmail:
+		if msg.Subject, err = execute(t.Subject, data); err != nil {
+			return msg, err
+		}
+		// Subjects are a single line
+		msg.Subject = strings.Join(strings.Fields(msg.Subject), " ")
+		if msg.Text, err = execute(t.Text, data); err != nil {
+			return msg, err
+		}
+		if t.HTML != nil {
+			var buf bytes.Buffer
+			if err := t.HTML.Execute(&buf, data); err != nil {
+				return msg, fmt.Errorf("error rendering html template: %w", err)
+			}
+			msg.HTML = buf.String()
+		}
+	case ChannelSMS:
+		if msg.Text, err = execute(t.SMS, data); err != nil {
+			return msg, err
+		}
+		msg.Text = strings.TrimSpace(msg.Text)
+	default:
+		return msg, fmt.Errorf("unknown channel %q", channel)
+	}
+	return msg, nil
+}
+
+func execute(t *texttemplate.Template, data Data) (string, error) {
+	if t == nil {
+		return "", errors.New("missing template")
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error rendering %s template: %w", t.Name(), err)
+	}
+	return buf.String(), nil
+}
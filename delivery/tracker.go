@@ -0,0 +1,110 @@
+package delivery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Tracker records deliveries
+type Tracker interface {
+	Record(ctx context.Context, delivery Delivery) error
+	// Deliveries returns the deliveries of a card, oldest first, or of
+	// every card when cardID is empty
+	Deliveries(ctx context.Context, cardID string) ([]Delivery, error)
+}
+
+// MemoryTracker is a Tracker that keeps deliveries in memory
+type MemoryTracker struct {
+	mu         sync.Mutex
+	deliveries []Delivery
+}
+
+// Record adds a delivery
+func (m *MemoryTracker) Record(ctx context.Context, delivery Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, delivery)
+	return nil
+}
+
+// Deliveries returns the recorded deliveries of a card
+func (m *MemoryTracker) Deliveries(ctx context.Context, cardID string) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return filterDeliveries(m.deliveries, cardID), nil
+}
+
+// FileTracker appends deliveries to a file, one JSON object per line
+type FileTracker struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Record appends a delivery to the file
+func (f *FileTracker) Record(ctx context.Context, delivery Delivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("error encoding delivery: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error recording delivery: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("error recording delivery: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error recording delivery: %w", err)
+	}
+	return nil
+}
+
+// Deliveries reads the file. A missing file has no deliveries.
+func (f *FileTracker) Deliveries(ctx context.Context, cardID string) ([]Delivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.Open(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading deliveries: %w", err)
+	}
+	defer file.Close()
+
+	var deliveries []Delivery
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var delivery Delivery
+		if err := json.Unmarshal(scanner.Bytes(), &delivery); err != nil {
+			return nil, fmt.Errorf("error parsing deliveries %s line %d: %w", f.Path, line, err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading deliveries: %w", err)
+	}
+	return filterDeliveries(deliveries, cardID), nil
+}
+
+func filterDeliveries(deliveries []Delivery, cardID string) []Delivery {
+	var matched []Delivery
+	for _, d := range deliveries {
+		if cardID == "" || d.CardID == cardID {
+			matched = append(matched, d)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,150 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+func decodeEvent(t *testing.T, data string) models.NormalizedEvent {
+	t.Helper()
+	var event models.NormalizedEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestWriter_JSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatJSONLines, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := decodeEvent(t, `{"id": 5, "type": "suspend", "timestamp": "2026-05-01T14:00:00+02:00", "details": "by admin", "region": "eu"}`)
+	if err := w.Write(event); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	w.Flush()
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("output is not JSON: %q", buf.String())
+	}
+	if record["id"] != "5" || record["timestamp"] != "2026-05-01T12:00:00Z" || record["details"] != "by admin" {
+		t.Errorf("record = %v", record)
+	}
+	if extra, _ := record["extra"].(map[string]interface{}); extra["region"] != "eu" {
+		t.Errorf("extra = %v, want region preserved", record["extra"])
+	}
+}
+
+func TestWriter_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, FormatCSV, true)
+
+	w.Write(decodeEvent(t, `{"id": "evt_1", "type": "install", "card_id": "0xc4rd1d", "timestamp": "2026-05-01T12:00:00Z", "details": {"platform": "apple"}, "metadata": {"site": "hq"}}`))
+	w.Write(decodeEvent(t, `{"id": "evt_2", "type": "resume", "timestamp": "2026-05-01T13:00:00Z", "region": "eu"}`))
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "id" {
+		t.Fatalf("rows = %v, want header and 2 events", rows)
+	}
+	if rows[1][5] != "0xc4rd1d" || rows[1][10] != `{"platform": "apple"}` || rows[1][11] != `{"site":"hq"}` || rows[1][12] != "" {
+		t.Errorf("row = %v", rows[1])
+	}
+	if rows[0][12] != "extra" || rows[2][12] != `{"region":"eu"}` {
+		t.Errorf("extra column = %q, %q", rows[0][12], rows[2][12])
+	}
+
+	buf.Reset()
+	w, _ = NewWriter(&buf, FormatCSV, false)
+	w.Write(decodeEvent(t, `{"id": "evt_3"}`))
+	w.Flush()
+	if strings.HasPrefix(buf.String(), "id,") {
+		t.Error("header written when appending")
+	}
+
+	if _, err := NewWriter(&buf, "xml", true); err == nil {
+		t.Error("expected error for unknown format, got nil")
+	}
+}
+
+// logServer serves one template whose log grows between exports
+type logServer struct {
+	mu   sync.Mutex
+	logs string
+}
+
+func (l *logServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"logs": [` + l.logs + `]}`))
+}
+
+func TestExporter_Incremental(t *testing.T) {
+	logs := &logServer{logs: `
+		{"id": "evt_1", "type": "install", "timestamp": "2026-05-01T12:00:00.250Z"},
+		{"id": "evt_bad", "type": "install", "timestamp": "not a time"},
+		{"id": "evt_2", "type": "install", "timestamp": "2026-05-01T12:00:05.500Z"}`}
+	server := httptest.NewServer(logs)
+	defer server.Close()
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+
+	exporter := &Exporter{
+		Console:     services.NewConsoleService(c),
+		Filters:     models.AccountEventLogFilters{TemplateIDs: []string{"tmpl_a"}},
+		CursorStore: services.FileCursorStore{Path: filepath.Join(t.TempDir(), "cursor.json")},
+	}
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, FormatJSONLines, false)
+	n, err := exporter.Export(context.Background(), w)
+	if err != nil || n != 3 {
+		t.Fatalf("Export() = %d, %v, want 3 events", n, err)
+	}
+	if !strings.Contains(buf.String(), `"id":"evt_bad"`) {
+		t.Errorf("output = %s, want the event with a malformed timestamp", buf.String())
+	}
+
+	// The API returns events from the watermark's second again
+	logs.mu.Lock()
+	logs.logs = `
+		{"id": "evt_2", "type": "install", "timestamp": "2026-05-01T12:00:05.500Z"},
+		{"id": "evt_3", "type": "install", "timestamp": "2026-05-01T12:00:05.750Z"}`
+	logs.mu.Unlock()
+
+	buf.Reset()
+	n, err = exporter.Export(context.Background(), w)
+	if err != nil || n != 1 {
+		t.Fatalf("Export() = %d, %v, want 1 new event", n, err)
+	}
+	if !strings.Contains(buf.String(), `"id":"evt_3"`) {
+		t.Errorf("output = %s, want evt_3", buf.String())
+	}
+
+	buf.Reset()
+	n, _ = exporter.Export(context.Background(), w)
+	if n != 0 {
+		t.Errorf("Export() = %d on an unchanged log, want 0: %s", n, buf.String())
+	}
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// Exporter writes the events of an account, or of chosen templates, to a
+// Writer
+type Exporter struct {
+	Console *services.ConsoleService
+	// Filters selects the templates and events to export. Its StartDate
+	// is only used when there is no saved cursor.
+	Filters models.AccountEventLogFilters
+	// CursorStore, if set, holds the watermark of the last export so the
+	// next run only writes newer events
+	CursorStore services.CursorStore
+}
+
+// Export writes events newer than the saved cursor, oldest first, and
+// returns how many were written. The cursor is saved only after the
+// writer has been flushed, so a failed run is repeated in full.
+//
+// Events are decoded tolerantly, so a malformed entry does not fail the
+// export. Events whose timestamp cannot be parsed are written rather than
+// dropped, with the raw value in their extra fields, and may be written
+// again by a later run.
+func (e *Exporter) Export(ctx context.Context, w Writer) (int, error) {
+	var cursor *services.EventCursor
+	if e.CursorStore != nil {
+		saved, err := e.CursorStore.LoadCursor()
+		if err != nil {
+			return 0, err
+		}
+		cursor = saved
+	}
+
+	filters := e.Filters
+	seen := map[string]bool{}
+	var floor time.Time
+	if cursor != nil {
+		since := cursor.Watermark
+		filters.StartDate = &since
+		floor = since.Truncate(time.Second)
+		for _, id := range cursor.SeenIDs {
+			seen[id] = true
+		}
+	}
+
+	events, err := e.Console.NormalizedAccountEventLog(ctx, filters)
+	if err != nil {
+		return 0, err
+	}
+
+	next := services.EventCursor{}
+	if cursor != nil {
+		next.Watermark = cursor.Watermark
+	}
+	var written []models.NormalizedEvent
+	for _, event := range events {
+		untimed := event.Timestamp.IsZero()
+		if seen[event.Key()] || (cursor != nil && !untimed && event.Timestamp.Before(floor)) {
+			continue
+		}
+		if err := w.Write(event); err != nil {
+			return len(written), err
+		}
+		written = append(written, event)
+		if event.Timestamp.After(next.Watermark) {
+			next.Watermark = event.Timestamp
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return len(written), err
+	}
+	if e.CursorStore == nil || len(written) == 0 {
+		return len(written), nil
+	}
+
+	// Remember the events the next run's start date will return again
+	nextFloor := next.Watermark.Truncate(time.Second)
+	if cursor != nil && floor.Equal(nextFloor) {
+		next.SeenIDs = append(next.SeenIDs, cursor.SeenIDs...)
+	}
+	for _, event := range written {
+		if event.Timestamp.IsZero() || !event.Timestamp.Before(nextFloor) {
+			next.SeenIDs = append(next.SeenIDs, event.Key())
+		}
+	}
+	sort.Strings(next.SeenIDs)
+	return len(written), e.CursorStore.SaveCursor(&next)
+}
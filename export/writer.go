@@ -0,0 +1,160 @@
+// Package export writes event logs in formats SIEMs ingest.
+//
+// Events are written as JSON Lines or CSV with UTC timestamps. An Exporter
+// fetches events across templates and, given a cursor store, only exports
+// events newer than the previous run.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// Format is an export file format
+type Format string
+
+// Supported formats
+const (
+	FormatJSONLines Format = "jsonl"
+	FormatCSV       Format = "csv"
+)
+
+// Record is the flat form of an event written by every format
+type Record struct {
+	ID         string                     `json:"id"`
+	Type       string                     `json:"type"`
+	Timestamp  string                     `json:"timestamp"`
+	CreatedAt  string                     `json:"created_at,omitempty"`
+	TemplateID string                     `json:"template_id,omitempty"`
+	CardID     string                     `json:"card_id,omitempty"`
+	UserID     string                     `json:"user_id,omitempty"`
+	Device     string                     `json:"device,omitempty"`
+	IPAddress  string                     `json:"ip_address,omitempty"`
+	UserAgent  string                     `json:"user_agent,omitempty"`
+	Details    json.RawMessage            `json:"details,omitempty"`
+	Metadata   map[string]interface{}     `json:"metadata,omitempty"`
+	Extra      map[string]json.RawMessage `json:"extra,omitempty"`
+}
+
+// csvHeader lists the CSV columns in order
+var csvHeader = []string{
+	"id", "type", "timestamp", "created_at", "template_id", "card_id", "user_id",
+	"device", "ip_address", "user_agent", "details", "metadata", "extra",
+}
+
+// NewRecord flattens an event, formatting times as RFC 3339 in UTC
+func NewRecord(event models.NormalizedEvent) Record {
+	record := Record{
+		ID:         string(event.ID),
+		Type:       string(event.Type),
+		Timestamp:  formatTime(event.Timestamp),
+		CreatedAt:  formatTime(event.CreatedAt),
+		TemplateID: event.TemplateID,
+		CardID:     event.CardID,
+		UserID:     event.UserID,
+		Device:     event.Device,
+		IPAddress:  event.IPAddress,
+		UserAgent:  event.UserAgent,
+		Metadata:   event.Metadata,
+		Extra:      event.Extra,
+	}
+
+	switch details := event.Details.(type) {
+	case nil:
+	case *models.TextEventDetails:
+		record.Details, _ = json.Marshal(details.Text)
+	case *models.RawEventDetails:
+		record.Details = details.Raw
+	case *models.DeviceEventDetails:
+		record.Details = details.Raw
+	case *models.CardStateEventDetails:
+		record.Details = details.Raw
+	}
+	return record
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// Writer writes events in an export format
+type Writer interface {
+	Write(event models.NormalizedEvent) error
+	// Flush writes any buffered data to the underlying writer
+	Flush() error
+}
+
+// NewWriter returns a writer for the format. CSV output starts with a
+// header row when header is true; pass false when appending to a file
+// that already has one.
+func NewWriter(w io.Writer, format Format, header bool) (Writer, error) {
+	switch format {
+	case FormatJSONLines:
+		return &jsonLinesWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w), header: header}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+type jsonLinesWriter struct {
+	encoder *json.Encoder
+}
+
+func (j *jsonLinesWriter) Write(event models.NormalizedEvent) error {
+	return j.encoder.Encode(NewRecord(event))
+}
+
+func (j *jsonLinesWriter) Flush() error {
+	return nil
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (c *csvWriter) Write(event models.NormalizedEvent) error {
+	if c.header {
+		if err := c.writer.Write(csvHeader); err != nil {
+			return err
+		}
+		c.header = false
+	}
+
+	record := NewRecord(event)
+	var metadata, extra string
+	if len(record.Metadata) > 0 {
+		data, err := json.Marshal(record.Metadata)
+		if err != nil {
+			return fmt.Errorf("error encoding metadata of event %s: %w", record.ID, err)
+		}
+		metadata = string(data)
+	}
+	if len(record.Extra) > 0 {
+		data, err := json.Marshal(record.Extra)
+		if err != nil {
+			return fmt.Errorf("error encoding extra fields of event %s: %w", record.ID, err)
+		}
+		extra = string(data)
+	}
+	return c.writer.Write([]string{
+		record.ID, record.Type, record.Timestamp, record.CreatedAt, record.TemplateID,
+		record.CardID, record.UserID, record.Device, record.IPAddress, record.UserAgent,
+		string(record.Details), metadata, extra,
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
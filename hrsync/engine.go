@@ -0,0 +1,302 @@
+package hrsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/budget"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// ErrChangeLimit is matched by errors.Is when a run is aborted because it
+// would change more cards than its limits allow
+var ErrChangeLimit = errors.New("change limit exceeded")
+
+// ErrEmptyRoster is returned when the source returns no employees while the
+// template has managed cards, which would remove every one of them. It
+// usually means the HR system or directory is down.
+var ErrEmptyRoster = errors.New("roster is empty")
+
+// DefaultMaxRemoveFraction is the fraction of the managed cards a run may
+// remove by suspending, and separately by deleting, when Limits sets no
+// fraction
+const DefaultMaxRemoveFraction = 0.1
+
+// Limits bound the changes of a single run. Suspend and delete limits
+// count only removals, the cards of employees missing from the roster;
+// suspending the card of an inactive employee is not limited. Fractions
+// are of the managed cards and always allow at least one removal. Zero
+// counts are not enforced; zero fractions default to
+// DefaultMaxRemoveFraction, and a fraction of 1 allows every managed card
+// to be removed.
+type Limits struct {
+	MaxProvisions      int
+	MaxSuspends        int
+	MaxSuspendFraction float64
+	MaxDeletes         int
+	MaxDeleteFraction  float64
+}
+
+// LimitError describes a run aborted by its limits
+type LimitError struct {
+	Action  Action
+	Planned int
+	Allowed int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("change limit exceeded: %d cards to %s, at most %d allowed", e.Planned, e.Action, e.Allowed)
+}
+
+// Is matches ErrChangeLimit
+func (e *LimitError) Is(target error) bool {
+	return target == ErrChangeLimit
+}
+
+// Config configures an Engine
+type Config struct {
+	// CardTemplateID is the template new passes are provisioned with. Only
+	// cards of this template are synced.
+	CardTemplateID string
+	// RemoveAction is applied to cards of employees missing from the
+	// roster: ActionDelete (the default) or ActionSuspend
+	RemoveAction Action
+	// DefaultValidity sets the expiration of new passes for employees
+	// without an ExpirationDate. Defaults to one year.
+	DefaultValidity time.Duration
+	Limits          Limits
+	// AllowEmptyRoster lets a run remove every managed card when the source
+	// returns no employees. Otherwise such a run fails with ErrEmptyRoster.
+	AllowEmptyRoster bool
+	// DryRun plans and reports changes without making them
+	DryRun bool
+	// Provisioner, if set, provisions new passes instead of the card
+	// service, for example a budget.Guard
+	Provisioner budget.Provisioner
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Engine syncs a template's cards with a roster
+type Engine struct {
+	cards  *services.AccessCardsService
+	source Source
+	config Config
+}
+
+// NewEngine creates a sync engine
+func NewEngine(cards *services.AccessCardsService, source Source, config Config) (*Engine, error) {
+	if config.CardTemplateID == "" {
+		return nil, errors.New("CardTemplateID is required")
+	}
+	switch config.RemoveAction {
+	case "":
+		config.RemoveAction = ActionDelete
+	case ActionDelete, ActionSuspend:
+	default:
+		return nil, fmt.Errorf("RemoveAction must be %s or %s, got %q", ActionDelete, ActionSuspend, config.RemoveAction)
+	}
+	if config.DefaultValidity <= 0 {
+		config.DefaultValidity = 365 * 24 * time.Hour
+	}
+	if config.Limits.MaxSuspendFraction == 0 {
+		config.Limits.MaxSuspendFraction = DefaultMaxRemoveFraction
+	}
+	if config.Limits.MaxDeleteFraction == 0 {
+		config.Limits.MaxDeleteFraction = DefaultMaxRemoveFraction
+	}
+	if config.Provisioner == nil {
+		config.Provisioner = cards
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Engine{cards: cards, source: source, config: config}, nil
+}
+
+// Plan reads the roster and the template's cards and returns the changes
+// a run would make. An empty roster is refused unless AllowEmptyRoster is
+// set.
+func (e *Engine) Plan(ctx context.Context) (*Plan, error) {
+	employees, err := e.source.Employees(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading roster: %w", err)
+	}
+	cards, err := e.cards.List(ctx, &models.ListKeysParams{TemplateID: e.config.CardTemplateID})
+	if err != nil {
+		return nil, err
+	}
+	plan, err := buildPlan(employees, cards, e.config.RemoveAction)
+	if err != nil {
+		return nil, err
+	}
+	if len(employees) == 0 && plan.Managed > 0 && !e.config.AllowEmptyRoster {
+		return nil, fmt.Errorf("%w: refusing to remove %d managed cards", ErrEmptyRoster, plan.Managed)
+	}
+	return plan, nil
+}
+
+// checkLimits returns a LimitError for the first limit the plan exceeds
+func (e *Engine) checkLimits(plan *Plan) error {
+	allowed := func(count int, fraction float64) int {
+		limit := -1
+		if count > 0 {
+			limit = count
+		}
+		if fraction > 0 {
+			byFraction := max(1, int(math.Floor(fraction*float64(plan.Managed))))
+			if limit < 0 || byFraction < limit {
+				limit = byFraction
+			}
+		}
+		return limit
+	}
+
+	l := e.config.Limits
+	for _, check := range []struct {
+		action  Action
+		limit   int
+		planned int
+	}{
+		{ActionProvision, allowed(l.MaxProvisions, 0), plan.Count(ActionProvision)},
+		{ActionSuspend, allowed(l.MaxSuspends, l.MaxSuspendFraction), plan.removals(ActionSuspend)},
+		{ActionDelete, allowed(l.MaxDeletes, l.MaxDeleteFraction), plan.removals(ActionDelete)},
+	} {
+		if planned := check.planned; check.limit >= 0 && planned > check.limit {
+			return &LimitError{Action: check.action, Planned: planned, Allowed: check.limit}
+		}
+	}
+	return nil
+}
+
+// Run plans and applies a sync. The report is returned even when the run
+// fails; a run that exceeds its limits makes no changes. Failed changes
+// are recorded and the run continues with the rest.
+func (e *Engine) Run(ctx context.Context) (*Report, error) {
+	report := &Report{StartedAt: e.config.Now().UTC(), DryRun: e.config.DryRun, CardTemplateID: e.config.CardTemplateID}
+	finish := func(err error) (*Report, error) {
+		report.FinishedAt = e.config.Now().UTC()
+		if err != nil {
+			report.Error = err.Error()
+		}
+		return report, err
+	}
+
+	plan, err := e.Plan(ctx)
+	if err != nil {
+		return finish(err)
+	}
+	report.Desired, report.Managed, report.Unmanaged = plan.Desired, plan.Managed, plan.Unmanaged
+
+	if err := e.checkLimits(plan); err != nil {
+		for _, change := range plan.Changes {
+			report.Results = append(report.Results, Result{Change: change, Status: StatusSkipped})
+		}
+		return finish(err)
+	}
+
+	failed := 0
+	for _, change := range plan.Changes {
+		result := Result{Change: change, Status: StatusPlanned}
+		if !e.config.DryRun {
+			cardID, err := e.apply(ctx, change)
+			if cardID != "" {
+				result.CardID = cardID
+			}
+			if err != nil {
+				result.Status = StatusFailed
+				result.Error = err.Error()
+				failed++
+			} else {
+				result.Status = StatusApplied
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	if failed > 0 {
+		return finish(fmt.Errorf("%d of %d changes failed", failed, len(plan.Changes)))
+	}
+	return finish(nil)
+}
+
+// apply makes one change and returns the ID of a provisioned card
+func (e *Engine) apply(ctx context.Context, change Change) (string, error) {
+	switch change.Action {
+	case ActionProvision:
+		card, err := e.config.Provisioner.Provision(ctx, e.provisionParams(change.employee))
+		if err != nil {
+			return "", err
+		}
+		return card.ID, nil
+	case ActionUpdate:
+		_, err := e.cards.Update(ctx, updateParams(change))
+		return "", err
+	case ActionResume:
+		return "", e.cards.Resume(ctx, change.CardID)
+	case ActionSuspend:
+		return "", e.cards.Suspend(ctx, change.CardID)
+	case ActionDelete:
+		return "", e.cards.Delete(ctx, change.CardID)
+	default:
+		return "", fmt.Errorf("unknown action %q", change.Action)
+	}
+}
+
+func (e *Engine) provisionParams(employee *Employee) models.ProvisionParams {
+	start := employee.StartDate
+	if start.IsZero() {
+		start = e.config.Now()
+	}
+	expiration := employee.ExpirationDate
+	if expiration.IsZero() {
+		expiration = start.Add(e.config.DefaultValidity)
+	}
+
+	return models.ProvisionParams{
+		CardTemplateID: e.config.CardTemplateID,
+		EmployeeID:     employee.EmployeeID,
+		FullName:       employee.FullName,
+		Email:          employee.Email,
+		PhoneNumber:    employee.PhoneNumber,
+		Classification: employee.Classification,
+		Title:          employee.Title,
+		Department:     employee.Department,
+		Location:       employee.Location,
+		SiteName:       employee.SiteName,
+		Workstation:    employee.Workstation,
+		MailStop:       employee.MailStop,
+		CompanyAddress: employee.CompanyAddress,
+		StartDate:      start.UTC(),
+		ExpirationDate: expiration.UTC(),
+		EmployeePhoto:  employee.EmployeePhoto,
+		Metadata:       employee.Metadata,
+	}
+}
+
+// updateParams sends only the fields that differ
+func updateParams(change Change) models.UpdateParams {
+	params := models.UpdateParams{CardID: change.CardID}
+	for _, diff := range change.Diffs {
+		switch diff.Field {
+		case "full_name":
+			params.FullName = diff.New
+		case "email":
+			params.Email = diff.New
+		case "phone_number":
+			params.PhoneNumber = diff.New
+		case "classification":
+			params.Classification = diff.New
+		case "title":
+			params.Title = diff.New
+		case "expiration_date":
+			expiration := change.employee.ExpirationDate.UTC()
+			params.ExpirationDate = &expiration
+		}
+	}
+	return params
+}
@@ -0,0 +1,310 @@
+package hrsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// cardServer serves a template's cards and records the changes made
+type cardServer struct {
+	mu       sync.Mutex
+	cards    []models.Card
+	requests []string
+	failures map[string]bool
+}
+
+func (s *cardServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method == http.MethodGet && r.URL.Path == "/v1/key-cards" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.cards})
+		return
+	}
+
+	request := r.Method + " " + r.URL.Path
+	s.requests = append(s.requests, request)
+	if s.failures[request] {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"message": "failed"}`))
+		return
+	}
+	if r.Method == http.MethodPost && r.URL.Path == "/v1/key-cards" {
+		var params models.ProvisionParams
+		json.NewDecoder(r.Body).Decode(&params)
+		json.NewEncoder(w).Encode(models.CardProvisionResponse{ID: "new_" + params.EmployeeID, EmployeeID: params.EmployeeID})
+		return
+	}
+	w.Write([]byte(`{}`))
+}
+
+func newTestEngine(t *testing.T, server *cardServer, employees []Employee, config Config) *Engine {
+	t.Helper()
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	c, err := client.NewClient("test-account", "test-secret", client.WithBaseURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.CardTemplateID = "tmpl_1"
+	config.Now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	engine, err := NewEngine(services.NewAccessCardsService(c), StaticSource(employees), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+// noRemoveLimits lifts the default removal limits
+var noRemoveLimits = Limits{MaxSuspendFraction: 1, MaxDeleteFraction: 1}
+
+func rosterAndCards() ([]Employee, []models.Card) {
+	employees := []Employee{
+		{EmployeeID: "e1", FullName: "Ada Lovelace", Email: "ada@example.com", Active: true},
+		{EmployeeID: "e2", FullName: "Grace Hopper", Email: "grace@example.com", Active: true},
+		{EmployeeID: "e3", FullName: "Alan Turing", Active: false},
+		{EmployeeID: "e4", FullName: "Edsger Dijkstra", Active: true},
+		{EmployeeID: "e6", FullName: "Barbara Liskov", Active: true},
+	}
+	cards := []models.Card{
+		{ID: "c1", EmployeeID: "e1", FullName: "Ada Lovelace", Email: "ada@example.com", State: "active"},
+		{ID: "c2", EmployeeID: "e2", FullName: "Grace Hopper", Email: "grace@old.example.com", State: "active"},
+		{ID: "c3", EmployeeID: "e3", FullName: "Alan Turing", State: "active"},
+		{ID: "c4", EmployeeID: "e4", FullName: "Edsger Dijkstra", State: "suspended"},
+		{ID: "c5", EmployeeID: "e5", FullName: "Former Employee", State: "active"},
+		{ID: "c7", EmployeeID: "e7", FullName: "Long Gone", State: "deleted"},
+		{ID: "c8", FullName: "Front Desk", State: "active"},
+	}
+	return employees, cards
+}
+
+func TestBuildPlan(t *testing.T) {
+	employees, cards := rosterAndCards()
+	plan, err := buildPlan(employees, cards, ActionDelete)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, c := range plan.Changes {
+		got = append(got, fmt.Sprintf("%s %s %s", c.Action, c.EmployeeID, c.CardID))
+	}
+	want := []string{
+		"provision e6 ",
+		"update e2 c2",
+		"resume e4 c4",
+		"suspend e3 c3",
+		"delete e5 c5",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if plan.Desired != 5 || plan.Managed != 5 || plan.Unmanaged != 1 {
+		t.Errorf("counts = %d/%d/%d, want 5/5/1", plan.Desired, plan.Managed, plan.Unmanaged)
+	}
+	if diffs := plan.Changes[1].Diffs; len(diffs) != 1 || diffs[0].Field != "email" || diffs[0].New != "grace@example.com" {
+		t.Errorf("diffs = %+v", diffs)
+	}
+}
+
+func TestBuildPlanRejectsBadRoster(t *testing.T) {
+	if _, err := buildPlan([]Employee{{FullName: "No ID"}}, nil, ActionDelete); err == nil {
+		t.Error("expected an error for a missing employee ID")
+	}
+	if _, err := buildPlan([]Employee{{EmployeeID: "e1"}, {EmployeeID: "e1"}}, nil, ActionDelete); err == nil {
+		t.Error("expected an error for a duplicate employee ID")
+	}
+}
+
+func TestBuildPlanSuspendRemoved(t *testing.T) {
+	cards := []models.Card{
+		{ID: "c1", EmployeeID: "e1", State: "active"},
+		{ID: "c2", EmployeeID: "e2", State: "suspended"},
+	}
+	plan, err := buildPlan(nil, cards, ActionSuspend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Action != ActionSuspend || plan.Changes[0].CardID != "c1" {
+		t.Errorf("changes = %+v, want only c1 suspended", plan.Changes)
+	}
+}
+
+func TestRunApplies(t *testing.T) {
+	employees, cards := rosterAndCards()
+	server := &cardServer{cards: cards}
+	engine := newTestEngine(t, server, employees, Config{Limits: noRemoveLimits})
+
+	report, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"POST /v1/key-cards",
+		"PATCH /v1/key-cards/c2",
+		"POST /v1/key-cards/c4/resume",
+		"POST /v1/key-cards/c3/suspend",
+		"POST /v1/key-cards/c5/delete",
+	}
+	if strings.Join(server.requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests:\n%s\nwant:\n%s", strings.Join(server.requests, "\n"), strings.Join(want, "\n"))
+	}
+	for _, result := range report.Results {
+		if result.Status != StatusApplied {
+			t.Errorf("%s %s: status %s", result.Action, result.EmployeeID, result.Status)
+		}
+	}
+	if report.Results[0].CardID != "new_e6" {
+		t.Errorf("provisioned card ID = %q, want new_e6", report.Results[0].CardID)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	employees, cards := rosterAndCards()
+	server := &cardServer{cards: cards}
+	engine := newTestEngine(t, server, employees, Config{DryRun: true, Limits: noRemoveLimits})
+
+	report, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(server.requests) != 0 {
+		t.Errorf("dry run made requests: %v", server.requests)
+	}
+	if len(report.Results) != 5 || report.Count(ActionDelete, StatusPlanned) != 1 {
+		t.Errorf("results = %+v", report.Results)
+	}
+
+	var out bytes.Buffer
+	if err := report.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"Dry run of template tmpl_1", `- delete e5 "Former Employee" (c5) [planned]`, `email: "grace@old.example.com" => "grace@example.com"`, "delete: 1 planned."} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("report missing %q:\n%s", s, out.String())
+		}
+	}
+}
+
+func TestRunLimits(t *testing.T) {
+	employees, cards := rosterAndCards()
+	server := &cardServer{cards: cards}
+	// Three deletes of five managed cards is 60%
+	engine := newTestEngine(t, server, employees[2:], Config{Limits: Limits{MaxDeleteFraction: 0.4}})
+
+	report, err := engine.Run(context.Background())
+	if !errors.Is(err, ErrChangeLimit) {
+		t.Fatalf("error = %v, want ErrChangeLimit", err)
+	}
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Action != ActionDelete || limitErr.Planned != 3 || limitErr.Allowed != 2 {
+		t.Errorf("limit error = %+v", limitErr)
+	}
+	if len(server.requests) != 0 {
+		t.Errorf("aborted run made requests: %v", server.requests)
+	}
+	if report.Count(ActionProvision, StatusSkipped) != 1 || report.Error == "" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestRunDefaultLimits(t *testing.T) {
+	employees, cards := rosterAndCards()
+	server := &cardServer{cards: cards}
+
+	// 10% of five cards rounds down to none, but one removal is always
+	// allowed, and suspending an inactive employee is not a removal
+	engine := newTestEngine(t, server, employees, Config{})
+	report, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.Count(ActionSuspend, StatusApplied) != 1 || report.Count(ActionDelete, StatusApplied) != 1 {
+		t.Errorf("results = %+v", report.Results)
+	}
+
+	server = &cardServer{cards: cards}
+	engine = newTestEngine(t, server, employees[2:], Config{RemoveAction: ActionSuspend})
+	_, err = engine.Run(context.Background())
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Action != ActionSuspend || limitErr.Planned != 3 || limitErr.Allowed != 1 {
+		t.Fatalf("error = %v, want a suspend limit error", err)
+	}
+	if len(server.requests) != 0 {
+		t.Errorf("aborted run made requests: %v", server.requests)
+	}
+}
+
+func TestRunRefusesEmptyRoster(t *testing.T) {
+	_, cards := rosterAndCards()
+	server := &cardServer{cards: cards}
+	engine := newTestEngine(t, server, nil, Config{Limits: noRemoveLimits})
+
+	if _, err := engine.Run(context.Background()); !errors.Is(err, ErrEmptyRoster) {
+		t.Fatalf("error = %v, want ErrEmptyRoster", err)
+	}
+	if len(server.requests) != 0 {
+		t.Errorf("refused run made requests: %v", server.requests)
+	}
+
+	engine = newTestEngine(t, server, nil, Config{Limits: noRemoveLimits, AllowEmptyRoster: true, DryRun: true})
+	report, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(ActionDelete, StatusPlanned) != 5 {
+		t.Errorf("results = %+v", report.Results)
+	}
+}
+
+func TestRunContinuesPastFailures(t *testing.T) {
+	employees, cards := rosterAndCards()
+	server := &cardServer{cards: cards, failures: map[string]bool{"POST /v1/key-cards/c4/resume": true}}
+	engine := newTestEngine(t, server, employees, Config{Limits: noRemoveLimits})
+
+	report, err := engine.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "1 of 5 changes failed") {
+		t.Fatalf("error = %v", err)
+	}
+	if report.Count(ActionResume, StatusFailed) != 1 || report.Count(ActionDelete, StatusApplied) != 1 {
+		t.Errorf("results = %+v", report.Results)
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Results) != 5 || decoded.Results[2].Status != StatusFailed || decoded.Results[2].Error == "" {
+		t.Errorf("decoded results = %+v", decoded.Results)
+	}
+}
+
+func TestProvisionParamsDefaults(t *testing.T) {
+	engine := newTestEngine(t, &cardServer{}, nil, Config{})
+	params := engine.provisionParams(&Employee{EmployeeID: "e1", FullName: "Ada Lovelace"})
+	if params.CardTemplateID != "tmpl_1" {
+		t.Errorf("template = %q", params.CardTemplateID)
+	}
+	if want := time.Date(2027, 3, 1, 12, 0, 0, 0, time.UTC); !params.ExpirationDate.Equal(want) {
+		t.Errorf("expiration = %v, want %v", params.ExpirationDate, want)
+	}
+}
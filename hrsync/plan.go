@@ -0,0 +1,181 @@
+package hrsync
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// Action is a change to a card
+type Action string
+
+// Card changes a plan can make
+const (
+	ActionProvision Action = "provision"
+	ActionUpdate    Action = "update"
+	ActionResume    Action = "resume"
+	ActionSuspend   Action = "suspend"
+	ActionDelete    Action = "delete"
+)
+
+// actionOrder is the order changes are applied in: removals go last so an
+// aborted run leaves people with access rather than without
+var actionOrder = []Action{ActionProvision, ActionUpdate, ActionResume, ActionSuspend, ActionDelete}
+
+// Card states the engine acts on
+const (
+	stateSuspended = "suspended"
+	stateDeleted   = "deleted"
+)
+
+// FieldDiff is a field that differs between the roster and a card
+type FieldDiff struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Change is one planned change
+type Change struct {
+	Action     Action      `json:"action"`
+	EmployeeID string      `json:"employee_id"`
+	FullName   string      `json:"full_name,omitempty"`
+	CardID     string      `json:"card_id,omitempty"`
+	Diffs      []FieldDiff `json:"diffs,omitempty"`
+
+	employee *Employee
+	// removal is set for changes to cards of employees missing from the
+	// roster
+	removal bool
+}
+
+// Plan is the set of changes a run will make
+type Plan struct {
+	Changes []Change `json:"changes"`
+	// Desired is the number of employees in the roster
+	Desired int `json:"desired"`
+	// Managed is the number of live cards with an employee ID
+	Managed int `json:"managed"`
+	// Unmanaged is the number of live cards without an employee ID, which
+	// are left alone
+	Unmanaged int `json:"unmanaged"`
+}
+
+// Count returns the number of changes with the action
+func (p *Plan) Count(action Action) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// removals returns the number of removals with the action
+func (p *Plan) removals(action Action) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action && c.removal {
+			n++
+		}
+	}
+	return n
+}
+
+// buildPlan compares the roster with the template's cards
+func buildPlan(employees []Employee, cards []models.Card, removeAction Action) (*Plan, error) {
+	desired := map[string]*Employee{}
+	for i := range employees {
+		e := &employees[i]
+		if e.EmployeeID == "" {
+			return nil, fmt.Errorf("employee %q has no employee ID", e.FullName)
+		}
+		if desired[e.EmployeeID] != nil {
+			return nil, fmt.Errorf("duplicate employee ID %q in source", e.EmployeeID)
+		}
+		desired[e.EmployeeID] = e
+	}
+
+	plan := &Plan{Desired: len(employees)}
+	hasCard := map[string]bool{}
+	for _, card := range cards {
+		if card.State == stateDeleted {
+			continue
+		}
+		if card.EmployeeID == "" {
+			plan.Unmanaged++
+			continue
+		}
+		plan.Managed++
+		hasCard[card.EmployeeID] = true
+
+		e, ok := desired[card.EmployeeID]
+		if !ok {
+			if removeAction == ActionSuspend && card.State == stateSuspended {
+				continue
+			}
+			plan.Changes = append(plan.Changes, Change{Action: removeAction, EmployeeID: card.EmployeeID, FullName: card.FullName, CardID: card.ID, removal: true})
+			continue
+		}
+
+		if diffs := cardDiffs(card, e); len(diffs) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, EmployeeID: e.EmployeeID, FullName: e.FullName, CardID: card.ID, Diffs: diffs, employee: e})
+		}
+		switch {
+		case e.Active && card.State == stateSuspended:
+			plan.Changes = append(plan.Changes, Change{Action: ActionResume, EmployeeID: e.EmployeeID, FullName: e.FullName, CardID: card.ID, employee: e})
+		case !e.Active && card.State != stateSuspended:
+			plan.Changes = append(plan.Changes, Change{Action: ActionSuspend, EmployeeID: e.EmployeeID, FullName: e.FullName, CardID: card.ID, employee: e})
+		}
+	}
+
+	for i := range employees {
+		e := &employees[i]
+		if e.Active && !hasCard[e.EmployeeID] {
+			plan.Changes = append(plan.Changes, Change{Action: ActionProvision, EmployeeID: e.EmployeeID, FullName: e.FullName, employee: e})
+		}
+	}
+
+	rank := map[Action]int{}
+	for i, a := range actionOrder {
+		rank[a] = i
+	}
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		a, b := plan.Changes[i], plan.Changes[j]
+		if a.Action != b.Action {
+			return rank[a.Action] < rank[b.Action]
+		}
+		return a.EmployeeID < b.EmployeeID
+	})
+	return plan, nil
+}
+
+// cardDiffs lists the roster fields that differ from the card. Empty
+// roster fields are not compared, since an update cannot clear them.
+func cardDiffs(card models.Card, e *Employee) []FieldDiff {
+	var diffs []FieldDiff
+	compare := func(field, old, new string) {
+		if new != "" && old != new {
+			diffs = append(diffs, FieldDiff{Field: field, Old: old, New: new})
+		}
+	}
+	compare("full_name", card.FullName, e.FullName)
+	compare("email", card.Email, e.Email)
+	compare("phone_number", card.PhoneNumber, e.PhoneNumber)
+	compare("classification", card.Classification, e.Classification)
+	compare("title", card.Title, e.Title)
+	if !e.ExpirationDate.IsZero() {
+		compare("expiration_date", formatDate(card.ExpirationDate), formatDate(e.ExpirationDate))
+	}
+	return diffs
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02")
+}
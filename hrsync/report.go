@@ -0,0 +1,130 @@
+package hrsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of a change
+type Status string
+
+const (
+	// StatusPlanned marks changes of a dry run
+	StatusPlanned Status = "planned"
+	StatusApplied Status = "applied"
+	StatusFailed  Status = "failed"
+	// StatusSkipped marks changes of a run aborted by its limits
+	StatusSkipped Status = "skipped"
+)
+
+// Result records what happened to a change
+type Result struct {
+	Change
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the audit record of a run
+type Report struct {
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	DryRun         bool      `json:"dry_run"`
+	CardTemplateID string    `json:"card_template_id"`
+	Desired        int       `json:"desired"`
+	Managed        int       `json:"managed"`
+	Unmanaged      int       `json:"unmanaged"`
+	Results        []Result  `json:"results"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Count returns the number of results with the action and status
+func (r *Report) Count(action Action, status Status) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Action == action && result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Write writes the report for people, one line per change
+func (r *Report) Write(w io.Writer) error {
+	symbols := map[Action]string{
+		ActionProvision: "+",
+		ActionUpdate:    "~",
+		ActionResume:    ">",
+		ActionSuspend:   "||",
+		ActionDelete:    "-",
+	}
+
+	mode := "Sync"
+	if r.DryRun {
+		mode = "Dry run"
+	}
+	if _, err := fmt.Fprintf(w, "%s of template %s at %s: %d employees, %d managed cards, %d unmanaged\n\n",
+		mode, r.CardTemplateID, r.StartedAt.Format(time.RFC3339), r.Desired, r.Managed, r.Unmanaged); err != nil {
+		return err
+	}
+
+	if len(r.Results) == 0 {
+		if _, err := fmt.Fprintln(w, "No changes."); err != nil {
+			return err
+		}
+	}
+	for _, result := range r.Results {
+		line := fmt.Sprintf("%s %s %s", symbols[result.Action], result.Action, result.EmployeeID)
+		if result.FullName != "" {
+			line += fmt.Sprintf(" %q", result.FullName)
+		}
+		if result.CardID != "" {
+			line += fmt.Sprintf(" (%s)", result.CardID)
+		}
+		line += " [" + string(result.Status) + "]"
+		if result.Error != "" {
+			line += ": " + result.Error
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+		for _, d := range result.Diffs {
+			if _, err := fmt.Fprintf(w, "    %s: %q => %q\n", d.Field, d.Old, d.New); err != nil {
+				return err
+			}
+		}
+	}
+
+	var summary []string
+	for _, action := range actionOrder {
+		var counts []string
+		for _, status := range []Status{StatusPlanned, StatusApplied, StatusFailed, StatusSkipped} {
+			if n := r.Count(action, status); n > 0 {
+				counts = append(counts, fmt.Sprintf("%d %s", n, status))
+			}
+		}
+		if len(counts) > 0 {
+			summary = append(summary, fmt.Sprintf("%s: %s", action, strings.Join(counts, ", ")))
+		}
+	}
+	if len(summary) > 0 {
+		if _, err := fmt.Fprintf(w, "\n%s.\n", strings.Join(summary, "; ")); err != nil {
+			return err
+		}
+	}
+	if r.Error != "" {
+		if _, err := fmt.Fprintf(w, "Error: %s\n", r.Error); err != nil {
+			return err
+		}
+	}
+	return nil
+}
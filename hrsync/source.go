@@ -0,0 +1,61 @@
+// Package hrsync keeps access passes in line with an HR roster.
+//
+// An Engine reads the desired employees from a Source, compares them with
+// the cards of a template, and provisions, updates, suspends, resumes or
+// deletes cards to match. Runs can be dry runs, are bounded by change
+// limits, and produce an audit report of every change.
+package hrsync
+
+import (
+	"context"
+	"time"
+)
+
+// Employee is the desired state of one employee's access pass
+type Employee struct {
+	EmployeeID     string
+	FullName       string
+	Email          string
+	PhoneNumber    string
+	Classification string
+	Title          string
+	Department     string
+	Location       string
+	SiteName       string
+	Workstation    string
+	MailStop       string
+	CompanyAddress string
+	StartDate      time.Time
+	// ExpirationDate is compared with the card's by day. A zero value
+	// leaves the card's expiration alone.
+	ExpirationDate time.Time
+	// Active is false for employees who keep their pass but should not
+	// be able to use it, such as those on leave
+	Active bool
+	// EmployeePhoto is a base64 photo, as prepared by the images package.
+	// It is sent with new passes only.
+	EmployeePhoto string
+	Metadata      map[string]interface{}
+}
+
+// Source supplies the desired employees. Employees missing from the
+// result have their cards removed.
+type Source interface {
+	Employees(ctx context.Context) ([]Employee, error)
+}
+
+// StaticSource is a Source backed by a fixed list
+type StaticSource []Employee
+
+// Employees returns the list
+func (s StaticSource) Employees(ctx context.Context) ([]Employee, error) {
+	return s, nil
+}
+
+// SourceFunc adapts a function to a Source
+type SourceFunc func(ctx context.Context) ([]Employee, error)
+
+// Employees calls f
+func (f SourceFunc) Employees(ctx context.Context) ([]Employee, error) {
+	return f(ctx)
+}
@@ -0,0 +1,220 @@
+package images
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// AssetSpec describes the format and pixel dimensions a wallet accepts for
+// a template asset. Zero limits are not enforced.
+type AssetSpec struct {
+	Name      string
+	Formats   []string
+	MinWidth  int
+	MinHeight int
+	MaxWidth  int
+	MaxHeight int
+	Square    bool
+	MaxBytes  int
+}
+
+// Asset specifications for Apple Wallet, Google Wallet and landing pages.
+// Apple limits are given for @3x artwork.
+var (
+	AppleLogo = AssetSpec{
+		Name:      "Apple logo",
+		Formats:   []string{"png"},
+		MinHeight: 50,
+		MaxWidth:  480,
+		MaxHeight: 150,
+		MaxBytes:  512 * 1024,
+	}
+	AppleIcon = AssetSpec{
+		Name:      "Apple icon",
+		Formats:   []string{"png"},
+		MinWidth:  58,
+		MinHeight: 58,
+		MaxWidth:  1024,
+		MaxHeight: 1024,
+		Square:    true,
+		MaxBytes:  512 * 1024,
+	}
+	AppleBackground = AssetSpec{
+		Name:      "Apple background",
+		Formats:   []string{"png", "jpeg"},
+		MinWidth:  180,
+		MinHeight: 220,
+		MaxWidth:  540,
+		MaxHeight: 660,
+		MaxBytes:  1024 * 1024,
+	}
+	GoogleLogo = AssetSpec{
+		Name:      "Google logo",
+		Formats:   []string{"png", "jpeg"},
+		MinWidth:  660,
+		MinHeight: 660,
+		Square:    true,
+		MaxBytes:  1024 * 1024,
+	}
+	GoogleBackground = AssetSpec{
+		Name:      "Google hero image",
+		Formats:   []string{"png", "jpeg"},
+		MinWidth:  1032,
+		MinHeight: 336,
+		MaxBytes:  1024 * 1024,
+	}
+	LandingPageLogo = AssetSpec{
+		Name:     "landing page logo",
+		Formats:  []string{"png", "jpeg"},
+		MaxWidth: 1024,
+		MaxBytes: 1024 * 1024,
+	}
+)
+
+// AssetError reports every way an asset fails its specification
+type AssetError struct {
+	Asset    string
+	Problems []string
+}
+
+// Error implements the error interface
+func (e *AssetError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Asset, strings.Join(e.Problems, "; "))
+}
+
+// Asset validates an image against spec and returns it base64 encoded.
+// The image bytes are passed through unchanged so the artwork is not
+// recompressed.
+func Asset(r io.Reader, spec AssetSpec) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", spec.Name, err)
+	}
+	if err := spec.Validate(data); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// AssetFile is like Asset but reads the image from a file
+func AssetFile(path string, spec AssetSpec) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening %s: %w", spec.Name, err)
+	}
+	defer f.Close()
+	return Asset(f, spec)
+}
+
+// Validate checks encoded image data against the specification
+func (spec AssetSpec) Validate(data []byte) error {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return &AssetError{
+			Asset:    spec.Name,
+			Problems: []string{fmt.Sprintf("not a readable image (%v); export it as %s", err, strings.Join(spec.Formats, " or "))},
+		}
+	}
+
+	var problems []string
+	if !slices.Contains(spec.Formats, format) {
+		problems = append(problems, fmt.Sprintf("format is %s, re-export it as %s", format, strings.Join(spec.Formats, " or ")))
+	}
+	if spec.MinWidth > 0 && cfg.Width < spec.MinWidth {
+		problems = append(problems, fmt.Sprintf("width %dpx is below the minimum of %dpx", cfg.Width, spec.MinWidth))
+	}
+	if spec.MinHeight > 0 && cfg.Height < spec.MinHeight {
+		problems = append(problems, fmt.Sprintf("height %dpx is below the minimum of %dpx", cfg.Height, spec.MinHeight))
+	}
+	if spec.MaxWidth > 0 && cfg.Width > spec.MaxWidth {
+		problems = append(problems, fmt.Sprintf("width %dpx exceeds the maximum of %dpx", cfg.Width, spec.MaxWidth))
+	}
+	if spec.MaxHeight > 0 && cfg.Height > spec.MaxHeight {
+		problems = append(problems, fmt.Sprintf("height %dpx exceeds the maximum of %dpx", cfg.Height, spec.MaxHeight))
+	}
+	if spec.Square && cfg.Width != cfg.Height {
+		problems = append(problems, fmt.Sprintf("image is %dx%dpx but must be square", cfg.Width, cfg.Height))
+	}
+	if spec.MaxBytes > 0 && len(data) > spec.MaxBytes {
+		problems = append(problems, fmt.Sprintf("file is %d KB, compress it below %d KB", len(data)/1024, spec.MaxBytes/1024))
+	}
+
+	if len(problems) > 0 {
+		return &AssetError{Asset: spec.Name, Problems: problems}
+	}
+	return nil
+}
+
+// TemplateAssets holds paths to the image files of a card template.
+// Empty paths are skipped.
+type TemplateAssets struct {
+	Background string
+	Logo       string
+	Icon       string
+}
+
+// ApplyTemplateAssets loads and validates the assets for the platform of
+// params and stores their encoded form on params. Apple templates use
+// BackgroundImage, LogoImage and IconImage; Google templates use
+// BackgroundImage and Logo and have no icon. params is left unchanged if
+// any asset fails.
+func ApplyTemplateAssets(params *models.CreateTemplateParams, assets TemplateAssets) error {
+	updated := *params
+	var background, logo AssetSpec
+	var logoField *string
+	switch updated.Platform {
+	case "apple":
+		background, logo, logoField = AppleBackground, AppleLogo, &updated.LogoImage
+	case "android", "google":
+		background, logo, logoField = GoogleBackground, GoogleLogo, &updated.Logo
+		if assets.Icon != "" {
+			return errors.New("icon images are only supported on Apple templates")
+		}
+	default:
+		return fmt.Errorf("unsupported template platform %q, want apple or android", updated.Platform)
+	}
+
+	if assets.Background != "" {
+		encoded, err := AssetFile(assets.Background, background)
+		if err != nil {
+			return err
+		}
+		updated.BackgroundImage = encoded
+	}
+	if assets.Logo != "" {
+		encoded, err := AssetFile(assets.Logo, logo)
+		if err != nil {
+			return err
+		}
+		*logoField = encoded
+	}
+	if assets.Icon != "" {
+		encoded, err := AssetFile(assets.Icon, AppleIcon)
+		if err != nil {
+			return err
+		}
+		updated.IconImage = encoded
+	}
+	*params = updated
+	return nil
+}
+
+// ApplyLandingPageLogo loads and validates a landing page logo and stores its
+// encoded form on params
+func ApplyLandingPageLogo(params *models.CreateLandingPageParams, path string) error {
+	encoded, err := AssetFile(path, LandingPageLogo)
+	if err != nil {
+		return err
+	}
+	params.Logo = encoded
+	return nil
+}
@@ -0,0 +1,121 @@
+package images
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+func writeTestImage(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAsset_Valid(t *testing.T) {
+	data := testPNG(t, 87, 87)
+	encoded, err := Asset(bytes.NewReader(data), AppleIcon)
+	if err != nil {
+		t.Fatalf("Asset() error = %v", err)
+	}
+	if encoded != base64.StdEncoding.EncodeToString(data) {
+		t.Error("Asset() did not return the original bytes base64 encoded")
+	}
+}
+
+func TestAsset_ReportsAllProblems(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 30)), nil)
+
+	_, err := Asset(&buf, AppleIcon)
+	var assetErr *AssetError
+	if !errors.As(err, &assetErr) {
+		t.Fatalf("Asset() error = %v, want *AssetError", err)
+	}
+	if assetErr.Asset != "Apple icon" {
+		t.Errorf("Asset = %q, want Apple icon", assetErr.Asset)
+	}
+	// Wrong format, too narrow, too short and not square
+	if len(assetErr.Problems) != 4 {
+		t.Errorf("got %d problems, want 4: %v", len(assetErr.Problems), assetErr.Problems)
+	}
+	if !strings.Contains(err.Error(), "re-export it as png") {
+		t.Errorf("expected format hint, got: %s", err.Error())
+	}
+}
+
+func TestAsset_NotAnImage(t *testing.T) {
+	_, err := Asset(strings.NewReader("<svg/>"), LandingPageLogo)
+	var assetErr *AssetError
+	if !errors.As(err, &assetErr) {
+		t.Fatalf("Asset() error = %v, want *AssetError", err)
+	}
+}
+
+func TestApplyTemplateAssets_Apple(t *testing.T) {
+	params := models.CreateTemplateParams{Name: "Badge", Platform: "apple"}
+	err := ApplyTemplateAssets(&params, TemplateAssets{
+		Background: writeTestImage(t, "bg.png", testPNG(t, 360, 440)),
+		Logo:       writeTestImage(t, "logo.png", testPNG(t, 320, 100)),
+		Icon:       writeTestImage(t, "icon.png", testPNG(t, 87, 87)),
+	})
+	if err != nil {
+		t.Fatalf("ApplyTemplateAssets() error = %v", err)
+	}
+	if params.BackgroundImage == "" || params.LogoImage == "" || params.IconImage == "" {
+		t.Errorf("expected all Apple image fields to be set, got %+v", params)
+	}
+	if params.Logo != "" {
+		t.Error("expected Logo to stay empty for Apple templates")
+	}
+}
+
+func TestApplyTemplateAssets_FailureLeavesParams(t *testing.T) {
+	params := models.CreateTemplateParams{Name: "Badge", Platform: "apple"}
+	err := ApplyTemplateAssets(&params, TemplateAssets{
+		Background: writeTestImage(t, "bg.png", testPNG(t, 360, 440)),
+		Icon:       writeTestImage(t, "icon.png", testPNG(t, 40, 40)),
+	})
+	if err == nil {
+		t.Fatal("expected error for a small icon, got nil")
+	}
+	if params.BackgroundImage != "" || params.IconImage != "" {
+		t.Error("params were changed by a failed ApplyTemplateAssets()")
+	}
+}
+
+func TestApplyTemplateAssets_Google(t *testing.T) {
+	params := models.CreateTemplateParams{Name: "Badge", Platform: "android"}
+	err := ApplyTemplateAssets(&params, TemplateAssets{
+		Logo: writeTestImage(t, "logo.png", testPNG(t, 500, 500)),
+	})
+	if err == nil || !strings.Contains(err.Error(), "below the minimum of 660px") {
+		t.Fatalf("ApplyTemplateAssets() error = %v, want minimum size error", err)
+	}
+
+	err = ApplyTemplateAssets(&params, TemplateAssets{Icon: "icon.png"})
+	if err == nil {
+		t.Fatal("expected error for icon on Google template, got nil")
+	}
+}
+
+func TestApplyLandingPageLogo(t *testing.T) {
+	params := models.CreateLandingPageParams{Name: "Lobby", Kind: "universal"}
+	if err := ApplyLandingPageLogo(&params, writeTestImage(t, "logo.png", testPNG(t, 200, 80))); err != nil {
+		t.Fatalf("ApplyLandingPageLogo() error = %v", err)
+	}
+	if params.Logo == "" {
+		t.Error("expected Logo to be set")
+	}
+}
@@ -0,0 +1,119 @@
+// Package images prepares image data for the AccessGrid API
+package images
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+
+	// Register the PNG decoder with image.Decode
+	_ "image/png"
+)
+
+const (
+	// DefaultPhotoWidth is the width in pixels of an employee photo on a wallet pass
+	DefaultPhotoWidth = 300
+	// DefaultPhotoHeight is the height in pixels of an employee photo on a wallet pass
+	DefaultPhotoHeight = 400
+	// DefaultPhotoMaxBytes is the largest encoded photo accepted before base64 encoding
+	DefaultPhotoMaxBytes = 100 * 1024
+
+	minJPEGQuality = 40
+	maxJPEGQuality = 90
+)
+
+// ErrPhotoTooLarge is returned when a photo cannot be encoded within the size budget
+var ErrPhotoTooLarge = errors.New("photo exceeds size budget")
+
+// PhotoOptions controls how an employee photo is processed.
+// Zero values fall back to the package defaults.
+type PhotoOptions struct {
+	Width    int
+	Height   int
+	MaxBytes int
+}
+
+func (o PhotoOptions) withDefaults() PhotoOptions {
+	if o.Width <= 0 {
+		o.Width = DefaultPhotoWidth
+	}
+	if o.Height <= 0 {
+		o.Height = DefaultPhotoHeight
+	}
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = DefaultPhotoMaxBytes
+	}
+	return o
+}
+
+// EmployeePhoto decodes a JPEG or PNG image, crops it to the target aspect
+// ratio around its center, resizes it and re-encodes it as JPEG within the
+// size budget. The result is base64 encoded and ready for
+// ProvisionParams.EmployeePhoto or UpdateParams.EmployeePhoto.
+func EmployeePhoto(r io.Reader, opts PhotoOptions) (string, error) {
+	opts = opts.withDefaults()
+
+	src, format, err := image.Decode(r)
+	if err != nil {
+		return "", fmt.Errorf("error decoding photo: %w", err)
+	}
+	if format != "jpeg" && format != "png" {
+		return "", fmt.Errorf("unsupported photo format %q, want jpeg or png", format)
+	}
+
+	img := Resize(CropToAspect(src, opts.Width, opts.Height), opts.Width, opts.Height)
+	flattenOnWhite(img)
+
+	data, err := encodeJPEGWithin(img, opts.MaxBytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// EmployeePhotoFile is like EmployeePhoto but reads the image from a file
+func EmployeePhotoFile(path string, opts PhotoOptions) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening photo: %w", err)
+	}
+	defer f.Close()
+	return EmployeePhoto(f, opts)
+}
+
+// flattenOnWhite composites img over a white background in place, since JPEG
+// has no alpha channel and transparent PNG regions would otherwise turn black
+func flattenOnWhite(img *image.RGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		a := uint32(img.Pix[i+3])
+		if a == 0xff {
+			continue
+		}
+		bg := 0xff - a
+		img.Pix[i] = uint8(uint32(img.Pix[i]) + bg)
+		img.Pix[i+1] = uint8(uint32(img.Pix[i+1]) + bg)
+		img.Pix[i+2] = uint8(uint32(img.Pix[i+2]) + bg)
+		img.Pix[i+3] = 0xff
+	}
+}
+
+// encodeJPEGWithin encodes img as JPEG, lowering the quality until the
+// output fits in maxBytes
+func encodeJPEGWithin(img image.Image, maxBytes int) ([]byte, error) {
+	var buf bytes.Buffer
+	for quality := maxJPEGQuality; quality >= minJPEGQuality; quality -= 10 {
+		buf.Reset()
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("error encoding photo: %w", err)
+		}
+		if buf.Len() <= maxBytes {
+			return buf.Bytes(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d bytes at lowest quality, budget is %d", ErrPhotoTooLarge, buf.Len(), maxBytes)
+}
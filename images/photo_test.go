@@ -0,0 +1,107 @@
+package images
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decodeResult(t *testing.T, encoded string) image.Image {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("result is not valid base64: %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("result is not a JPEG: %v", err)
+	}
+	return img
+}
+
+func TestEmployeePhoto_CropsAndResizes(t *testing.T) {
+	encoded, err := EmployeePhoto(bytes.NewReader(testPNG(t, 800, 600)), PhotoOptions{})
+	if err != nil {
+		t.Fatalf("EmployeePhoto() error = %v", err)
+	}
+
+	img := decodeResult(t, encoded)
+	if img.Bounds().Dx() != DefaultPhotoWidth || img.Bounds().Dy() != DefaultPhotoHeight {
+		t.Errorf("size = %v, want %dx%d", img.Bounds().Size(), DefaultPhotoWidth, DefaultPhotoHeight)
+	}
+}
+
+func TestEmployeePhotoFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.png")
+	if err := os.WriteFile(path, testPNG(t, 120, 120), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := EmployeePhotoFile(path, PhotoOptions{Width: 64, Height: 64})
+	if err != nil {
+		t.Fatalf("EmployeePhotoFile() error = %v", err)
+	}
+
+	img := decodeResult(t, encoded)
+	if img.Bounds().Dx() != 64 || img.Bounds().Dy() != 64 {
+		t.Errorf("size = %v, want 64x64", img.Bounds().Size())
+	}
+}
+
+func TestEmployeePhoto_SizeBudget(t *testing.T) {
+	// Random noise does not compress, so no quality fits a tiny budget
+	img := image.NewRGBA(image.Rect(0, 0, 300, 400))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+
+	_, err := EmployeePhoto(&buf, PhotoOptions{MaxBytes: 1024})
+	if !errors.Is(err, ErrPhotoTooLarge) {
+		t.Errorf("EmployeePhoto() error = %v, want ErrPhotoTooLarge", err)
+	}
+}
+
+func TestEmployeePhoto_InvalidImage(t *testing.T) {
+	_, err := EmployeePhoto(strings.NewReader("not an image"), PhotoOptions{})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "error decoding photo") {
+		t.Errorf("expected wrapped message, got: %s", err.Error())
+	}
+}
+
+func TestCropToAspect(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	cropped := CropToAspect(img, 3, 4)
+	if cropped.Bounds().Dx() != 450 || cropped.Bounds().Dy() != 600 {
+		t.Errorf("cropped size = %v, want 450x600", cropped.Bounds().Size())
+	}
+	if cropped.Bounds().Min.X != 175 {
+		t.Errorf("cropped origin = %v, want x=175", cropped.Bounds().Min)
+	}
+}
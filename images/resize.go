@@ -0,0 +1,86 @@
+package images
+
+import (
+	"image"
+	"image/color"
+)
+
+// CropToAspect returns the largest centered region of img with the aspect
+// ratio width:height
+func CropToAspect(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	if srcW == 0 || srcH == 0 || width <= 0 || height <= 0 {
+		return img
+	}
+
+	cropW, cropH := srcW, srcH
+	if srcW*height > srcH*width {
+		cropW = srcH * width / height
+	} else {
+		cropH = srcW * height / width
+	}
+	x0 := b.Min.X + (srcW-cropW)/2
+	y0 := b.Min.Y + (srcH-cropH)/2
+	rect := image.Rect(x0, y0, x0+cropW, y0+cropH)
+
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, cropW, cropH))
+	for y := 0; y < cropH; y++ {
+		for x := 0; x < cropW; x++ {
+			dst.Set(x, y, img.At(x0+x, y0+y))
+		}
+	}
+	return dst
+}
+
+// Resize scales img to exactly width x height. Each destination pixel is the
+// average of the source pixels it covers, which keeps downscaled photos
+// free of aliasing.
+func Resize(img image.Image, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	if srcW == 0 || srcH == 0 {
+		return dst
+	}
+
+	for y := 0; y < height; y++ {
+		sy0 := b.Min.Y + y*srcH/height
+		sy1 := b.Min.Y + (y+1)*srcH/height
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < width; x++ {
+			sx0 := b.Min.X + x*srcW/width
+			sx1 := b.Min.X + (x+1)*srcW/width
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
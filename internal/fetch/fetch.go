@@ -0,0 +1,46 @@
+// Package fetch downloads images referenced by URL in API responses.
+package fetch
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MaxBytes caps a download. The API's largest images are a few hundred
+// kilobytes.
+const MaxBytes = 10 << 20
+
+// Base64 returns an image as base64. Images served by URL are downloaded
+// with client, up to MaxBytes; any other value is already encoded and is
+// returned as is.
+func Base64(ctx context.Context, client *http.Client, value string) (string, error) {
+	if !strings.HasPrefix(value, "https://") && !strings.HasPrefix(value, "http://") {
+		return value, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, value, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request for %s: %w", value, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error downloading %s: %w", value, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error downloading %s: status %d", value, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("error downloading %s: %w", value, err)
+	}
+	if len(data) > MaxBytes {
+		return "", fmt.Errorf("error downloading %s: larger than %d bytes", value, MaxBytes)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,40 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBase64(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/photo.jpg":
+			w.Write([]byte("jpeg bytes"))
+		case "/huge.jpg":
+			w.Write(bytes.Repeat([]byte{0}, MaxBytes+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	got, err := Base64(ctx, server.Client(), server.URL+"/photo.jpg")
+	if err != nil || got != base64.StdEncoding.EncodeToString([]byte("jpeg bytes")) {
+		t.Errorf("Base64() = %q, %v", got, err)
+	}
+	if got, err := Base64(ctx, server.Client(), "aGVsbG8="); err != nil || got != "aGVsbG8=" {
+		t.Errorf("Base64() of encoded data = %q, %v", got, err)
+	}
+	if _, err := Base64(ctx, server.Client(), server.URL+"/huge.jpg"); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("Base64() error = %v, want a size error", err)
+	}
+	if _, err := Base64(ctx, server.Client(), server.URL+"/missing.jpg"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Base64() error = %v, want a status error", err)
+	}
+}
@@ -0,0 +1,583 @@
+// Package yaml converts YAML documents to JSON.
+//
+// It reads the subset of YAML that configuration files use: block
+// mappings and sequences, flow collections on one line, plain, quoted and
+// block scalars, and comments. Scalars are typed by the YAML 1.2 core
+// schema, so quote strings that look like numbers or booleans. Anchors,
+// aliases, tags and multiple documents are rejected.
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ToJSON converts a YAML document to JSON. An empty document is null.
+func ToJSON(data []byte) ([]byte, error) {
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n")
+	p := &parser{lines: strings.Split(text, "\n")}
+	if err := p.documentStart(); err != nil {
+		return nil, err
+	}
+
+	value, err := p.block(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.documentEnd(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+type parser struct {
+	lines []string
+	pos   int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("yaml: line %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// documentStart skips blank lines and an optional --- marker
+func (p *parser) documentStart() error {
+	if !p.skip() {
+		return nil
+	}
+	if line := p.lines[p.pos]; line == "---" || strings.HasPrefix(line, "--- ") {
+		if rest := stripComment(strings.TrimPrefix(line, "---")); rest != "" {
+			return p.errorf("content after --- is not supported")
+		}
+		p.pos++
+	}
+	return nil
+}
+
+// documentEnd checks that nothing but an optional ... marker follows the
+// document
+func (p *parser) documentEnd() error {
+	if !p.skip() {
+		return nil
+	}
+	switch line := p.lines[p.pos]; {
+	case strings.HasPrefix(line, "---"):
+		return p.errorf("multiple documents are not supported")
+	case strings.HasPrefix(line, "..."):
+		p.pos++
+		if p.skip() {
+			return p.errorf("content after the end of the document")
+		}
+		return nil
+	default:
+		return p.errorf("unexpected content %q", strings.TrimSpace(line))
+	}
+}
+
+// next skips blank and comment lines and reports whether a line of the
+// document remains
+func (p *parser) next() bool {
+	return p.skip() && !isMarker(p.lines[p.pos])
+}
+
+// skip skips blank and comment lines and reports whether any line remains
+func (p *parser) skip() bool {
+	for ; p.pos < len(p.lines); p.pos++ {
+		if stripComment(p.lines[p.pos]) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// isMarker reports whether a line starts or ends a document
+func isMarker(line string) bool {
+	return line == "---" || strings.HasPrefix(line, "--- ") || line == "..." || strings.HasPrefix(line, "... ")
+}
+
+// line returns the indentation and content of the current line
+func (p *parser) line() (int, string, error) {
+	raw := p.lines[p.pos]
+	content := strings.TrimLeft(raw, " ")
+	if strings.HasPrefix(content, "\t") {
+		return 0, "", p.errorf("tabs cannot indent YAML")
+	}
+	return len(raw) - len(content), stripComment(content), nil
+}
+
+// block parses the node starting at the next line, if it is indented at
+// least minIndent
+func (p *parser) block(minIndent int) (interface{}, error) {
+	if !p.next() {
+		return nil, nil
+	}
+	indent, content, err := p.line()
+	if err != nil {
+		return nil, err
+	}
+	if indent < minIndent {
+		return nil, nil
+	}
+	switch {
+	case isSequenceItem(content):
+		return p.sequence(indent)
+	case mappingKeyEnd(content) >= 0:
+		return p.mapping(indent)
+	default:
+		p.pos++
+		return p.inline(content, indent-1)
+	}
+}
+
+func isSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+// sequence parses the items of a block sequence at indent
+func (p *parser) sequence(indent int) ([]interface{}, error) {
+	items := []interface{}{}
+	for p.next() {
+		itemIndent, content, err := p.line()
+		if err != nil {
+			return nil, err
+		}
+		if itemIndent < indent || (itemIndent == indent && !isSequenceItem(content)) {
+			break
+		}
+		if itemIndent > indent || !isSequenceItem(content) {
+			return nil, p.errorf("unexpected indentation")
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.block(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+
+		// A nested collection starting on the item's line is parsed as if
+		// it started on a line of its own, indented to where it begins
+		restIndent := indent + len(content) - len(rest)
+		if isSequenceItem(rest) || mappingKeyEnd(rest) >= 0 {
+			p.lines[p.pos] = strings.Repeat(" ", restIndent) + rest
+			item, err := p.block(restIndent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		p.pos++
+		item, err := p.inline(rest, indent)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// mapping parses the entries of a block mapping at indent
+func (p *parser) mapping(indent int) (map[string]interface{}, error) {
+	entries := map[string]interface{}{}
+	for p.next() {
+		entryIndent, content, err := p.line()
+		if err != nil {
+			return nil, err
+		}
+		if entryIndent < indent {
+			break
+		}
+		end := mappingKeyEnd(content)
+		if entryIndent > indent || end < 0 {
+			if entryIndent == indent && isSequenceItem(content) {
+				break
+			}
+			return nil, p.errorf("unexpected indentation or a missing colon")
+		}
+
+		key, err := p.key(content[:end])
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := entries[key]; ok {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		rest := strings.TrimSpace(content[end+1:])
+		p.pos++
+
+		var value interface{}
+		if rest == "" {
+			// A sequence may sit at the key's own indentation
+			if p.next() {
+				nextIndent, next, err := p.line()
+				if err != nil {
+					return nil, err
+				}
+				if nextIndent == indent && isSequenceItem(next) {
+					value, err = p.sequence(indent)
+				} else {
+					value, err = p.block(indent + 1)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		} else if value, err = p.inline(rest, indent); err != nil {
+			return nil, err
+		}
+		entries[key] = value
+	}
+	return entries, nil
+}
+
+// key decodes a mapping key
+func (p *parser) key(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", p.errorf("empty key")
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		v, n, err := quoted(text)
+		if err != nil || n != len(text) {
+			return "", p.errorf("invalid quoted key %s", text)
+		}
+		return v, nil
+	}
+	if strings.ContainsAny(text[:1], "[{&*!|>?") {
+		return "", p.errorf("complex keys are not supported: %s", text)
+	}
+	return text, nil
+}
+
+// mappingKeyEnd returns the index of the colon ending a mapping key on
+// the line, or -1 when the line is not a mapping entry
+func mappingKeyEnd(content string) int {
+	if content == "" || strings.ContainsAny(content[:1], "[{") {
+		return -1
+	}
+	start := 0
+	if content[0] == '"' || content[0] == '\'' {
+		_, n, err := quoted(content)
+		if err != nil {
+			return -1
+		}
+		start = n
+	}
+	for i := start; i < len(content); i++ {
+		if content[i] == ':' && (i+1 == len(content) || content[i+1] == ' ') {
+			return i
+		}
+	}
+	return -1
+}
+
+// inline parses a value written after a key or sequence marker. Block
+// scalars continue on the lines indented deeper than parentIndent.
+func (p *parser) inline(text string, parentIndent int) (interface{}, error) {
+	switch text[0] {
+	case '&', '*', '!':
+		p.pos--
+		return nil, p.errorf("anchors, aliases and tags are not supported")
+	case '|', '>':
+		return p.blockScalar(text, parentIndent)
+	case '[', '{':
+		f := &flow{text: text}
+		value, err := f.value()
+		if err == nil && f.skipSpace() < len(text) {
+			err = fmt.Errorf("unexpected %q", text[f.pos:])
+		}
+		if err != nil {
+			p.pos--
+			return nil, p.errorf("%v; flow collections must fit on one line", err)
+		}
+		return value, nil
+	case '"', '\'':
+		v, n, err := quoted(text)
+		if err != nil || n != len(text) {
+			p.pos--
+			return nil, p.errorf("invalid quoted string %s; quoted strings must fit on one line", text)
+		}
+		return v, nil
+	}
+	v, err := plain(text)
+	if err != nil {
+		p.pos--
+		return nil, p.errorf("%v", err)
+	}
+	return v, nil
+}
+
+// blockScalar reads a literal (|) or folded (>) scalar
+func (p *parser) blockScalar(header string, parentIndent int) (string, error) {
+	literal := header[0] == '|'
+	chomp := byte(0)
+	for _, c := range header[1:] {
+		switch {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = byte(c)
+		default:
+			p.pos--
+			return "", p.errorf("unsupported block scalar header %q", header)
+		}
+	}
+
+	var lines []string
+	indent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := p.lines[p.pos]
+		content := strings.TrimLeft(raw, " ")
+		lineIndent := len(raw) - len(content)
+		if content == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if indent < 0 {
+			if lineIndent <= parentIndent {
+				break
+			}
+			indent = lineIndent
+		}
+		if lineIndent < indent {
+			break
+		}
+		lines = append(lines, raw[indent:])
+	}
+
+	// Trailing blank lines belong to the scalar only when kept
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			// Folding joins lines with a space, except around blank and
+			// more indented lines
+			prev := lines[i-1]
+			moreIndented := strings.HasPrefix(line, " ") || strings.HasPrefix(prev, " ")
+			switch {
+			case literal || prev == "" || moreIndented:
+				b.WriteByte('\n')
+			case line == "":
+				// The blank line itself stands for the break
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	switch chomp {
+	case '-':
+	case '+':
+		b.WriteString(strings.Repeat("\n", trailing+1))
+	default:
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// stripComment removes a comment and surrounding space from a line
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			// Quotes only open a string at the start of a token
+			if i == 0 || strings.ContainsRune(" [{,:-", rune(line[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return strings.TrimSpace(line[:i])
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+// quoted decodes the quoted string at the start of text and returns its
+// length in text
+func quoted(text string) (string, int, error) {
+	if text[0] == '\'' {
+		var b strings.Builder
+		for i := 1; i < len(text); i++ {
+			if text[i] != '\'' {
+				b.WriteByte(text[i])
+				continue
+			}
+			if i+1 < len(text) && text[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), i + 1, nil
+		}
+		return "", 0, fmt.Errorf("unterminated string")
+	}
+
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			s, err := strconv.Unquote(text[:i+1])
+			return s, i + 1, err
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+var (
+	intPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	floatPattern = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// plain types a plain scalar by the core schema
+func plain(text string) (interface{}, error) {
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case ".inf", ".Inf", ".INF", "+.inf", "-.inf", ".nan", ".NaN", ".NAN":
+		return nil, fmt.Errorf("%s has no JSON form", text)
+	}
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0o") {
+		base := 16
+		if text[1] == 'o' {
+			base = 8
+		}
+		if n, err := strconv.ParseInt(text[2:], base, 64); err == nil {
+			return json.Number(strconv.FormatInt(n, 10)), nil
+		}
+	}
+	if intPattern.MatchString(text) || floatPattern.MatchString(text) {
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("number %s is out of range", text)
+		}
+		number := strings.TrimPrefix(text, "+")
+		if intPattern.MatchString(number) {
+			// JSON has no leading zeros
+			negative := strings.HasPrefix(number, "-")
+			digits := strings.TrimLeft(strings.TrimPrefix(number, "-"), "0")
+			if digits == "" {
+				digits = "0"
+			}
+			if negative && digits != "0" {
+				digits = "-" + digits
+			}
+			return json.Number(digits), nil
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	}
+	if strings.ContainsAny(text[:1], "@`%") {
+		return nil, fmt.Errorf("plain scalars cannot start with %q; quote the value", text[:1])
+	}
+	return text, nil
+}
+
+// flow parses a flow collection on one line
+type flow struct {
+	text string
+	pos  int
+}
+
+func (f *flow) skipSpace() int {
+	for f.pos < len(f.text) && f.text[f.pos] == ' ' {
+		f.pos++
+	}
+	return f.pos
+}
+
+func (f *flow) value() (interface{}, error) {
+	if f.skipSpace() >= len(f.text) {
+		return nil, fmt.Errorf("unexpected end of line")
+	}
+	switch c := f.text[f.pos]; c {
+	case '[', '{':
+		f.pos++
+		closing := byte(']')
+		if c == '{' {
+			closing = '}'
+		}
+		items := []interface{}{}
+		entries := map[string]interface{}{}
+		for {
+			if f.skipSpace() < len(f.text) && f.text[f.pos] == closing {
+				f.pos++
+				if c == '{' {
+					return entries, nil
+				}
+				return items, nil
+			}
+			item, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			if c == '{' {
+				key, ok := item.(string)
+				if !ok || f.skipSpace() >= len(f.text) || f.text[f.pos] != ':' {
+					return nil, fmt.Errorf("flow mapping entries need a string key and a colon")
+				}
+				f.pos++
+				if item, err = f.value(); err != nil {
+					return nil, err
+				}
+				if _, ok := entries[key]; ok {
+					return nil, fmt.Errorf("duplicate key %q", key)
+				}
+				entries[key] = item
+			} else {
+				items = append(items, item)
+			}
+			if f.skipSpace() < len(f.text) && f.text[f.pos] == ',' {
+				f.pos++
+				continue
+			}
+			if f.pos >= len(f.text) || f.text[f.pos] != closing {
+				return nil, fmt.Errorf("missing %q", closing)
+			}
+		}
+	case '"', '\'':
+		s, n, err := quoted(f.text[f.pos:])
+		if err != nil {
+			return nil, err
+		}
+		f.pos += n
+		return s, nil
+	case '&', '*', '!':
+		return nil, fmt.Errorf("anchors, aliases and tags are not supported")
+	}
+
+	start := f.pos
+	for f.pos < len(f.text) {
+		c := f.text[f.pos]
+		if c == ',' || c == ']' || c == '}' || (c == ':' && (f.pos+1 == len(f.text) || f.text[f.pos+1] == ' ')) {
+			break
+		}
+		f.pos++
+	}
+	return plain(strings.TrimSpace(f.text[start:f.pos]))
+}
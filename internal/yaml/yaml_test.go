@@ -0,0 +1,67 @@
+package yaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"empty", "# nothing\n", `null`},
+		{"mapping", "name: Badge\ncount: 2\n", `{"count":2,"name":"Badge"}`},
+		{"nested mapping", "a:\n  b:\n    c: 1\n  d: x\n", `{"a":{"b":{"c":1},"d":"x"}}`},
+		{"sequence", "- 1\n- two\n-\n  - 3\n", `[1,"two",[3]]`},
+		{"sequence at key indent", "items:\n- a\n- b\nnext: 1\n", `{"items":["a","b"],"next":1}`},
+		{"compact items", "items:\n  - name: a\n    n: 1\n  - name: b\n", `{"items":[{"n":1,"name":"a"},{"name":"b"}]}`},
+		{"nested compact items", "- - a\n  - b\n- c\n", `[["a","b"],"c"]`},
+		{"document markers", "---\na: 1\n...\n", `{"a":1}`},
+		{"scalars", "a: ~\nb: true\nc: False\nd: 0x1f\ne: 1.5e3\nf: 007\ng: -0\nh: yes\ni: 1.0\n",
+			`{"a":null,"b":true,"c":false,"d":31,"e":1500,"f":7,"g":0,"h":"yes","i":1}`},
+		{"quoted", `a: "x: \"y\" # z"` + "\nb: 'it''s'\n'c d': \"\\u00e9\"\n", `{"a":"x: \"y\" # z","b":"it's","c d":"é"}`},
+		{"comments", "a: b # comment\n# full line\nc: d#e\n", `{"a":"b","c":"d#e"}`},
+		{"url", "url: https://example.com/hook\n", `{"url":"https://example.com/hook"}`},
+		{"flow", "a: [1, b, {c: d, 'e': [f]}]\nb: {}\nc: []\n", `{"a":[1,"b",{"c":"d","e":["f"]}],"b":{},"c":[]}`},
+		{"literal", "a: |\n  one\n    two\n\nb: 1\n", `{"a":"one\n  two\n","b":1}`},
+		{"folded", "a: >-\n  one\n  two\n\n  three\n", `{"a":"one two\nthree"}`},
+		{"keep", "a: |+\n  one\n\nb: 1\n", `{"a":"one\n\n","b":1}`},
+		{"windows newlines", "a: 1\r\nb: 2\r\n", `{"a":1,"b":2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToJSON([]byte(tt.yaml))
+			if err != nil || string(got) != tt.want {
+				t.Errorf("ToJSON() = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestToJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"tab", "a:\n\tb: 1\n", "line 2: tabs"},
+		{"duplicate key", "a: 1\na: 2\n", `line 2: duplicate key "a"`},
+		{"anchor", "a: &x 1\nb: *x\n", "line 1: anchors"},
+		{"documents", "a: 1\n---\nb: 2\n", "line 2: multiple documents"},
+		{"unclosed flow", "a: [1, 2\n", "line 1: missing"},
+		{"unclosed string", "a: \"b\n", "line 1: invalid quoted string"},
+		{"indentation", "a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"missing colon", "a: 1\nb\n", "line 2: unexpected indentation or a missing colon"},
+		{"infinity", "a: .inf\n", "line 1: .inf has no JSON form"},
+		{"reserved", "a: @b\n", "line 1: plain scalars cannot start"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ToJSON([]byte(tt.yaml)); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ToJSON() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Access-Grid/accessgrid-go/internal/atomicfile"
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+const (
+	escrowVersion   = 1
+	escrowAlgorithm = "AES-256-GCM"
+
+	// WrappingKeyLength is the length in bytes of an escrow wrapping key
+	WrappingKeyLength = 32
+)
+
+// escrowFile is the on-disk format of an escrow file. Each key is sealed
+// separately with its label, type and version as additional data, so
+// metadata cannot be swapped between entries without detection.
+type escrowFile struct {
+	Version   int           `json:"version"`
+	Algorithm string        `json:"algorithm"`
+	Keys      []escrowEntry `json:"keys"`
+}
+
+type escrowEntry struct {
+	Label      string         `json:"label"`
+	Type       models.KeyType `json:"key_type"`
+	KeyVersion int            `json:"key_version"`
+	Nonce      string         `json:"nonce"`
+	Ciphertext string         `json:"ciphertext"`
+}
+
+func (e escrowEntry) additionalData() []byte {
+	return []byte(fmt.Sprintf("%s\x00%s\x00%d", e.Label, e.Type, e.KeyVersion))
+}
+
+// GenerateWrappingKey creates a random key for WriteEscrowFile. Store it
+// apart from the escrow file, for example in a KMS or HSM.
+func GenerateWrappingKey() ([]byte, error) {
+	wrappingKey := make([]byte, WrappingKeyLength)
+	if _, err := rand.Read(wrappingKey); err != nil {
+		return nil, fmt.Errorf("error generating wrapping key: %w", err)
+	}
+	return wrappingKey, nil
+}
+
+func newGCM(wrappingKey []byte) (cipher.AEAD, error) {
+	if len(wrappingKey) != WrappingKeyLength {
+		return nil, fmt.Errorf("wrapping key must be %d bytes, got %d", WrappingKeyLength, len(wrappingKey))
+	}
+	block, err := aes.NewCipher(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// WriteEscrowFile encrypts keys with a 32 byte wrapping key and writes them
+// to path, readable only by the owner
+func WriteEscrowFile(path string, wrappingKey []byte, keys []*Key) error {
+	gcm, err := newGCM(wrappingKey)
+	if err != nil {
+		return err
+	}
+
+	file := escrowFile{Version: escrowVersion, Algorithm: escrowAlgorithm}
+	for _, key := range keys {
+		if key.material == nil {
+			return fmt.Errorf("key %q has been destroyed", key.Label)
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return fmt.Errorf("error generating nonce: %w", err)
+		}
+
+		entry := escrowEntry{Label: key.Label, Type: key.Type, KeyVersion: key.Version}
+		sealed := gcm.Seal(nil, nonce, key.material, entry.additionalData())
+		entry.Nonce = base64.StdEncoding.EncodeToString(nonce)
+		entry.Ciphertext = base64.StdEncoding.EncodeToString(sealed)
+		file.Keys = append(file.Keys, entry)
+	}
+
+	// A new file is written and renamed over path, so an existing file
+	// with a looser mode is replaced rather than reused
+	if err := atomicfile.WriteJSON(path, file); err != nil {
+		return fmt.Errorf("error writing escrow file: %w", err)
+	}
+	return nil
+}
+
+// ReadEscrowFile decrypts the keys of an escrow file
+func ReadEscrowFile(path string, wrappingKey []byte) ([]*Key, error) {
+	gcm, err := newGCM(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading escrow file: %w", err)
+	}
+	var file escrowFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing escrow file: %w", err)
+	}
+	if file.Version != escrowVersion || file.Algorithm != escrowAlgorithm {
+		return nil, fmt.Errorf("unsupported escrow file version %d (%s)", file.Version, file.Algorithm)
+	}
+
+	keys := make([]*Key, 0, len(file.Keys))
+	for _, entry := range file.Keys {
+		nonce, err := base64.StdEncoding.DecodeString(entry.Nonce)
+		if err != nil || len(nonce) != gcm.NonceSize() {
+			return nil, fmt.Errorf("escrowed key %q has an invalid nonce", entry.Label)
+		}
+		sealed, err := base64.StdEncoding.DecodeString(entry.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("escrowed key %q has invalid ciphertext", entry.Label)
+		}
+		material, err := gcm.Open(nil, nonce, sealed, entry.additionalData())
+		if err != nil {
+			return nil, fmt.Errorf("escrowed key %q could not be decrypted; check the wrapping key", entry.Label)
+		}
+		keys = append(keys, &Key{Label: entry.Label, Type: entry.Type, Version: entry.KeyVersion, material: material})
+	}
+	return keys, nil
+}
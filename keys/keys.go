@@ -0,0 +1,106 @@
+// Package keys generates key material for credential profiles.
+//
+// Keys are created with crypto/rand and kept out of logs: a Key redacts
+// itself when formatted and refuses to be marshaled to JSON. The material
+// only leaves a Key through KeyParam, for the API, or encrypted in an
+// escrow file.
+package keys
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// ErrPlaintextExport is returned when a Key is marshaled to JSON
+var ErrPlaintextExport = errors.New("keys: refusing to export key material in plain text")
+
+// Key is a credential profile key
+type Key struct {
+	Label   string
+	Type    models.KeyType
+	Version int
+
+	material []byte
+}
+
+// Generate creates a random key of the given type
+func Generate(label string, keyType models.KeyType) (*Key, error) {
+	length := keyType.KeyLength()
+	if length == 0 {
+		return nil, fmt.Errorf("unknown key type %q", keyType)
+	}
+
+	material := make([]byte, length)
+	if _, err := rand.Read(material); err != nil {
+		return nil, fmt.Errorf("error generating key: %w", err)
+	}
+	return &Key{Label: label, Type: keyType, material: material}, nil
+}
+
+// GenerateProfileKeys creates one key of the given type per label, in
+// order, ready for CreateCredentialProfileParams.Keys
+func GenerateProfileKeys(keyType models.KeyType, labels ...string) ([]*Key, error) {
+	keys := make([]*Key, 0, len(labels))
+	for _, label := range labels {
+		key, err := Generate(label, keyType)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// KeyParams formats keys as credential profile key parameters
+func KeyParams(keys []*Key) []models.KeyParam {
+	params := make([]models.KeyParam, 0, len(keys))
+	for _, key := range keys {
+		params = append(params, key.KeyParam())
+	}
+	return params
+}
+
+// KeyParam formats the key as the API expects it
+func (k *Key) KeyParam() models.KeyParam {
+	return models.KeyParam{
+		Value:   hex.EncodeToString(k.material),
+		KeyType: k.Type,
+		Version: k.Version,
+	}
+}
+
+// String redacts the key material
+func (k Key) String() string {
+	return fmt.Sprintf("Key{Label: %s, Type: %s, Version: %d, Value: [redacted]}", k.Label, k.Type, k.Version)
+}
+
+// GoString redacts the key material when formatted with %#v
+func (k Key) GoString() string {
+	return k.String()
+}
+
+// Format redacts the key material with every verb, including those such
+// as %d and %x that would otherwise print the fields of a Key value
+func (k Key) Format(f fmt.State, verb rune) {
+	io.WriteString(f, k.String())
+}
+
+// MarshalJSON always fails so keys cannot be serialized by accident.
+// Use WriteEscrowFile to store keys.
+func (k Key) MarshalJSON() ([]byte, error) {
+	return nil, ErrPlaintextExport
+}
+
+// Destroy overwrites the key material in memory. The key is unusable
+// afterwards.
+func (k *Key) Destroy() {
+	for i := range k.material {
+		k.material[i] = 0
+	}
+	k.material = nil
+}
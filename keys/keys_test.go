@@ -0,0 +1,156 @@
+package keys
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		keyType models.KeyType
+		hexLen  int
+	}{
+		{models.KeyTypeAES128, 32},
+		{models.KeyType2K3DES, 32},
+		{models.KeyType3K3DES, 48},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.keyType), func(t *testing.T) {
+			key, err := Generate("master", tt.keyType)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			param := key.KeyParam()
+			if len(param.Value) != tt.hexLen {
+				t.Errorf("len(Value) = %d, want %d", len(param.Value), tt.hexLen)
+			}
+			if param.KeyType != tt.keyType {
+				t.Errorf("KeyType = %v, want %v", param.KeyType, tt.keyType)
+			}
+		})
+	}
+
+	if _, err := Generate("master", "des"); err == nil {
+		t.Error("expected error for unknown key type, got nil")
+	}
+}
+
+func TestGenerateProfileKeys_ValidProfile(t *testing.T) {
+	keys, err := GenerateProfileKeys(models.KeyTypeAES128, "master", "read")
+	if err != nil {
+		t.Fatalf("GenerateProfileKeys() error = %v", err)
+	}
+
+	params := models.CreateCredentialProfileParams{
+		Name:    "Main Office",
+		AppName: "KEY-ID-main",
+		Keys:    KeyParams(keys),
+	}
+	if err := params.Validate(); err != nil {
+		t.Errorf("generated keys failed validation: %v", err)
+	}
+}
+
+func TestKey_NeverPrintsMaterial(t *testing.T) {
+	key, _ := Generate("master", models.KeyTypeAES128)
+	value := key.KeyParam().Value
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		if out := fmt.Sprintf(format, key); strings.Contains(out, value) {
+			t.Errorf("fmt %s leaks key material: %s", format, out)
+		}
+	}
+	if out := fmt.Sprintf("%v", []*Key{key}); strings.Contains(out, value) {
+		t.Errorf("slice formatting leaks key material: %s", out)
+	}
+
+	// Values bypass pointer methods, so they are checked separately
+	material := fmt.Sprint(key.material)
+	holder := struct{ Key Key }{*key}
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%d", "%x"} {
+		for _, v := range []interface{}{*key, []Key{*key}, holder} {
+			if out := fmt.Sprintf(format, v); strings.Contains(out, value) || strings.Contains(out, material) || strings.Contains(out, strings.Trim(material, "[]")) {
+				t.Errorf("fmt %s of %T leaks key material: %s", format, v, out)
+			}
+		}
+	}
+	if _, err := json.Marshal(*key); !errors.Is(err, ErrPlaintextExport) {
+		t.Errorf("json.Marshal() of a value error = %v, want ErrPlaintextExport", err)
+	}
+
+	_, err := json.Marshal(key)
+	if !errors.Is(err, ErrPlaintextExport) {
+		t.Errorf("json.Marshal() error = %v, want ErrPlaintextExport", err)
+	}
+}
+
+func TestKey_Destroy(t *testing.T) {
+	key, _ := Generate("master", models.KeyTypeAES128)
+	material := key.material
+	key.Destroy()
+
+	if !bytes.Equal(material, make([]byte, len(material))) {
+		t.Error("Destroy() did not zero the key material")
+	}
+	if err := WriteEscrowFile(filepath.Join(t.TempDir(), "escrow.json"), make([]byte, 32), []*Key{key}); err == nil {
+		t.Error("expected error escrowing a destroyed key, got nil")
+	}
+}
+
+func TestEscrowFile_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "escrow.json")
+	wrappingKey, err := GenerateWrappingKey()
+	if err != nil {
+		t.Fatalf("GenerateWrappingKey() error = %v", err)
+	}
+
+	// An existing file's looser mode is not kept
+	if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, _ := GenerateProfileKeys(models.KeyTypeAES128, "master", "read")
+	keys[1].Version = 3
+	if err := WriteEscrowFile(path, wrappingKey, keys); err != nil {
+		t.Fatalf("WriteEscrowFile() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("escrow file permissions = %o, want 600", perm)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), keys[0].KeyParam().Value) {
+		t.Error("escrow file contains plain text key material")
+	}
+
+	restored, err := ReadEscrowFile(path, wrappingKey)
+	if err != nil {
+		t.Fatalf("ReadEscrowFile() error = %v", err)
+	}
+	if len(restored) != 2 {
+		t.Fatalf("got %d keys, want 2", len(restored))
+	}
+	for i := range keys {
+		if restored[i].KeyParam() != keys[i].KeyParam() || restored[i].Label != keys[i].Label {
+			t.Errorf("restored[%d] does not match the original key", i)
+		}
+	}
+
+	otherKey, _ := GenerateWrappingKey()
+	if _, err := ReadEscrowFile(path, otherKey); err == nil {
+		t.Error("expected error with the wrong wrapping key, got nil")
+	}
+}
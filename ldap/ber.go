@@ -0,0 +1,218 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER classes and the constructed bit of an identifier octet
+const (
+	classUniversal   = 0x00
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+)
+
+// Universal tags
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagNull        = 0x05
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x10 | constructed
+	tagSet         = 0x11 | constructed
+)
+
+// maxPacketSize bounds a single message, which may carry photos
+const maxPacketSize = 32 << 20
+
+// maxDepth bounds the nesting of constructed elements. LDAP messages nest
+// a handful of levels; filters in search requests nest deeper, but never
+// this deep in practice.
+const maxDepth = 64
+
+// packet is a decoded BER element. Only single-octet identifiers and
+// definite lengths are supported, which is all LDAP uses.
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+func (p *packet) isConstructed() bool {
+	return p.tag&constructed != 0
+}
+
+// encode serializes a packet
+func (p *packet) encode() []byte {
+	value := p.value
+	if p.isConstructed() {
+		value = nil
+		for _, child := range p.children {
+			value = append(value, child.encode()...)
+		}
+	}
+	out := []byte{p.tag}
+	out = append(out, encodeLength(len(value))...)
+	return append(out, value...)
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func newSequence(tag byte, children ...*packet) *packet {
+	return &packet{tag: tag, children: children}
+}
+
+func newString(tag byte, s string) *packet {
+	return &packet{tag: tag, value: []byte(s)}
+}
+
+func newInteger(tag byte, n int64) *packet {
+	// Minimal two's complement
+	var b []byte
+	for {
+		b = append([]byte{byte(n)}, b...)
+		if (n >= -0x80 && n < 0x80) || len(b) == 8 {
+			break
+		}
+		n >>= 8
+	}
+	return &packet{tag: tag, value: b}
+}
+
+func newBoolean(v bool) *packet {
+	if v {
+		return &packet{tag: tagBoolean, value: []byte{0xff}}
+	}
+	return &packet{tag: tagBoolean, value: []byte{0x00}}
+}
+
+// readPacket reads one element
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag&0x1f == 0x1f {
+		return nil, errors.New("ldap: multi-octet BER tags are not supported")
+	}
+
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		octets := int(first & 0x7f)
+		if octets == 0 || octets > 4 {
+			return nil, errors.New("ldap: unsupported BER length")
+		}
+		length = 0
+		for i := 0; i < octets; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("ldap: message of %d bytes is too large", length)
+	}
+
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return parsePacket(tag, value, 0)
+}
+
+// parsePacket decodes the children of a constructed element at the given
+// nesting depth
+func parsePacket(tag byte, value []byte, depth int) (*packet, error) {
+	p := &packet{tag: tag, value: value}
+	if !p.isConstructed() {
+		return p, nil
+	}
+	if depth >= maxDepth {
+		return nil, fmt.Errorf("ldap: BER elements nested more than %d deep", maxDepth)
+	}
+	for len(value) > 0 {
+		child, n, err := decodeNested(value, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+		value = value[n:]
+	}
+	return p, nil
+}
+
+// decodeElement decodes the element at the start of data and returns its
+// encoded size
+func decodeElement(data []byte) (*packet, int, error) {
+	return decodeNested(data, 0)
+}
+
+func decodeNested(data []byte, depth int) (*packet, int, error) {
+	if len(data) < 2 {
+		return nil, 0, errors.New("ldap: truncated BER element")
+	}
+	tag, first := data[0], data[1]
+	offset, length := 2, int(first)
+	if first&0x80 != 0 {
+		octets := int(first & 0x7f)
+		if octets == 0 || octets > 4 || len(data) < 2+octets {
+			return nil, 0, errors.New("ldap: invalid BER length")
+		}
+		length = 0
+		for _, b := range data[2 : 2+octets] {
+			length = length<<8 | int(b)
+		}
+		offset += octets
+	}
+	if length < 0 || len(data)-offset < length {
+		return nil, 0, errors.New("ldap: truncated BER element")
+	}
+	p, err := parsePacket(tag, data[offset:offset+length], depth)
+	return p, offset + length, err
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// integer decodes an INTEGER or ENUMERATED value
+func (p *packet) integer() (int64, error) {
+	if len(p.value) == 0 || len(p.value) > 8 {
+		return 0, errors.New("ldap: invalid BER integer")
+	}
+	n := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+// child returns the i-th child or an error naming what was expected
+func (p *packet) child(i int, what string) (*packet, error) {
+	if i >= len(p.children) {
+		return nil, fmt.Errorf("ldap: malformed message: missing %s", what)
+	}
+	return p.children[i], nil
+}
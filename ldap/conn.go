@@ -0,0 +1,506 @@
+// Package ldap reads employees from an LDAP directory such as Active
+// Directory or OpenLDAP.
+//
+// It holds a small LDAPv3 client, enough to bind, search and page through
+// large directories over plain TCP, LDAPS or StartTLS, and a Source that
+// maps directory entries onto hrsync employees.
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Protocol operation tags
+const (
+	opBindRequest      = classApplication | constructed | 0
+	opBindResponse     = classApplication | constructed | 1
+	opUnbindRequest    = classApplication | 2
+	opSearchRequest    = classApplication | constructed | 3
+	opSearchEntry      = classApplication | constructed | 4
+	opSearchDone       = classApplication | constructed | 5
+	opSearchReference  = classApplication | constructed | 19
+	opExtendedRequest  = classApplication | constructed | 23
+	opExtendedResponse = classApplication | constructed | 24
+	tagControls        = classContext | constructed | 0
+	oidStartTLS        = "1.3.6.1.4.1.1466.20037"
+	oidPagedResults    = "1.2.840.113556.1.4.319"
+	derefAliasesNever  = 0
+	protocolVersion    = 3
+	defaultPort        = "389"
+	defaultPortLDAPS   = "636"
+	unsolicitedID      = 0
+)
+
+// Result codes
+const (
+	ResultSuccess            = 0
+	ResultSizeLimitExceeded  = 4
+	ResultNoSuchObject       = 32
+	ResultInvalidCredentials = 49
+)
+
+// Error is an LDAP result other than success
+type Error struct {
+	Op         string
+	ResultCode int
+	MatchedDN  string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("ldap: %s failed with result code %d", e.Op, e.ResultCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Scope is the depth of a search
+type Scope string
+
+const (
+	// ScopeSubtree searches the base entry and everything below it. It is
+	// the default.
+	ScopeSubtree Scope = "sub"
+	// ScopeOne searches the entries directly below the base
+	ScopeOne Scope = "one"
+	// ScopeBase reads only the base entry
+	ScopeBase Scope = "base"
+)
+
+func (s Scope) enum() (int64, error) {
+	switch s {
+	case ScopeBase:
+		return 0, nil
+	case ScopeOne:
+		return 1, nil
+	case ScopeSubtree, "":
+		return 2, nil
+	default:
+		return 0, fmt.Errorf("ldap: unknown scope %q", s)
+	}
+}
+
+// SearchRequest describes a search
+type SearchRequest struct {
+	BaseDN string
+	Scope  Scope
+	// Filter is an RFC 4515 filter such as (&(objectClass=user)(mail=*))
+	Filter     string
+	Attributes []string
+	// SizeLimit caps the entries returned; zero leaves it to the server
+	SizeLimit int
+	// PageSize, if set, fetches the results in pages of this size with the
+	// paged results control, which Active Directory needs for more than
+	// 1000 entries
+	PageSize int
+}
+
+// Entry is a directory entry
+type Entry struct {
+	DN string
+	// Attributes holds the values of each attribute, keyed by the lower
+	// case attribute name
+	Attributes map[string][][]byte
+}
+
+// Get returns the first value of an attribute, or ""
+func (e *Entry) Get(name string) string {
+	return string(e.GetBytes(name))
+}
+
+// GetBytes returns the first value of an attribute, or nil
+func (e *Entry) GetBytes(name string) []byte {
+	if values := e.Attributes[strings.ToLower(name)]; len(values) > 0 {
+		return values[0]
+	}
+	return nil
+}
+
+// Values returns every value of an attribute
+func (e *Entry) Values(name string) []string {
+	var values []string
+	for _, v := range e.Attributes[strings.ToLower(name)] {
+		values = append(values, string(v))
+	}
+	return values
+}
+
+// message is a decoded LDAPMessage
+type message struct {
+	id       int64
+	op       *packet
+	controls []*packet
+}
+
+// Conn is a connection to a directory server. Operations are run one at a
+// time.
+type Conn struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	r      *bufio.Reader
+	nextID int64
+	// err is set when the connection can no longer be used
+	err error
+}
+
+// NewConn wraps an established connection
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// Dial connects to an ldap:// or ldaps:// URL. tlsConfig is used for
+// ldaps and may be nil; its ServerName defaults to the URL's host.
+func Dial(ctx context.Context, rawURL string, tlsConfig *tls.Config) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid URL: %w", err)
+	}
+	port := defaultPort
+	switch u.Scheme {
+	case "ldap":
+	case "ldaps":
+		port = defaultPortLDAPS
+	default:
+		return nil, fmt.Errorf("ldap: unsupported URL scheme %q", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: error connecting: %w", err)
+	}
+	if u.Scheme == "ldaps" {
+		tlsConn := tls.Client(conn, clientTLSConfig(tlsConfig, u.Hostname()))
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap: TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+	return NewConn(conn), nil
+}
+
+func clientTLSConfig(config *tls.Config, host string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	return config
+}
+
+// Close unbinds and closes the connection
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.nextID++
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.conn.Write(newSequence(tagSequence, newInteger(tagInteger, c.nextID), &packet{tag: opUnbindRequest}).encode())
+		c.err = net.ErrClosed
+	}
+	return c.conn.Close()
+}
+
+// roundTrip sends an operation and passes each response to handle until
+// it reports the operation done. The caller must hold c.mu.
+func (c *Conn) roundTrip(ctx context.Context, op *packet, controls []*packet, handle func(*message) (bool, error)) (err error) {
+	if c.err != nil {
+		return c.err
+	}
+	defer func() {
+		// After an I/O error the stream position is unknown
+		var ldapErr *Error
+		if err != nil && !errors.As(err, &ldapErr) {
+			// The connection deadline is the context's and may fire just
+			// before the context reports it
+			deadline, ok := ctx.Deadline()
+			switch {
+			case ctx.Err() != nil:
+				err = ctx.Err()
+			case ok && errors.Is(err, os.ErrDeadlineExceeded) && !time.Now().Before(deadline):
+				err = context.DeadlineExceeded
+			}
+			c.err = fmt.Errorf("ldap: connection unusable after error: %w", err)
+		}
+	}()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	} else {
+		c.conn.SetDeadline(time.Time{})
+	}
+	stop := context.AfterFunc(ctx, func() { c.conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	c.nextID++
+	id := c.nextID
+	request := newSequence(tagSequence, newInteger(tagInteger, id), op)
+	if len(controls) > 0 {
+		request.children = append(request.children, newSequence(tagControls, controls...))
+	}
+	if _, err := c.conn.Write(request.encode()); err != nil {
+		return fmt.Errorf("ldap: error sending request: %w", err)
+	}
+
+	for {
+		p, err := readPacket(c.r)
+		if err != nil {
+			return fmt.Errorf("ldap: error reading response: %w", err)
+		}
+		m, err := decodeMessage(p)
+		if err != nil {
+			return err
+		}
+		if m.id == unsolicitedID {
+			result, _ := parseResult("connection", m.op)
+			if result == nil {
+				return errors.New("ldap: server closed the connection")
+			}
+			return fmt.Errorf("ldap: server closed the connection: %s", result.Message)
+		}
+		if m.id != id {
+			continue
+		}
+		done, err := handle(m)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+func decodeMessage(p *packet) (*message, error) {
+	if p.tag != tagSequence || len(p.children) < 2 {
+		return nil, errors.New("ldap: malformed message")
+	}
+	id, err := p.children[0].integer()
+	if err != nil {
+		return nil, err
+	}
+	m := &message{id: id, op: p.children[1]}
+	if len(p.children) > 2 && p.children[2].tag == tagControls {
+		m.controls = p.children[2].children
+	}
+	return m, nil
+}
+
+// parseResult decodes an LDAPResult, returning an *Error for anything but
+// success
+func parseResult(op string, p *packet) (*Error, error) {
+	if len(p.children) < 3 {
+		return nil, errors.New("ldap: malformed result")
+	}
+	code, err := p.children[0].integer()
+	if err != nil {
+		return nil, err
+	}
+	result := &Error{Op: op, ResultCode: int(code), MatchedDN: string(p.children[1].value), Message: string(p.children[2].value)}
+	if code != ResultSuccess {
+		return result, result
+	}
+	return result, nil
+}
+
+// Bind authenticates with a simple bind. An empty DN and password bind
+// anonymously.
+func (c *Conn) Bind(ctx context.Context, dn, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	op := newSequence(opBindRequest,
+		newInteger(tagInteger, protocolVersion),
+		newString(tagOctetString, dn),
+		newString(classContext|0, password),
+	)
+	return c.roundTrip(ctx, op, nil, func(m *message) (bool, error) {
+		if m.op.tag != opBindResponse {
+			return false, fmt.Errorf("ldap: unexpected response to bind")
+		}
+		_, err := parseResult("bind", m.op)
+		return true, err
+	})
+}
+
+// StartTLS upgrades the connection to TLS. config may be nil; its
+// ServerName must be set unless verification is disabled.
+func (c *Conn) StartTLS(ctx context.Context, config *tls.Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	op := newSequence(opExtendedRequest, newString(classContext|0, oidStartTLS))
+	err := c.roundTrip(ctx, op, nil, func(m *message) (bool, error) {
+		if m.op.tag != opExtendedResponse {
+			return false, fmt.Errorf("ldap: unexpected response to StartTLS")
+		}
+		_, err := parseResult("StartTLS", m.op)
+		return true, err
+	})
+	if err != nil {
+		return err
+	}
+
+	host, _, _ := net.SplitHostPort(c.conn.RemoteAddr().String())
+	tlsConn := tls.Client(c.conn, clientTLSConfig(config, host))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		c.err = fmt.Errorf("ldap: TLS handshake failed: %w", err)
+		return c.err
+	}
+	c.conn, c.r = tlsConn, bufio.NewReader(tlsConn)
+	return nil
+}
+
+// Search returns every entry matching the request
+func (c *Conn) Search(ctx context.Context, req *SearchRequest) ([]*Entry, error) {
+	var entries []*Entry
+	err := c.SearchFunc(ctx, req, func(entry *Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// SearchFunc calls fn for each entry matching the request, fetching pages
+// as needed. An error from fn stops the search after the current page and
+// is returned.
+func (c *Conn) SearchFunc(ctx context.Context, req *SearchRequest, fn func(*Entry) error) error {
+	scope, err := req.Scope.enum()
+	if err != nil {
+		return err
+	}
+	filter, err := compileFilter(req.Filter)
+	if err != nil {
+		return err
+	}
+	attributes := newSequence(tagSequence)
+	for _, name := range req.Attributes {
+		attributes.children = append(attributes.children, newString(tagOctetString, name))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var cookie []byte
+	for {
+		op := newSequence(opSearchRequest,
+			newString(tagOctetString, req.BaseDN),
+			newInteger(tagEnumerated, scope),
+			newInteger(tagEnumerated, derefAliasesNever),
+			newInteger(tagInteger, int64(req.SizeLimit)),
+			newInteger(tagInteger, 0),
+			newBoolean(false),
+			filter,
+			attributes,
+		)
+		var controls []*packet
+		if req.PageSize > 0 {
+			controls = append(controls, pagedResultsControl(req.PageSize, cookie))
+		}
+
+		var fnErr error
+		cookie = nil
+		err := c.roundTrip(ctx, op, controls, func(m *message) (bool, error) {
+			switch m.op.tag {
+			case opSearchEntry:
+				entry, err := parseEntry(m.op)
+				if err != nil {
+					return false, err
+				}
+				// Keep reading the page after fn fails so the connection
+				// stays usable
+				if fnErr == nil {
+					fnErr = fn(entry)
+				}
+				return false, nil
+			case opSearchReference:
+				return false, nil
+			case opSearchDone:
+				if _, err := parseResult("search", m.op); err != nil {
+					return true, err
+				}
+				cookie = pagedResultsCookie(m.controls)
+				return true, nil
+			default:
+				return false, fmt.Errorf("ldap: unexpected response to search")
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if fnErr != nil {
+			return fnErr
+		}
+		if len(cookie) == 0 {
+			return nil
+		}
+	}
+}
+
+func parseEntry(p *packet) (*Entry, error) {
+	dn, err := p.child(0, "entry DN")
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{DN: string(dn.value), Attributes: map[string][][]byte{}}
+	attributes, err := p.child(1, "entry attributes")
+	if err != nil {
+		return nil, err
+	}
+	for _, attribute := range attributes.children {
+		name, err := attribute.child(0, "attribute name")
+		if err != nil {
+			return nil, err
+		}
+		values, err := attribute.child(1, "attribute values")
+		if err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(string(name.value))
+		for _, v := range values.children {
+			entry.Attributes[key] = append(entry.Attributes[key], v.value)
+		}
+	}
+	return entry, nil
+}
+
+// pagedResultsControl requests a page (RFC 2696)
+func pagedResultsControl(size int, cookie []byte) *packet {
+	value := newSequence(tagSequence, newInteger(tagInteger, int64(size)), &packet{tag: tagOctetString, value: cookie})
+	return newSequence(tagSequence,
+		newString(tagOctetString, oidPagedResults),
+		&packet{tag: tagOctetString, value: value.encode()},
+	)
+}
+
+// pagedResultsCookie returns the cookie of the next page, or nil after
+// the last page
+func pagedResultsCookie(controls []*packet) []byte {
+	for _, control := range controls {
+		if len(control.children) < 2 || string(control.children[0].value) != oidPagedResults {
+			continue
+		}
+		value := control.children[len(control.children)-1]
+		p, _, err := decodeElement(value.value)
+		if err != nil || len(p.children) < 2 {
+			return nil
+		}
+		return p.children[1].value
+	}
+	return nil
+}
@@ -0,0 +1,484 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"image"
+	"image/png"
+	"net"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// directoryServer is an in-process LDAP server holding a fixed set of
+// entries. It checks simple binds, pages searches and supports StartTLS.
+type directoryServer struct {
+	t        *testing.T
+	listener net.Listener
+	bindDN   string
+	password string
+	entries  []*Entry
+	tls      *tls.Config
+
+	mu       sync.Mutex
+	filters  []string
+	pageSize []int
+}
+
+func newDirectoryServer(t *testing.T, entries []*Entry) *directoryServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &directoryServer{t: t, listener: listener, bindDN: "cn=sync,dc=example,dc=com", password: "s3cret", entries: entries}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *directoryServer) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *directoryServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	send := func(id int64, op *packet, controls ...*packet) {
+		m := newSequence(tagSequence, newInteger(tagInteger, id), op)
+		if len(controls) > 0 {
+			m.children = append(m.children, newSequence(tagControls, controls...))
+		}
+		conn.Write(m.encode())
+	}
+	result := func(tag byte, code int64, message string) *packet {
+		return newSequence(tag, newInteger(tagEnumerated, code), newString(tagOctetString, ""), newString(tagOctetString, message))
+	}
+
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		m, err := decodeMessage(p)
+		if err != nil {
+			s.t.Errorf("server: %v", err)
+			return
+		}
+
+		switch m.op.tag {
+		case opBindRequest:
+			dn, password := string(m.op.children[1].value), string(m.op.children[2].value)
+			if dn != s.bindDN || password != s.password {
+				send(m.id, result(opBindResponse, ResultInvalidCredentials, "invalid credentials"))
+				continue
+			}
+			send(m.id, result(opBindResponse, ResultSuccess, ""))
+		case opExtendedRequest:
+			if s.tls == nil {
+				send(m.id, result(opExtendedResponse, 2, "StartTLS not supported"))
+				continue
+			}
+			send(m.id, result(opExtendedResponse, ResultSuccess, ""))
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r = tlsConn, bufio.NewReader(tlsConn)
+		case opSearchRequest:
+			s.search(m, send, result)
+		case opUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *directoryServer) search(m *message, send func(int64, *packet, ...*packet), result func(byte, int64, string) *packet) {
+	s.mu.Lock()
+	s.filters = append(s.filters, filterString(m.op.children[6]))
+	s.mu.Unlock()
+
+	var wanted []string
+	for _, a := range m.op.children[7].children {
+		wanted = append(wanted, strings.ToLower(string(a.value)))
+	}
+
+	// Page through the entries with the offset as the cookie
+	start, size := 0, len(s.entries)
+	paged := false
+	for _, control := range m.controls {
+		if string(control.children[0].value) != oidPagedResults {
+			continue
+		}
+		value, _, _ := decodeElement(control.children[len(control.children)-1].value)
+		n, _ := value.children[0].integer()
+		size, paged = int(n), true
+		if cookie := string(value.children[1].value); cookie != "" {
+			start, _ = strconv.Atoi(cookie)
+		}
+	}
+	s.mu.Lock()
+	s.pageSize = append(s.pageSize, size)
+	s.mu.Unlock()
+
+	end := min(start+size, len(s.entries))
+	for _, entry := range s.entries[start:end] {
+		attributes := newSequence(tagSequence)
+		for _, name := range wanted {
+			values := newSequence(tagSet)
+			for _, v := range entry.Attributes[name] {
+				values.children = append(values.children, &packet{tag: tagOctetString, value: v})
+			}
+			if len(values.children) > 0 {
+				attributes.children = append(attributes.children, newSequence(tagSequence, newString(tagOctetString, name), values))
+			}
+		}
+		send(m.id, newSequence(opSearchEntry, newString(tagOctetString, entry.DN), attributes))
+	}
+
+	var controls []*packet
+	if paged {
+		cookie := ""
+		if end < len(s.entries) {
+			cookie = strconv.Itoa(end)
+		}
+		value := newSequence(tagSequence, newInteger(tagInteger, 0), newString(tagOctetString, cookie))
+		controls = append(controls, newSequence(tagSequence, newString(tagOctetString, oidPagedResults), &packet{tag: tagOctetString, value: value.encode()}))
+	}
+	send(m.id, result(opSearchDone, ResultSuccess, ""), controls...)
+}
+
+// filterString renders an encoded filter back to RFC 4515 form
+func filterString(p *packet) string {
+	switch p.tag {
+	case filterAnd, filterOr, filterNot:
+		op := map[byte]string{filterAnd: "&", filterOr: "|", filterNot: "!"}[p.tag]
+		var b strings.Builder
+		for _, child := range p.children {
+			b.WriteString(filterString(child))
+		}
+		return "(" + op + b.String() + ")"
+	case filterPresent:
+		return "(" + string(p.value) + "=*)"
+	case filterSubstrings:
+		value := ""
+		hasFinal := false
+		for i, part := range p.children[1].children {
+			if part.tag != substringInitial && (i > 0 || value == "") {
+				value += "*"
+			}
+			value += EscapeFilter(string(part.value))
+			hasFinal = part.tag == substringFinal
+		}
+		if !hasFinal {
+			value += "*"
+		}
+		return "(" + string(p.children[0].value) + "=" + value + ")"
+	default:
+		op := map[byte]string{filterEquality: "=", filterApproxMatch: "~=", filterGreaterOrEqual: ">=", filterLessOrEqual: "<="}[p.tag]
+		return "(" + string(p.children[0].value) + op + EscapeFilter(string(p.children[1].value)) + ")"
+	}
+}
+
+func entry(dn string, attributes map[string]string) *Entry {
+	e := &Entry{DN: dn, Attributes: map[string][][]byte{}}
+	for name, value := range attributes {
+		e.Attributes[strings.ToLower(name)] = [][]byte{[]byte(value)}
+	}
+	return e
+}
+
+func testEntries(t *testing.T) []*Entry {
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 60, 80))); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []*Entry{
+		entry("cn=Ada Lovelace,ou=people,dc=example,dc=com", map[string]string{
+			"employeeID": "E1", "cn": "Ada Lovelace", "mail": "ada@example.com", "title": "Analyst",
+			"department": "Engineering", "telephoneNumber": "+1 555 0100", "userAccountControl": "512",
+		}),
+		entry("cn=Grace Hopper,ou=people,dc=example,dc=com", map[string]string{
+			"employeeID": "E2", "cn": "Grace Hopper", "userAccountControl": "514",
+		}),
+		entry("cn=Printer,ou=people,dc=example,dc=com", map[string]string{"cn": "Printer"}),
+		entry("cn=Alan Turing,ou=people,dc=example,dc=com", map[string]string{"employeeID": "E3", "cn": "Alan Turing"}),
+		entry("cn=Edsger Dijkstra,ou=people,dc=example,dc=com", map[string]string{"employeeID": "E4", "cn": "Edsger Dijkstra"}),
+	}
+	entries[0].Attributes["jpegphoto"] = [][]byte{photo.Bytes()}
+	return entries
+}
+
+func TestSourcePagesAndMaps(t *testing.T) {
+	server := newDirectoryServer(t, testEntries(t))
+	source, err := NewSource(Config{
+		URL:               server.url(),
+		BindDN:            server.bindDN,
+		Password:          server.password,
+		AllowInsecureBind: true,
+		BaseDN:            "ou=people,dc=example,dc=com",
+		Filter:            "(&(objectClass=user)(employeeID=*))",
+		PageSize:          2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	employees, err := source.Employees(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(employees) != 4 {
+		t.Fatalf("got %d employees, want 4 (the printer has no employee ID)", len(employees))
+	}
+	if len(server.pageSize) != 3 || server.pageSize[0] != 2 {
+		t.Errorf("page requests = %v, want 3 pages of 2", server.pageSize)
+	}
+	if server.filters[0] != "(&(objectClass=user)(employeeID=*))" {
+		t.Errorf("filter = %s", server.filters[0])
+	}
+
+	ada := employees[0]
+	if ada.EmployeeID != "E1" || ada.FullName != "Ada Lovelace" || ada.Email != "ada@example.com" ||
+		ada.Title != "Analyst" || ada.Department != "Engineering" || ada.PhoneNumber != "+1 555 0100" || !ada.Active {
+		t.Errorf("ada = %+v", ada)
+	}
+	if ada.EmployeePhoto == "" {
+		t.Error("photo was not mapped")
+	}
+	if employees[1].EmployeeID != "E2" || employees[1].Active {
+		t.Errorf("disabled account = %+v, want inactive", employees[1])
+	}
+}
+
+func TestSourceBindFailure(t *testing.T) {
+	server := newDirectoryServer(t, nil)
+	config := Config{URL: server.url(), BindDN: server.bindDN, Password: "wrong", BaseDN: "dc=example,dc=com"}
+	if _, err := NewSource(config); err == nil {
+		t.Fatal("expected an error binding with a password over plain ldap://, got nil")
+	}
+	config.AllowInsecureBind = true
+	source, err := NewSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = source.Employees(context.Background())
+	var ldapErr *Error
+	if !errors.As(err, &ldapErr) || ldapErr.ResultCode != ResultInvalidCredentials {
+		t.Errorf("error = %v, want invalid credentials", err)
+	}
+}
+
+func TestStartTLS(t *testing.T) {
+	certServer := httptest.NewUnstartedServer(nil)
+	certServer.StartTLS()
+	defer certServer.Close()
+
+	server := newDirectoryServer(t, testEntries(t)[:1])
+	server.tls = &tls.Config{Certificates: certServer.TLS.Certificates}
+	roots := x509.NewCertPool()
+	roots.AddCert(certServer.Certificate())
+
+	source, err := NewSource(Config{
+		URL:       server.url(),
+		StartTLS:  true,
+		TLSConfig: &tls.Config{RootCAs: roots},
+		BindDN:    server.bindDN,
+		Password:  server.password,
+		BaseDN:    "dc=example,dc=com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	employees, err := source.Employees(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(employees) != 1 {
+		t.Errorf("got %d employees, want 1", len(employees))
+	}
+}
+
+func TestSearchContextCancel(t *testing.T) {
+	// A server that accepts but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	conn, err := Dial(context.Background(), "ldap://"+listener.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := conn.Bind(ctx, "", ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want deadline exceeded", err)
+	}
+	if err := conn.Bind(context.Background(), "", ""); err == nil {
+		t.Error("connection should be unusable after an interrupted operation")
+	}
+}
+
+func TestCompileFilter(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"(cn=Ada)", "(cn=Ada)"},
+		{"objectClass=person", "(objectClass=person)"},
+		{"(&(objectClass=user)(!(cn=svc*))(|(mail=*@example.com)(title~=eng)))", "(&(objectClass=user)(!(cn=svc*))(|(mail=*@example.com)(title~=eng)))"},
+		{"(cn=*a*b*)", "(cn=*a*b*)"},
+		{`(cn=paren\28s\29)`, `(cn=paren\28s\29)`},
+		{"(uSNChanged>=100)", "(uSNChanged>=100)"},
+	}
+	for _, tt := range tests {
+		p, err := compileFilter(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		decoded, _, err := decodeElement(p.encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := filterString(decoded); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "(cn=Ada", "(=x)", "(cn:dn:=x)", `(cn=\zz)`, "(cn=a))"} {
+		if _, err := compileFilter(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestIntegerEncoding(t *testing.T) {
+	for _, n := range []int64{0, 1, 127, 128, 255, 256, -1, -128, -129, 1 << 31, -(1 << 40)} {
+		p, _, err := decodeElement(newInteger(tagInteger, n).encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := p.integer(); got != n {
+			t.Errorf("round trip of %d gave %d", n, got)
+		}
+	}
+	if got := encodeLength(300); !bytes.Equal(got, []byte{0x82, 0x01, 0x2c}) {
+		t.Errorf("length 300 = %x", got)
+	}
+}
+
+func TestNestingDepth(t *testing.T) {
+	nest := func(depth int) []byte {
+		p := newString(tagOctetString, "x")
+		for i := 0; i < depth; i++ {
+			p = newSequence(tagSequence, p)
+		}
+		return p.encode()
+	}
+
+	if _, _, err := decodeElement(nest(maxDepth)); err != nil {
+		t.Errorf("%d levels: %v", maxDepth, err)
+	}
+	if _, _, err := decodeElement(nest(maxDepth + 1)); err == nil {
+		t.Errorf("%d levels: expected an error", maxDepth+1)
+	}
+	if _, err := readPacket(bufio.NewReader(bytes.NewReader(nest(10000)))); err == nil {
+		t.Error("10000 levels: expected an error")
+	}
+}
+
+// TestDirectoryServer runs against a real server, such as
+//
+//	docker run -p 1389:1389 -e LDAP_ADMIN_PASSWORD=admin bitnami/openldap
+//
+// with ACCESSGRID_LDAP_URL=ldap://localhost:1389 and the bind DN, password
+// and base DN in ACCESSGRID_LDAP_BIND_DN, ACCESSGRID_LDAP_PASSWORD and
+// ACCESSGRID_LDAP_BASE_DN. The directory needs at least two inetOrgPerson
+// entries with a uid, which the image creates by default.
+func TestDirectoryServer(t *testing.T) {
+	url := os.Getenv("ACCESSGRID_LDAP_URL")
+	if url == "" {
+		t.Skip("ACCESSGRID_LDAP_URL is not set")
+	}
+	config := Config{
+		URL:      url,
+		BindDN:   os.Getenv("ACCESSGRID_LDAP_BIND_DN"),
+		Password: os.Getenv("ACCESSGRID_LDAP_PASSWORD"),
+		BaseDN:   os.Getenv("ACCESSGRID_LDAP_BASE_DN"),
+		// The container above serves plain ldap:// on localhost
+		AllowInsecureBind: true,
+		Filter:            "(&(objectClass=inetOrgPerson)(|(uid=*)(cn=*)))",
+		Attributes:        Attributes{EmployeeID: "uid", FullName: "cn", Email: "mail"},
+	}
+	employees := func(pageSize int) []string {
+		t.Helper()
+		config := config
+		config.PageSize = pageSize
+		source, err := NewSource(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found, err := source.Employees(context.Background())
+		if err != nil {
+			t.Fatalf("page size %d: %v", pageSize, err)
+		}
+		var ids []string
+		for _, e := range found {
+			if e.EmployeeID == "" || e.FullName == "" {
+				t.Errorf("page size %d: incomplete employee %+v", pageSize, e)
+			}
+			ids = append(ids, e.EmployeeID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	// A page size of one makes the server page through every entry
+	all, paged := employees(1000), employees(1)
+	if len(all) < 2 {
+		t.Fatalf("found %d employees, want at least 2", len(all))
+	}
+	if strings.Join(paged, ",") != strings.Join(all, ",") {
+		t.Errorf("paged search found %v, want %v", paged, all)
+	}
+
+	config.Password = "wrong"
+	source, err := NewSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = source.Employees(context.Background())
+	var ldapErr *Error
+	if !errors.As(err, &ldapErr) || ldapErr.ResultCode != ResultInvalidCredentials {
+		t.Errorf("error with a wrong password = %v, want invalid credentials", err)
+	}
+}
@@ -0,0 +1,215 @@
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/Access-Grid/accessgrid-go/hrsync"
+	"github.com/Access-Grid/accessgrid-go/images"
+)
+
+// Attributes names the directory attribute read for each employee field.
+// Empty names are not read.
+type Attributes struct {
+	EmployeeID     string
+	FullName       string
+	Email          string
+	PhoneNumber    string
+	Title          string
+	Department     string
+	Location       string
+	Classification string
+	// Photo holds a JPEG or PNG image, such as jpegPhoto or Active
+	// Directory's thumbnailPhoto
+	Photo string
+}
+
+// DefaultAttributes suits Active Directory and inetOrgPerson entries
+var DefaultAttributes = Attributes{
+	EmployeeID:  "employeeID",
+	FullName:    "cn",
+	Email:       "mail",
+	PhoneNumber: "telephoneNumber",
+	Title:       "title",
+	Department:  "department",
+	Location:    "l",
+	Photo:       "jpegPhoto",
+}
+
+func (a Attributes) names() []string {
+	var names []string
+	for _, name := range []string{a.EmployeeID, a.FullName, a.Email, a.PhoneNumber, a.Title, a.Department, a.Location, a.Classification, a.Photo} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Config configures a Source
+type Config struct {
+	// URL is the server, such as ldaps://dc1.example.com
+	URL       string
+	TLSConfig *tls.Config
+	// StartTLS upgrades an ldap:// connection before binding
+	StartTLS bool
+	// BindDN and Password authenticate the search; leave both empty to
+	// bind anonymously
+	BindDN   string
+	Password string
+	// AllowInsecureBind sends the password over a plain ldap:// connection
+	// without StartTLS. Otherwise a password is only sent over ldaps:// or
+	// StartTLS, since a simple bind carries it in cleartext.
+	AllowInsecureBind bool
+	BaseDN            string
+	Scope             Scope
+	// Filter defaults to (objectClass=person)
+	Filter string
+	// PageSize defaults to 500
+	PageSize int
+	// Attributes defaults to DefaultAttributes
+	Attributes Attributes
+	// PhotoOptions sizes photos for the pass
+	PhotoOptions images.PhotoOptions
+	// Active decides whether an entry's pass is usable. Defaults to
+	// ActiveDirectoryEnabled.
+	Active func(*Entry) bool
+	// ExtraAttributes are read as well, for use by Active
+	ExtraAttributes []string
+}
+
+// Source is an hrsync.Source that searches a directory
+type Source struct {
+	config Config
+}
+
+var _ hrsync.Source = (*Source)(nil)
+
+// NewSource creates a directory source
+func NewSource(config Config) (*Source, error) {
+	if config.URL == "" {
+		return nil, errors.New("ldap: URL is required")
+	}
+	if config.BaseDN == "" {
+		return nil, errors.New("ldap: BaseDN is required")
+	}
+	if u, err := url.Parse(config.URL); err == nil && u.Scheme != "ldaps" && config.Password != "" && !config.StartTLS && !config.AllowInsecureBind {
+		return nil, errors.New("ldap: refusing to send the password over a plain connection; use ldaps://, StartTLS or AllowInsecureBind")
+	}
+	if _, err := config.Scope.enum(); err != nil {
+		return nil, err
+	}
+	if config.Filter == "" {
+		config.Filter = "(objectClass=person)"
+	}
+	if _, err := compileFilter(config.Filter); err != nil {
+		return nil, err
+	}
+	if config.PageSize <= 0 {
+		config.PageSize = 500
+	}
+	if config.Attributes == (Attributes{}) {
+		config.Attributes = DefaultAttributes
+	}
+	if config.Active == nil {
+		config.Active = ActiveDirectoryEnabled
+		config.ExtraAttributes = append(append([]string{}, config.ExtraAttributes...), "userAccountControl")
+	}
+	return &Source{config: config}, nil
+}
+
+// ActiveDirectoryEnabled reports whether the ACCOUNTDISABLE flag of
+// userAccountControl is clear. Entries without the attribute are active.
+func ActiveDirectoryEnabled(entry *Entry) bool {
+	value := entry.Get("userAccountControl")
+	if value == "" {
+		return true
+	}
+	flags, err := strconv.ParseInt(value, 10, 64)
+	return err != nil || flags&0x2 == 0
+}
+
+// Employees connects, binds and reads every matching entry. Entries
+// without an employee ID are skipped.
+func (s *Source) Employees(ctx context.Context) ([]hrsync.Employee, error) {
+	conn, err := Dial(ctx, s.config.URL, s.config.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if s.config.StartTLS {
+		if err := conn.StartTLS(ctx, clientTLSConfig(s.config.TLSConfig, hostname(s.config.URL))); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Bind(ctx, s.config.BindDN, s.config.Password); err != nil {
+		return nil, err
+	}
+
+	attributes := append(s.config.Attributes.names(), s.config.ExtraAttributes...)
+	var employees []hrsync.Employee
+	err = conn.SearchFunc(ctx, &SearchRequest{
+		BaseDN:     s.config.BaseDN,
+		Scope:      s.config.Scope,
+		Filter:     s.config.Filter,
+		Attributes: attributes,
+		PageSize:   s.config.PageSize,
+	}, func(entry *Entry) error {
+		if employee, ok := s.employee(entry); ok {
+			employees = append(employees, employee)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return employees, nil
+}
+
+// employee maps an entry. A photo that cannot be read is left out rather
+// than holding up the pass.
+func (s *Source) employee(entry *Entry) (hrsync.Employee, bool) {
+	a := s.config.Attributes
+	get := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return entry.Get(name)
+	}
+
+	employee := hrsync.Employee{
+		EmployeeID:     get(a.EmployeeID),
+		FullName:       get(a.FullName),
+		Email:          get(a.Email),
+		PhoneNumber:    get(a.PhoneNumber),
+		Title:          get(a.Title),
+		Department:     get(a.Department),
+		Location:       get(a.Location),
+		Classification: get(a.Classification),
+		Active:         s.config.Active(entry),
+	}
+	if employee.EmployeeID == "" {
+		return employee, false
+	}
+	if a.Photo != "" {
+		if raw := entry.GetBytes(a.Photo); len(raw) > 0 {
+			if photo, err := images.EmployeePhoto(bytes.NewReader(raw), s.config.PhotoOptions); err == nil {
+				employee.EmployeePhoto = photo
+			}
+		}
+	}
+	return employee, true
+}
+
+func hostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
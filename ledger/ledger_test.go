@@ -0,0 +1,147 @@
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+var ledgerPages = []string{
+	`[
+		{"id": "li_1", "created_at": "2026-04-02T10:00:00Z", "amount": -1.50, "kind": "access_pass_debit",
+		 "metadata": {"cost_center": "CC-100"},
+		 "access_pass": {"id": "ap_1", "full_name": "Jane Doe", "metadata": {"employee_id": "E1"},
+		  "pass_template": {"id": "pt_1", "name": "Employee Badge"}}},
+		{"id": "li_2", "created_at": "2026-04-03T10:00:00Z", "amount": -1.50, "kind": "access_pass_debit",
+		 "metadata": {},
+		 "access_pass": {"id": "ap_2", "full_name": "John Roe", "metadata": {"cost_center": "CC-200"},
+		  "pass_template": {"id": "pt_1", "name": "Employee Badge"}}}
+	]`,
+	`[
+		{"id": "li_3", "created_at": "2026-04-20T10:00:00Z", "amount": "-0.10", "kind": "access_pass_debit",
+		 "metadata": {"pass_template_ex_id": "pt_2"},
+		 "access_pass": {"id": "ap_3", "unified_access_pass_ex_id": "uap_3", "full_name": "Jane Doe", "metadata": {"employee_id": "E1"}}},
+		{"id": "li_4", "created_at": "2026-04-25T10:00:00Z", "amount": 500, "kind": "credit", "metadata": {}, "access_pass": null},
+		{"id": "li_5", "created_at": "2026-05-01T00:00:00Z", "amount": -1.50, "kind": "access_pass_debit", "metadata": {}, "access_pass": null}
+	]`,
+}
+
+func newLedgerService(t *testing.T) *services.ConsoleService {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var page int
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		items := "[]"
+		if page >= 1 && page <= len(ledgerPages) {
+			items = ledgerPages[page-1]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"ledger_items": %s, "pagination": {"current_page": %d, "total_pages": %d}}`, items, page, len(ledgerPages))
+	}))
+	t.Cleanup(server.Close)
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+	return services.NewConsoleService(c)
+}
+
+var (
+	april = time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	may   = time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func TestCollectAndBuildReport(t *testing.T) {
+	items, err := Collect(context.Background(), newLedgerService(t), april, may)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(items) != 4 {
+		t.Fatalf("Collect() got %d items, want 4 (end date is exclusive)", len(items))
+	}
+
+	report, err := BuildReport(items, april, may, ReportOptions{})
+	if err != nil {
+		t.Fatalf("BuildReport() error = %v", err)
+	}
+
+	checks := []struct {
+		name  string
+		line  *Line
+		count int
+		want  string
+	}{
+		{"total", &report.Total, 4, "496.90"},
+		{"debits", report.ByKind["access_pass_debit"], 3, "-3.10"},
+		{"credits", report.ByKind["credit"], 1, "500.00"},
+		{"badge template", report.ByTemplate["Employee Badge (pt_1)"], 2, "-3.00"},
+		{"template from metadata", report.ByTemplate["pt_2"], 1, "-0.10"},
+		{"item cost center", report.ByCostCenter["CC-100"], 1, "-1.50"},
+		{"pass cost center", report.ByCostCenter["CC-200"], 1, "-1.50"},
+		{"employee by ID", report.ByEmployee["E1"], 2, "-1.60"},
+		{"employee by name", report.ByEmployee["John Roe"], 1, "-1.50"},
+		{"unassigned employee", report.ByEmployee[Unassigned], 1, "500.00"},
+	}
+	for _, c := range checks {
+		if c.line == nil {
+			t.Errorf("%s: missing line", c.name)
+			continue
+		}
+		if c.line.Count != c.count || c.line.Amount.String() != c.want {
+			t.Errorf("%s = %d, %s, want %d, %s", c.name, c.line.Count, c.line.Amount, c.count, c.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	rows, _ := csv.NewReader(&buf).ReadAll()
+	if len(rows) < 2 || rows[1][0] != "total" || rows[1][3] != "496.90" {
+		t.Errorf("CSV rows = %v", rows)
+	}
+
+	buf.Reset()
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	var decoded struct {
+		Total struct {
+			Amount json.Number `json:"amount"`
+		} `json:"total"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.Total.Amount != "496.90" {
+		t.Errorf("JSON total = %v, %v", decoded.Total.Amount, err)
+	}
+}
+
+func TestBuildReport_InvalidAmount(t *testing.T) {
+	items := []models.LedgerItem{{ID: "li_bad", Amount: "one dollar"}}
+	if _, err := BuildReport(items, april, may, ReportOptions{}); err == nil {
+		t.Error("expected error for invalid amount, got nil")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	items, _ := Collect(context.Background(), newLedgerService(t), april, may)
+
+	result := Reconcile(items, []string{"ap_1", "uap_3", "ap_9"})
+	if fmt.Sprint(result.Matched) != "[ap_1 uap_3]" {
+		t.Errorf("Matched = %v", result.Matched)
+	}
+	if fmt.Sprint(result.Unbilled) != "[ap_9]" {
+		t.Errorf("Unbilled = %v", result.Unbilled)
+	}
+	if len(result.Unexpected) != 1 || result.Unexpected[0].ID != "li_2" {
+		t.Errorf("Unexpected = %v", result.Unexpected)
+	}
+	if result.Balanced() {
+		t.Error("Balanced() = true, want false")
+	}
+}
@@ -0,0 +1,64 @@
+package ledger
+
+import (
+	"sort"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// Reconciliation compares billed access passes with the cards expected to
+// be billed
+type Reconciliation struct {
+	// Matched lists the expected card IDs that were billed
+	Matched []string `json:"matched"`
+	// Unexpected holds items billed for access passes that were not expected
+	Unexpected []models.LedgerItem `json:"unexpected"`
+	// Unbilled lists the expected card IDs without a ledger item
+	Unbilled []string `json:"unbilled"`
+}
+
+// Balanced reports whether every billed pass was expected and every
+// expected card was billed
+func (r *Reconciliation) Balanced() bool {
+	return len(r.Unexpected) == 0 && len(r.Unbilled) == 0
+}
+
+// Reconcile matches ledger items for access passes against expected card
+// IDs. A card matches an item by its access pass ID or unified access pass
+// ID. Items without an access pass, such as credits, are ignored.
+func Reconcile(items []models.LedgerItem, expectedCardIDs []string) *Reconciliation {
+	expected := map[string]bool{}
+	for _, id := range expectedCardIDs {
+		expected[id] = true
+	}
+
+	billed := map[string]bool{}
+	result := &Reconciliation{}
+	for _, item := range items {
+		pass := item.AccessPass
+		if pass == nil {
+			continue
+		}
+		matched := false
+		for _, id := range []string{pass.ID, pass.UnifiedAccessPassExID} {
+			if id != "" && expected[id] {
+				billed[id] = true
+				matched = true
+			}
+		}
+		if !matched {
+			result.Unexpected = append(result.Unexpected, item)
+		}
+	}
+
+	for id := range expected {
+		if billed[id] {
+			result.Matched = append(result.Matched, id)
+		} else {
+			result.Unbilled = append(result.Unbilled, id)
+		}
+	}
+	sort.Strings(result.Matched)
+	sort.Strings(result.Unbilled)
+	return result
+}
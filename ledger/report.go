@@ -0,0 +1,219 @@
+// Package ledger builds billing reports from the account ledger.
+//
+// Collect fetches every ledger item in a date range, BuildReport totals
+// them by kind, pass template, cost center and employee, and Reconcile
+// cross-checks billed passes against the cards you expect to be billed for.
+package ledger
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// collectPageSize is the number of ledger items requested per page
+const collectPageSize = 100
+
+// Unassigned is the report key for items without a value for a dimension
+const Unassigned = "(none)"
+
+// Collect fetches every ledger item created in [start, end)
+func Collect(ctx context.Context, console *services.ConsoleService, start, end time.Time) ([]models.LedgerItem, error) {
+	var items []models.LedgerItem
+	for page := 1; ; page++ {
+		response, err := console.ListLedgerItems(ctx, models.ListLedgerItemsParams{
+			Page:      page,
+			PerPage:   collectPageSize,
+			StartDate: &start,
+			EndDate:   &end,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range response.LedgerItems {
+			// The API filters by date; guard against inclusive end dates
+			if !item.CreatedAt.Before(start) && item.CreatedAt.Before(end) {
+				items = append(items, item)
+			}
+		}
+		if len(response.LedgerItems) == 0 || page >= response.Pagination.TotalPages {
+			return items, nil
+		}
+	}
+}
+
+// ReportOptions configures BuildReport
+type ReportOptions struct {
+	// CostCenterKey is the metadata key holding an item's cost center. It
+	// is looked up on the ledger item first, then on its access pass.
+	// Defaults to "cost_center".
+	CostCenterKey string
+	// EmployeeKey is the access pass metadata key holding the employee ID.
+	// Items without it are grouped by the access pass holder's name.
+	// Defaults to "employee_id".
+	EmployeeKey string
+}
+
+// Line is a report total
+type Line struct {
+	Count  int            `json:"count"`
+	Amount models.Decimal `json:"amount"`
+}
+
+func (l *Line) add(amount models.Decimal) error {
+	sum, err := l.Amount.Add(amount)
+	if err != nil {
+		return err
+	}
+	l.Count++
+	l.Amount = sum
+	return nil
+}
+
+// Report totals ledger items over a period
+type Report struct {
+	Start        time.Time        `json:"start"`
+	End          time.Time        `json:"end"`
+	Total        Line             `json:"total"`
+	ByKind       map[string]*Line `json:"by_kind"`
+	ByTemplate   map[string]*Line `json:"by_template"`
+	ByCostCenter map[string]*Line `json:"by_cost_center"`
+	ByEmployee   map[string]*Line `json:"by_employee"`
+}
+
+// BuildReport totals ledger items. It fails on the first amount that is
+// not a valid decimal rather than produce a report that does not add up.
+func BuildReport(items []models.LedgerItem, start, end time.Time, opts ReportOptions) (*Report, error) {
+	if opts.CostCenterKey == "" {
+		opts.CostCenterKey = "cost_center"
+	}
+	if opts.EmployeeKey == "" {
+		opts.EmployeeKey = "employee_id"
+	}
+
+	report := &Report{
+		Start:        start.UTC(),
+		End:          end.UTC(),
+		ByKind:       map[string]*Line{},
+		ByTemplate:   map[string]*Line{},
+		ByCostCenter: map[string]*Line{},
+		ByEmployee:   map[string]*Line{},
+	}
+	addTo := func(lines map[string]*Line, key string, amount models.Decimal) error {
+		if key == "" {
+			key = Unassigned
+		}
+		if lines[key] == nil {
+			lines[key] = &Line{}
+		}
+		return lines[key].add(amount)
+	}
+
+	for _, item := range items {
+		amount, err := item.AmountDecimal()
+		if err != nil {
+			return nil, fmt.Errorf("ledger item %s: %w", item.ID, err)
+		}
+		if err := report.Total.add(amount); err != nil {
+			return nil, fmt.Errorf("ledger item %s: %w", item.ID, err)
+		}
+		groups := []struct {
+			lines map[string]*Line
+			key   string
+		}{
+			{report.ByKind, item.Kind},
+			{report.ByTemplate, templateKey(item)},
+			{report.ByCostCenter, costCenter(item, opts.CostCenterKey)},
+			{report.ByEmployee, employeeKey(item, opts.EmployeeKey)},
+		}
+		for _, group := range groups {
+			if err := addTo(group.lines, group.key, amount); err != nil {
+				return nil, fmt.Errorf("ledger item %s: %w", item.ID, err)
+			}
+		}
+	}
+	return report, nil
+}
+
+func metadataString(metadata map[string]interface{}, key string) string {
+	if value, ok := metadata[key]; ok && value != nil {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+func templateKey(item models.LedgerItem) string {
+	if item.AccessPass != nil && item.AccessPass.PassTemplate != nil {
+		template := item.AccessPass.PassTemplate
+		if template.Name != "" {
+			return fmt.Sprintf("%s (%s)", template.Name, template.ID)
+		}
+		return template.ID
+	}
+	return metadataString(item.Metadata, "pass_template_ex_id")
+}
+
+func costCenter(item models.LedgerItem, key string) string {
+	if value := metadataString(item.Metadata, key); value != "" {
+		return value
+	}
+	if item.AccessPass != nil {
+		return metadataString(item.AccessPass.Metadata, key)
+	}
+	return ""
+}
+
+func employeeKey(item models.LedgerItem, key string) string {
+	if item.AccessPass == nil {
+		return ""
+	}
+	if value := metadataString(item.AccessPass.Metadata, key); value != "" {
+		return value
+	}
+	return item.AccessPass.FullName
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes the report as rows of dimension, key, count and amount,
+// starting with the total
+func (r *Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"dimension", "key", "count", "amount"})
+	writer.Write([]string{"total", "", fmt.Sprint(r.Total.Count), r.Total.Amount.String()})
+
+	for _, dimension := range []struct {
+		name  string
+		lines map[string]*Line
+	}{
+		{"kind", r.ByKind},
+		{"template", r.ByTemplate},
+		{"cost_center", r.ByCostCenter},
+		{"employee", r.ByEmployee},
+	} {
+		keys := make([]string, 0, len(dimension.lines))
+		for key := range dimension.lines {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			line := dimension.lines[key]
+			writer.Write([]string{dimension.name, key, fmt.Sprint(line.Count), line.Amount.String()})
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,232 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// decimalScale is the number of fractional digits a Decimal holds
+	decimalScale = 4
+	// decimalUnit is the number of units in 1
+	decimalUnit int64 = 10000
+)
+
+// Decimal is an exact monetary amount with four fractional digits
+type Decimal struct {
+	units int64
+}
+
+// maxDecimalWhole is the largest whole part a Decimal holds
+const maxDecimalWhole = math.MaxInt64 / decimalUnit
+
+// maxDecimalExponent bounds the exponent ParseDecimal accepts, so a
+// malicious amount such as "1e999999999" cannot make it allocate a huge
+// number
+const maxDecimalExponent = 100
+
+// ParseDecimal parses a decimal string such as "-1.50" or "1.5e2". Amounts
+// too large for a Decimal are an error rather than wrapping around.
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if strings.ContainsAny(s, "eE") {
+		return parseExponent(s)
+	}
+	negative := strings.HasPrefix(s, "-")
+	digits := s
+	if negative || strings.HasPrefix(s, "+") {
+		digits = s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if len(fraction) > decimalScale {
+		if strings.Trim(fraction[decimalScale:], "0") != "" {
+			return Decimal{}, fmt.Errorf("decimal %q has more than %d fractional digits", s, decimalScale)
+		}
+		fraction = fraction[:decimalScale]
+	}
+	fraction += strings.Repeat("0", decimalScale-len(fraction))
+	if whole == "" {
+		whole = "0"
+	}
+
+	if strings.ContainsAny(whole, "+-") {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	f, ferr := strconv.ParseInt(fraction, 10, 64)
+	if ferr != nil || strings.ContainsAny(fraction, "+-") {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if err != nil || w > (math.MaxInt64-f)/decimalUnit {
+		return Decimal{}, fmt.Errorf("decimal %q is out of range", s)
+	}
+
+	units := w*decimalUnit + f
+	if negative {
+		units = -units
+	}
+	return Decimal{units: units}, nil
+}
+
+// parseExponent parses a decimal in exponent form, such as JSON encoders
+// write for large or small numbers
+func parseExponent(s string) (Decimal, error) {
+	mantissa, exponent, _ := strings.Cut(strings.ToLower(s), "e")
+	exp, err := strconv.Atoi(exponent)
+	if err != nil || mantissa == "" || strings.ContainsAny(mantissa, "/_") {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if exp > maxDecimalExponent || exp < -maxDecimalExponent {
+		return Decimal{}, fmt.Errorf("decimal %q is out of range", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	r.Mul(r, big.NewRat(decimalUnit, 1))
+	if !r.IsInt() {
+		return Decimal{}, fmt.Errorf("decimal %q has more than %d fractional digits", s, decimalScale)
+	}
+	units := r.Num()
+	if !units.IsInt64() || units.Int64() == math.MinInt64 {
+		return Decimal{}, fmt.Errorf("decimal %q is out of range", s)
+	}
+	return Decimal{units: units.Int64()}, nil
+}
+
+// DecimalFromValue converts a decoded JSON amount: a number, a numeric
+// string or nil
+func DecimalFromValue(value interface{}) (Decimal, error) {
+	switch v := value.(type) {
+	case nil:
+		return Decimal{}, nil
+	case float64:
+		// The shortest representation recovers the decimal the API sent
+		return ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+	case json.Number:
+		return ParseDecimal(v.String())
+	case string:
+		return ParseDecimal(v)
+	case int:
+		return decimalFromInt(int64(v))
+	case int64:
+		return decimalFromInt(v)
+	default:
+		return Decimal{}, fmt.Errorf("unsupported amount %v (%T)", value, value)
+	}
+}
+
+func decimalFromInt(v int64) (Decimal, error) {
+	if v > maxDecimalWhole || v < -maxDecimalWhole {
+		return Decimal{}, fmt.Errorf("amount %d is out of range", v)
+	}
+	return Decimal{units: v * decimalUnit}, nil
+}
+
+// Add returns d + other. A sum too large for a Decimal is an error rather
+// than wrapping around.
+func (d Decimal) Add(other Decimal) (Decimal, error) {
+	sum := d.units + other.units
+	// Operands of the same sign overflow when the sum's sign differs;
+	// MinInt64 is out of range too, as it has no negation
+	if (d.units > 0 && other.units > 0 && sum < 0) || (d.units < 0 && other.units < 0 && sum >= 0) || sum == math.MinInt64 {
+		return Decimal{}, fmt.Errorf("sum of %s and %s is out of range", d, other)
+	}
+	return Decimal{units: sum}, nil
+}
+
+// Neg returns -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{units: -d.units}
+}
+
+// Cmp returns -1, 0 or 1 as d is less than, equal to or greater than other
+func (d Decimal) Cmp(other Decimal) int {
+	switch {
+	case d.units < other.units:
+		return -1
+	case d.units > other.units:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Float64 returns d as a float, for ratios and display only
+func (d Decimal) Float64() float64 {
+	return float64(d.units) / float64(decimalUnit)
+}
+
+// IsZero reports whether d is zero
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+// Sign returns -1, 0 or 1
+func (d Decimal) Sign() int {
+	switch {
+	case d.units < 0:
+		return -1
+	case d.units > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// String formats d with at least two fractional digits
+func (d Decimal) String() string {
+	units := d.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	fraction := fmt.Sprintf("%04d", units%decimalUnit)
+	fraction = strings.TrimRight(fraction, "0")
+	for len(fraction) < 2 {
+		fraction += "0"
+	}
+	return fmt.Sprintf("%s%d.%s", sign, units/decimalUnit, fraction)
+}
+
+// MarshalJSON encodes d as a JSON number
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number, including exponent form, or a
+// numeric string
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	parsed, err := DecimalFromValue(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// AmountDecimal returns the item's amount as a Decimal
+func (l LedgerItem) AmountDecimal() (Decimal, error) {
+	return DecimalFromValue(l.Amount)
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"1.50", "1.50", false},
+		{"-1.5", "-1.50", false},
+		{"0.1", "0.10", false},
+		{"12", "12.00", false},
+		{".25", "0.25", false},
+		{"0.12345", "", true},
+		{"0.12340", "0.1234", false},
+		{"1.2.3", "", true},
+		{"abc", "", true},
+		{"", "", true},
+		{"-", "", true},
+		{"1.-5", "", true},
+		{"+1.5", "1.50", false},
+		{"-+1", "", true},
+		{"+-1", "", true},
+		{"--1", "", true},
+		{"922337203685477.5807", "922337203685477.5807", false},
+		{"922337203685477.5808", "", true},
+		{"922337203685478", "", true},
+		{"-922337203685478", "", true},
+		{"99999999999999999999", "", true},
+		{"1e2", "100.00", false},
+		{"-1.5E-2", "-0.015", false},
+		{"2.5e+1", "25.00", false},
+		{"1e-5", "", true},
+		{"1e15", "", true},
+		{"1e999999999", "", true},
+		{"e2", "", true},
+		{"1e", "", true},
+		{"1/2e1", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDecimal(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecimal_ExactSums(t *testing.T) {
+	// 0.1 added ten times is not 1 in floating point
+	var sum Decimal
+	for i := 0; i < 10; i++ {
+		d, _ := DecimalFromValue(0.1)
+		var err error
+		if sum, err = sum.Add(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sum.String() != "1.00" {
+		t.Errorf("sum = %s, want 1.00", sum)
+	}
+}
+
+func TestDecimal_AddOverflow(t *testing.T) {
+	largest := mustDecimal(t, "922337203685477.5807")
+	tests := []struct {
+		a, b    Decimal
+		want    string
+		wantErr bool
+	}{
+		{largest, mustDecimal(t, "-1"), "922337203685476.5807", false},
+		{largest, mustDecimal(t, "0.0001"), "", true},
+		{largest.Neg(), mustDecimal(t, "-0.0001"), "", true},
+		{largest, largest, "", true},
+		{largest.Neg(), largest.Neg(), "", true},
+		{largest, largest.Neg(), "0.00", false},
+	}
+	for _, tt := range tests {
+		got, err := tt.a.Add(tt.b)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s.Add(%s) error = %v, wantErr %v", tt.a, tt.b, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("%s.Add(%s) = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if largest.Cmp(largest.Neg()) != 1 || largest.Neg().Cmp(largest) != -1 || largest.Cmp(largest) != 0 {
+		t.Error("Cmp() of the largest amounts is wrong")
+	}
+}
+
+func TestLedgerItem_AmountDecimal(t *testing.T) {
+	var items []LedgerItem
+	data := `[{"id": "a", "amount": -1.50}, {"id": "b", "amount": "500.00"}, {"id": "c", "amount": null}, {"id": "d", "amount": true}]`
+	if err := json.Unmarshal([]byte(data), &items); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"-1.50", "500.00", "0.00"}
+	for i, w := range want {
+		got, err := items[i].AmountDecimal()
+		if err != nil || got.String() != w {
+			t.Errorf("items[%d].AmountDecimal() = %s, %v, want %s", i, got, err, w)
+		}
+	}
+	if _, err := items[3].AmountDecimal(); err == nil {
+		t.Error("expected error for boolean amount, got nil")
+	}
+
+	out, _ := json.Marshal(struct {
+		Amount Decimal `json:"amount"`
+	}{mustDecimal(t, "-2.25")})
+	if string(out) != `{"amount":-2.25}` {
+		t.Errorf("Marshal() = %s", out)
+	}
+}
+
+func TestDecimal_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{`1.5`, "1.50", false},
+		{`1e2`, "100.00", false},
+		{`-2.5E-1`, "-0.25", false},
+		{`"3.75"`, "3.75", false},
+		{`"1e1"`, "10.00", false},
+		{`null`, "0.00", false},
+		{`true`, "", true},
+		{`1e-9`, "", true},
+	}
+	for _, tt := range tests {
+		var got struct {
+			Amount Decimal `json:"amount"`
+		}
+		err := json.Unmarshal([]byte(`{"amount":`+tt.in+`}`), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.Amount.String() != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, got.Amount, tt.want)
+		}
+	}
+}
+
+func mustDecimal(t *testing.T, s string) Decimal {
+	t.Helper()
+	d, err := ParseDecimal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
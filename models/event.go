@@ -0,0 +1,308 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventID identifies an event. The API sends IDs as strings or numbers;
+// both decode to the same string form.
+type EventID string
+
+// UnmarshalJSON accepts string, numeric and null IDs
+func (id *EventID) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*id = ""
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*id = EventID(s)
+	default:
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid event id %s", data)
+		}
+		*id = EventID(n.String())
+	}
+	return nil
+}
+
+// EventType is the kind of an event log entry
+type EventType string
+
+// Known event types
+const (
+	EventTypeProvision EventType = "provision"
+	EventTypeInstall   EventType = "install"
+	EventTypeUninstall EventType = "uninstall"
+	EventTypeUpdate    EventType = "update"
+	EventTypeSuspend   EventType = "suspend"
+	EventTypeResume    EventType = "resume"
+	EventTypeUnlink    EventType = "unlink"
+	EventTypeDelete    EventType = "delete"
+)
+
+// EventDetails is the typed detail payload of an event. It is one of
+// *DeviceEventDetails, *CardStateEventDetails, *TextEventDetails or
+// *RawEventDetails.
+type EventDetails interface {
+	eventDetails()
+}
+
+// DeviceEventDetails describes the device of an install or uninstall event
+type DeviceEventDetails struct {
+	DeviceID   string          `json:"device_id,omitempty"`
+	Platform   DevicePlatform  `json:"platform,omitempty"`
+	DeviceType DeviceType      `json:"device_type,omitempty"`
+	Raw        json.RawMessage `json:"-"`
+}
+
+// CardStateEventDetails describes a change to a card
+type CardStateEventDetails struct {
+	FromState string          `json:"from_state,omitempty"`
+	ToState   string          `json:"to_state,omitempty"`
+	Reason    string          `json:"reason,omitempty"`
+	Raw       json.RawMessage `json:"-"`
+}
+
+// TextEventDetails holds details sent as plain text
+type TextEventDetails struct {
+	Text string
+}
+
+// RawEventDetails holds structured details of an event type without a
+// typed payload
+type RawEventDetails struct {
+	Raw json.RawMessage
+}
+
+func (*DeviceEventDetails) eventDetails()    {}
+func (*CardStateEventDetails) eventDetails() {}
+func (*TextEventDetails) eventDetails()      {}
+func (*RawEventDetails) eventDetails()       {}
+
+// NormalizedEvent is an event log entry with consistent types. Decoding is
+// tolerant: fields the SDK does not know, and times it cannot parse, are
+// kept in Extra rather than failing the whole entry.
+type NormalizedEvent struct {
+	ID         EventID
+	Type       EventType
+	UserID     string
+	CardID     string
+	TemplateID string
+	Device     string
+	IPAddress  string
+	UserAgent  string
+	// Timestamp is when the event happened, falling back to CreatedAt
+	Timestamp time.Time
+	CreatedAt time.Time
+	Details   EventDetails
+	Metadata  map[string]interface{}
+	Extra     map[string]json.RawMessage
+}
+
+// Key identifies the event for deduplication. Events without an ID fall
+// back to the fields that describe them.
+func (e NormalizedEvent) Key() string {
+	if e.ID != "" {
+		return string(e.ID)
+	}
+	return fmt.Sprintf("%s|%s|%s|%s", e.Timestamp.Format(time.RFC3339Nano), e.Type, e.CardID, e.UserID)
+}
+
+// eventTimeLayouts are the time formats seen in event logs
+var eventTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+}
+
+// ParseEventTime parses an event time given as a string in one of the
+// formats the API uses, or as Unix seconds
+func ParseEventTime(data json.RawMessage) (time.Time, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return time.Time{}, nil
+	}
+
+	if data[0] != '"' {
+		seconds, err := strconv.ParseFloat(string(data), 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid event time %s", data)
+		}
+		whole := int64(seconds)
+		return time.Unix(whole, int64((seconds-float64(whole))*1e9)).UTC(), nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return time.Time{}, err
+	}
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range eventTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid event time %q", s)
+}
+
+// UnmarshalJSON decodes an event log entry
+func (e *NormalizedEvent) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*e = NormalizedEvent{}
+	take := func(name string) (json.RawMessage, bool) {
+		raw, ok := fields[name]
+		delete(fields, name)
+		return raw, ok
+	}
+	str := func(name string) string {
+		var s string
+		if raw, ok := take(name); ok && json.Unmarshal(raw, &s) != nil {
+			fields[name] = raw
+		}
+		return s
+	}
+
+	if raw, ok := take("id"); ok {
+		if err := json.Unmarshal(raw, &e.ID); err != nil {
+			fields["id"] = raw
+		}
+	}
+	e.Type = EventType(str("type"))
+	if event := str("event"); e.Type == "" {
+		e.Type = EventType(event)
+	}
+	e.UserID = str("user_id")
+	e.CardID = str("card_id")
+	e.TemplateID = str("template_id")
+	e.Device = str("device")
+	e.IPAddress = str("ip_address")
+	e.UserAgent = str("user_agent")
+
+	for name, dst := range map[string]*time.Time{"timestamp": &e.Timestamp, "created_at": &e.CreatedAt} {
+		if raw, ok := take(name); ok {
+			t, err := ParseEventTime(raw)
+			if err != nil {
+				fields[name] = raw
+				continue
+			}
+			*dst = t
+		}
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = e.CreatedAt
+	}
+
+	if raw, ok := take("details"); ok {
+		e.Details = decodeEventDetails(e.Type, raw)
+	}
+	if raw, ok := take("metadata"); ok {
+		if err := json.Unmarshal(raw, &e.Metadata); err != nil {
+			fields["metadata"] = raw
+		}
+	}
+
+	if len(fields) > 0 {
+		e.Extra = fields
+	}
+	return nil
+}
+
+// decodeEventDetails types a details payload by event type. Details may
+// be a JSON object, a string holding a JSON object, or plain text.
+func decodeEventDetails(eventType EventType, raw json.RawMessage) EventDetails {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil
+	}
+
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return &RawEventDetails{Raw: raw}
+		}
+		trimmed := strings.TrimSpace(s)
+		if trimmed == "" {
+			return nil
+		}
+		if !strings.HasPrefix(trimmed, "{") || !json.Valid([]byte(trimmed)) {
+			return &TextEventDetails{Text: s}
+		}
+		raw = json.RawMessage(trimmed)
+	}
+
+	switch eventType {
+	case EventTypeInstall, EventTypeUninstall:
+		details := &DeviceEventDetails{Raw: raw}
+		if json.Unmarshal(raw, details) == nil {
+			return details
+		}
+	case EventTypeSuspend, EventTypeResume, EventTypeUnlink, EventTypeDelete, EventTypeUpdate, EventTypeProvision:
+		details := &CardStateEventDetails{Raw: raw}
+		if json.Unmarshal(raw, details) == nil {
+			return details
+		}
+	}
+	return &RawEventDetails{Raw: raw}
+}
+
+// Normalize converts an event decoded into the original Event struct
+func (e Event) Normalize() NormalizedEvent {
+	normalized := NormalizedEvent{
+		Type:       EventType(e.Type),
+		UserID:     e.UserID,
+		CardID:     e.CardID,
+		TemplateID: e.TemplateID,
+		Device:     e.Device,
+		IPAddress:  e.IPAddress,
+		UserAgent:  e.UserAgent,
+		Timestamp:  e.Timestamp.UTC(),
+	}
+	if normalized.Type == "" {
+		normalized.Type = EventType(e.Event)
+	}
+
+	switch id := e.ID.(type) {
+	case nil:
+	case float64:
+		normalized.ID = EventID(strconv.FormatFloat(id, 'f', -1, 64))
+	default:
+		normalized.ID = EventID(fmt.Sprint(id))
+	}
+
+	if e.CreatedAt != "" {
+		quoted, _ := json.Marshal(e.CreatedAt)
+		if t, err := ParseEventTime(quoted); err == nil {
+			normalized.CreatedAt = t
+		}
+	}
+	if normalized.Timestamp.IsZero() {
+		normalized.Timestamp = normalized.CreatedAt
+	}
+
+	if e.Details != "" {
+		quoted, _ := json.Marshal(e.Details)
+		normalized.Details = decodeEventDetails(normalized.Type, quoted)
+	}
+	if metadata, ok := e.Metadata.(map[string]interface{}); ok {
+		normalized.Metadata = metadata
+	}
+	return normalized
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNormalizedEvent_UnmarshalJSON(t *testing.T) {
+	data := []byte(`{
+		"id": 1042,
+		"event": "install",
+		"user_id": "usr_456",
+		"card_id": "0xc4rd1d",
+		"template_id": "0xd3adb00b5",
+		"created_at": "2026-05-01 12:00:00 UTC",
+		"details": "{\"device_id\": \"dev_1\", \"platform\": \"apple\", \"device_type\": \"watch\"}",
+		"metadata": {"site": "hq"},
+		"region": "eu"
+	}`)
+
+	var event NormalizedEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if event.ID != "1042" {
+		t.Errorf("ID = %q, want 1042", event.ID)
+	}
+	if event.Type != EventTypeInstall {
+		t.Errorf("Type = %q, want install", event.Type)
+	}
+	want := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	if !event.CreatedAt.Equal(want) || !event.Timestamp.Equal(want) {
+		t.Errorf("CreatedAt = %v, Timestamp = %v, want %v", event.CreatedAt, event.Timestamp, want)
+	}
+
+	details, ok := event.Details.(*DeviceEventDetails)
+	if !ok {
+		t.Fatalf("Details = %T, want *DeviceEventDetails", event.Details)
+	}
+	if details.DeviceID != "dev_1" || details.Platform != DevicePlatformApple || details.DeviceType != DeviceTypeWatch {
+		t.Errorf("Details = %+v", details)
+	}
+	if event.Metadata["site"] != "hq" {
+		t.Errorf("Metadata = %v", event.Metadata)
+	}
+	if string(event.Extra["region"]) != `"eu"` {
+		t.Errorf("Extra = %v, want region preserved", event.Extra)
+	}
+}
+
+func TestNormalizedEvent_Tolerant(t *testing.T) {
+	data := []byte(`{
+		"id": "evt_1",
+		"type": "suspend",
+		"timestamp": "last tuesday",
+		"details": "Suspended by administrator",
+		"metadata": "not an object"
+	}`)
+
+	var event NormalizedEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if !event.Timestamp.IsZero() {
+		t.Errorf("Timestamp = %v, want zero", event.Timestamp)
+	}
+	if _, ok := event.Extra["timestamp"]; !ok {
+		t.Error("unparseable timestamp not preserved in Extra")
+	}
+	if _, ok := event.Extra["metadata"]; !ok {
+		t.Error("non-object metadata not preserved in Extra")
+	}
+	if text, ok := event.Details.(*TextEventDetails); !ok || text.Text != "Suspended by administrator" {
+		t.Errorf("Details = %#v, want text details", event.Details)
+	}
+}
+
+func TestNormalizedEvent_DetailTypes(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`{"type": "resume", "details": {"from_state": "suspended", "to_state": "active"}}`, "*models.CardStateEventDetails"},
+		{`{"type": "badge_scan", "details": {"reader": "lobby"}}`, "*models.RawEventDetails"},
+		{`{"type": "install", "details": ""}`, "<nil>"},
+	}
+
+	for _, tt := range tests {
+		var event NormalizedEvent
+		if err := json.Unmarshal([]byte(tt.data), &event); err != nil {
+			t.Fatalf("Unmarshal(%s) error = %v", tt.data, err)
+		}
+		if got := fmt.Sprintf("%T", event.Details); got != tt.want {
+			t.Errorf("Details of %s = %s, want %s", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestEvent_Normalize(t *testing.T) {
+	var event Event
+	data := []byte(`{"id": 7, "type": "install", "timestamp": "2026-05-01T12:00:00+02:00", "details": "{\"platform\": \"android\"}"}`)
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	normalized := event.Normalize()
+	if normalized.ID != "7" {
+		t.Errorf("ID = %q, want 7", normalized.ID)
+	}
+	if normalized.Timestamp.Location() != time.UTC || normalized.Timestamp.Hour() != 10 {
+		t.Errorf("Timestamp = %v, want 10:00 UTC", normalized.Timestamp)
+	}
+	if details, ok := normalized.Details.(*DeviceEventDetails); !ok || details.Platform != DevicePlatformAndroid {
+		t.Errorf("Details = %#v", normalized.Details)
+	}
+}
@@ -1,15 +1,39 @@
 package models
 
-import "time"
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DevicePlatform identifies the wallet platform a device belongs to
+type DevicePlatform string
+
+// Supported device platforms
+const (
+	DevicePlatformApple   DevicePlatform = "apple"
+	DevicePlatformAndroid DevicePlatform = "android"
+)
+
+// DeviceType identifies the kind of device an access pass is installed on
+type DeviceType string
+
+// Supported device types
+const (
+	DeviceTypePhone DeviceType = "phone"
+	DeviceTypeWatch DeviceType = "watch"
+)
 
 // Device represents a device associated with an access pass
 type Device struct {
-	ID         string    `json:"id"`
-	Platform   string    `json:"platform"`
-	DeviceType string    `json:"device_type"`
-	Status     string    `json:"status"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         string         `json:"id"`
+	Platform   DevicePlatform `json:"platform"`
+	DeviceType DeviceType     `json:"device_type"`
+	Status     string         `json:"status"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
 }
 
 // Card represents an NFC key or access pass
@@ -90,6 +114,31 @@ type ProvisionParams struct {
 	Metadata               map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// ProvisionCheck is an extra check run by ProvisionParams.Validate. It
+// returns the problems it finds, if any.
+type ProvisionCheck func(p ProvisionParams) []string
+
+// Validate checks the parameters before they are sent to the API, along
+// with any extra checks such as the card formats of the wiegand package.
+// Every problem found is listed in a *ValidationError.
+func (p ProvisionParams) Validate(checks ...ProvisionCheck) error {
+	var problems []string
+	if p.CardTemplateID == "" {
+		problems = append(problems, "card_template_id is required")
+	}
+	if !p.StartDate.IsZero() && !p.ExpirationDate.IsZero() && !p.ExpirationDate.After(p.StartDate) {
+		problems = append(problems, "expiration_date must be after start_date")
+	}
+	for _, check := range checks {
+		problems = append(problems, check(p)...)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
 // UpdateParams defines parameters for updating an existing card
 type UpdateParams struct {
 	CardID         string     `json:"card_id"`
@@ -120,17 +169,19 @@ type ListKeysParams struct {
 
 // Template represents a card template
 type Template struct {
-	ID          string         `json:"id"`
-	Name        string         `json:"name"`
-	Platform    string         `json:"platform"`
-	UseCase     string         `json:"use_case"`
-	Protocol    string         `json:"protocol"`
-	WatchCount  int            `json:"watch_count"`
-	IPhoneCount int            `json:"iphone_count"`
-	Design      TemplateDesign `json:"design"`
-	SupportInfo SupportInfo    `json:"support_info"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
+	ID                     string         `json:"id"`
+	Name                   string         `json:"name"`
+	Platform               string         `json:"platform"`
+	UseCase                string         `json:"use_case"`
+	Protocol               string         `json:"protocol"`
+	Status                 string         `json:"status,omitempty"`
+	AllowOnMultipleDevices bool           `json:"allow_on_multiple_devices"`
+	WatchCount             int            `json:"watch_count"`
+	IPhoneCount            int            `json:"iphone_count"`
+	Design                 TemplateDesign `json:"design"`
+	SupportInfo            SupportInfo    `json:"support_info"`
+	CreatedAt              time.Time      `json:"created_at"`
+	UpdatedAt              time.Time      `json:"updated_at"`
 }
 
 // TemplateDesign represents the design elements of a card template
@@ -202,6 +253,16 @@ type EventLogFilters struct {
 	EventType string     `json:"event_type,omitempty"`
 }
 
+// AccountEventLogFilters defines parameters for filtering event logs
+// across templates. An empty TemplateIDs covers every template in the
+// account.
+type AccountEventLogFilters struct {
+	EventLogFilters
+	TemplateIDs []string
+	CardID      string
+	UserID      string
+}
+
 // Event represents an event in the event log
 type Event struct {
 	ID         interface{} `json:"id"`
@@ -269,6 +330,15 @@ type CreatePassTemplatePairParams struct {
 	GoogleCardTemplateID string `json:"google_card_template_id"`
 }
 
+// UpdatePassTemplatePairParams defines parameters for updating a pass template pair.
+// Empty fields are left unchanged.
+type UpdatePassTemplatePairParams struct {
+	PassTemplatePairID   string `json:"card_template_pair_id"`
+	Name                 string `json:"name,omitempty"`
+	AppleCardTemplateID  string `json:"apple_card_template_id,omitempty"`
+	GoogleCardTemplateID string `json:"google_card_template_id,omitempty"`
+}
+
 // LedgerItemPassTemplate represents a pass template reference within a ledger item's access pass
 type LedgerItemPassTemplate struct {
 	ID       string `json:"id"`
@@ -415,27 +485,303 @@ type UpdateLandingPageParams struct {
 	Logo                   string `json:"logo,omitempty"`
 }
 
-// CredentialProfile represents a credential profile
-type CredentialProfile struct {
-	ID          string                 `json:"id"`
-	AID         string                 `json:"aid"`
-	Name        string                 `json:"name"`
-	AppleID     string                 `json:"apple_id,omitempty"`
-	CreatedAt   string                 `json:"created_at"`
-	CardStorage interface{} `json:"card_storage,omitempty"`
-	Keys        []interface{}          `json:"keys,omitempty"`
-	Files       []interface{}          `json:"files,omitempty"`
+// KeyType identifies the cipher of a credential profile key
+type KeyType string
+
+// Supported key types
+const (
+	KeyTypeAES128 KeyType = "aes128"
+	KeyType2K3DES KeyType = "2k3des"
+	KeyType3K3DES KeyType = "3k3des"
+)
+
+// Credential profile protocols
+const (
+	ProfileProtocolDESFire = "desfire"
+	ProfileProtocolSEOS    = "seos"
+)
+
+// SEOSKeyRole identifies a key of a SEOS key set. A SEOS application has no
+// numbered key slots; it is protected by a privacy key, which secures
+// messaging with the reader, and an authentication key.
+type SEOSKeyRole string
+
+// SEOS key roles
+const (
+	SEOSKeyPrivacy SEOSKeyRole = "privacy"
+	SEOSKeyAuth    SEOSKeyRole = "auth"
+)
+
+// seosKeyRoles lists the roles a SEOS key set needs, in order
+var seosKeyRoles = []SEOSKeyRole{SEOSKeyPrivacy, SEOSKeyAuth}
+
+// KeyLength returns the length in bytes of keys of this type, or 0 for an
+// unknown type
+func (t KeyType) KeyLength() int {
+	switch t {
+	case KeyTypeAES128, KeyType2K3DES:
+		return 16
+	case KeyType3K3DES:
+		return 24
+	}
+	return 0
+}
+
+// Special key numbers in DESFire access rights
+const (
+	// AccessFree grants access without authentication
+	AccessFree = 14
+	// AccessDeny denies access
+	AccessDeny = 15
+	// MaxKeyNumber is the highest key number an application can hold
+	MaxKeyNumber = 13
+)
+
+// AccessRights lists the key number required for each operation on a file.
+// Values are key numbers 0-13, AccessFree or AccessDeny.
+type AccessRights struct {
+	Read      int `json:"read"`
+	Write     int `json:"write"`
+	ReadWrite int `json:"read_write"`
+	Change    int `json:"change"`
+}
+
+// String formats the access rights for review, e.g. "read=key1 write=deny"
+func (a AccessRights) String() string {
+	name := func(n int) string {
+		switch n {
+		case AccessFree:
+			return "free"
+		case AccessDeny:
+			return "deny"
+		}
+		return fmt.Sprintf("key%d", n)
+	}
+	return fmt.Sprintf("read=%s write=%s read_write=%s change=%s",
+		name(a.Read), name(a.Write), name(a.ReadWrite), name(a.Change))
+}
+
+// CardStorage describes the storage a credential profile occupies on the card
+type CardStorage struct {
+	Protocol string `json:"protocol,omitempty"`
+	Type     string `json:"type"`
+	Size     int    `json:"size"`
+	Used     int    `json:"used,omitempty"`
+}
+
+// CredentialProfileKey describes a key slot of a credential profile. Key
+// material is never returned by the API. DESFire keys have a KeyNumber and
+// SEOS keys a Role.
+type CredentialProfileKey struct {
+	KeyNumber int         `json:"key_number"`
+	Role      SEOSKeyRole `json:"role,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	KeyType   KeyType     `json:"key_type,omitempty"`
+	Version   int         `json:"version"`
+}
+
+// CredentialProfileFile describes a file stored by a credential profile
+type CredentialProfileFile struct {
+	FileID            string        `json:"file_id"`
+	FileType          string        `json:"file_type,omitempty"`
+	CommunicationMode string        `json:"communication_mode,omitempty"`
+	Size              int           `json:"size"`
+	AccessRights      *AccessRights `json:"access_rights,omitempty"`
 }
 
-// KeyParam represents a key parameter for credential profile creation
+// CredentialProfile represents a credential profile
+type CredentialProfile struct {
+	ID          string                  `json:"id"`
+	AID         string                  `json:"aid"`
+	Name        string                  `json:"name"`
+	AppleID     string                  `json:"apple_id,omitempty"`
+	CreatedAt   string                  `json:"created_at"`
+	CardStorage *CardStorage            `json:"card_storage,omitempty"`
+	Keys        []CredentialProfileKey  `json:"keys,omitempty"`
+	Files       []CredentialProfileFile `json:"files,omitempty"`
+}
+
+// KeyParam represents a key parameter for credential profile creation.
+// DESFire keys are assigned to key numbers in the order they are given,
+// starting with the application master key. SEOS keys name their Role.
 type KeyParam struct {
+	// Value is the key as hex, 32 characters for AES-128 and 2K3DES keys
+	// and 48 characters for 3K3DES keys
 	Value string `json:"value"`
+	// KeyType defaults to AES-128
+	KeyType KeyType `json:"key_type,omitempty"`
+	Version int     `json:"version,omitempty"`
+	// Role is set on SEOS keys only
+	Role SEOSKeyRole `json:"role,omitempty"`
+}
+
+// String redacts the key value so keys do not end up in logs
+func (k KeyParam) String() string {
+	if k.Role != "" {
+		return fmt.Sprintf("KeyParam{Role: %s, KeyType: %s, Version: %d, Value: [redacted]}", k.Role, k.keyType(), k.Version)
+	}
+	return fmt.Sprintf("KeyParam{KeyType: %s, Version: %d, Value: [redacted]}", k.keyType(), k.Version)
+}
+
+// GoString redacts the key value when formatted with %#v
+func (k KeyParam) GoString() string {
+	return k.String()
+}
+
+func (k KeyParam) keyType() KeyType {
+	if k.KeyType == "" {
+		return KeyTypeAES128
+	}
+	return k.KeyType
 }
 
 // CreateCredentialProfileParams defines parameters for creating a credential profile
 type CreateCredentialProfileParams struct {
-	Name    string     `json:"name"`
-	AppName string     `json:"app_name"`
-	Keys    []KeyParam `json:"keys,omitempty"`
-	FileID  string     `json:"file_id,omitempty"`
+	Name    string `json:"name"`
+	AppName string `json:"app_name"`
+	// Protocol is ProfileProtocolDESFire (the default) or
+	// ProfileProtocolSEOS
+	Protocol string     `json:"protocol,omitempty"`
+	Keys     []KeyParam `json:"keys,omitempty"`
+	// FileID is the DESFire file holding the credential
+	FileID string `json:"file_id,omitempty"`
+}
+
+// ValidationError lists every problem found when validating parameters
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return "invalid parameters: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the profile for missing fields, malformed key material and
+// file IDs before it is sent to the API. A SEOS profile needs one privacy
+// and one authentication key and has no file ID. Error messages never
+// include key values.
+func (p CreateCredentialProfileParams) Validate() error {
+	var problems []string
+	if p.Name == "" {
+		problems = append(problems, "name is required")
+	}
+	if p.AppName == "" {
+		problems = append(problems, "app_name is required")
+	}
+
+	switch p.Protocol {
+	case "", ProfileProtocolDESFire:
+		problems = append(problems, validateKeys(p.Keys, ProfileProtocolDESFire)...)
+		if p.FileID != "" {
+			if raw, err := hex.DecodeString(p.FileID); err != nil || len(raw) != 1 || raw[0] > 0x1f {
+				problems = append(problems, fmt.Sprintf("file_id must be a hex byte between 00 and 1F, got %q", p.FileID))
+			}
+		}
+	case ProfileProtocolSEOS:
+		problems = append(problems, validateKeys(p.Keys, ProfileProtocolSEOS)...)
+		roles := map[SEOSKeyRole]bool{}
+		for _, key := range p.Keys {
+			roles[key.Role] = true
+		}
+		for _, role := range seosKeyRoles {
+			if !roles[role] {
+				problems = append(problems, fmt.Sprintf("the SEOS key set has no %s key", role))
+			}
+		}
+		if p.FileID != "" {
+			problems = append(problems, "file_id is only used by DESFire profiles")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown protocol %q, want %s or %s", p.Protocol, ProfileProtocolDESFire, ProfileProtocolSEOS))
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateKeys checks key material for a protocol. SEOS keys need a
+// distinct role and are AES-128 or 2K3DES; DESFire keys have no role and
+// fit the application's key numbers.
+func validateKeys(keys []KeyParam, protocol string) []string {
+	var problems []string
+	if protocol == ProfileProtocolDESFire && len(keys) > MaxKeyNumber+1 {
+		problems = append(problems, fmt.Sprintf("%d keys given, at most %d fit in an application", len(keys), MaxKeyNumber+1))
+	}
+
+	seen := map[string]int{}
+	roles := map[SEOSKeyRole]int{}
+	for i, key := range keys {
+		keyType := key.keyType()
+		if protocol == ProfileProtocolSEOS {
+			switch {
+			case !slices.Contains(seosKeyRoles, key.Role):
+				problems = append(problems, fmt.Sprintf("keys[%d]: role must be %s or %s, got %q", i, SEOSKeyPrivacy, SEOSKeyAuth, key.Role))
+			case keyType == KeyType3K3DES:
+				problems = append(problems, fmt.Sprintf("keys[%d]: SEOS keys must be %s or %s", i, KeyTypeAES128, KeyType2K3DES))
+			}
+			if j, ok := roles[key.Role]; ok && key.Role != "" {
+				problems = append(problems, fmt.Sprintf("keys[%d] repeats the %s role of keys[%d]", i, key.Role, j))
+			}
+			roles[key.Role] = i
+		} else if key.Role != "" {
+			problems = append(problems, fmt.Sprintf("keys[%d]: role is only used by SEOS keys", i))
+		}
+
+		length := keyType.KeyLength()
+		if length == 0 {
+			problems = append(problems, fmt.Sprintf("keys[%d]: unknown key_type %q", i, key.KeyType))
+			continue
+		}
+		raw, err := hex.DecodeString(key.Value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("keys[%d]: value is not valid hex", i))
+			continue
+		}
+		if len(raw) != length {
+			problems = append(problems, fmt.Sprintf("keys[%d]: %s keys must be %d hex characters, got %d", i, keyType, length*2, len(key.Value)))
+			continue
+		}
+		if key.Version < 0 || key.Version > 255 {
+			problems = append(problems, fmt.Sprintf("keys[%d]: version must be between 0 and 255, got %d", i, key.Version))
+		}
+		normalized := strings.ToLower(key.Value)
+		if j, ok := seen[normalized]; ok {
+			problems = append(problems, fmt.Sprintf("keys[%d] reuses the value of keys[%d]", i, j))
+		}
+		seen[normalized] = i
+	}
+	return problems
+}
+
+// UpdateCredentialProfileParams defines parameters for updating a credential profile.
+// Empty fields are left unchanged.
+type UpdateCredentialProfileParams struct {
+	CredentialProfileID string     `json:"credential_profile_id"`
+	Name                string     `json:"name,omitempty"`
+	Keys                []KeyParam `json:"keys,omitempty"`
+}
+
+// Validate checks replacement keys the way CreateCredentialProfileParams
+// does. Keys with a role are checked as a SEOS key set, others as DESFire
+// keys.
+func (p UpdateCredentialProfileParams) Validate() error {
+	var problems []string
+	if p.CredentialProfileID == "" {
+		problems = append(problems, "credential_profile_id is required")
+	}
+	protocol := ProfileProtocolDESFire
+	for _, key := range p.Keys {
+		if key.Role != "" {
+			protocol = ProfileProtocolSEOS
+		}
+	}
+	problems = append(problems, validateKeys(p.Keys, protocol)...)
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
 }
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testMasterKey = "00112233445566778899aabbccddeeff"
+	testReadKey   = "ffeeddccbbaa99887766554433221100"
+)
+
+func TestCreateCredentialProfileParams_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  CreateCredentialProfileParams
+		wantErr string
+	}{
+		{
+			name: "Valid AES keys",
+			params: CreateCredentialProfileParams{
+				Name:    "Main Office",
+				AppName: "KEY-ID-main",
+				Keys:    []KeyParam{{Value: testMasterKey}, {Value: strings.ToUpper(testReadKey), Version: 2}},
+				FileID:  "00",
+			},
+		},
+		{
+			name: "Valid 3K3DES key",
+			params: CreateCredentialProfileParams{
+				Name:    "Legacy",
+				AppName: "KEY-ID-legacy",
+				Keys:    []KeyParam{{Value: testMasterKey + "0011223344556677", KeyType: KeyType3K3DES}},
+			},
+		},
+		{
+			name:    "Missing names",
+			params:  CreateCredentialProfileParams{},
+			wantErr: "name is required; app_name is required",
+		},
+		{
+			name: "Short key",
+			params: CreateCredentialProfileParams{
+				Name: "Main Office", AppName: "KEY-ID-main",
+				Keys: []KeyParam{{Value: testMasterKey[:30]}},
+			},
+			wantErr: "keys[0]: aes128 keys must be 32 hex characters, got 30",
+		},
+		{
+			name: "Non-hex key",
+			params: CreateCredentialProfileParams{
+				Name: "Main Office", AppName: "KEY-ID-main",
+				Keys: []KeyParam{{Value: "your_32_char_hex_master_key_here"}},
+			},
+			wantErr: "keys[0]: value is not valid hex",
+		},
+		{
+			name: "Reused key",
+			params: CreateCredentialProfileParams{
+				Name: "Main Office", AppName: "KEY-ID-main",
+				Keys: []KeyParam{{Value: testMasterKey}, {Value: testMasterKey}},
+			},
+			wantErr: "keys[1] reuses the value of keys[0]",
+		},
+		{
+			name: "Unknown key type",
+			params: CreateCredentialProfileParams{
+				Name: "Main Office", AppName: "KEY-ID-main",
+				Keys: []KeyParam{{Value: testMasterKey, KeyType: "des"}},
+			},
+			wantErr: `keys[0]: unknown key_type "des"`,
+		},
+		{
+			name: "File ID out of range",
+			params: CreateCredentialProfileParams{
+				Name: "Main Office", AppName: "KEY-ID-main",
+				FileID: "20",
+			},
+			wantErr: "file_id must be a hex byte between 00 and 1F",
+		},
+		{
+			name: "Valid SEOS key set",
+			params: CreateCredentialProfileParams{
+				Name: "Main Office", AppName: "KEY-ID-seos", Protocol: ProfileProtocolSEOS,
+				Keys: []KeyParam{
+					{Value: testMasterKey, Role: SEOSKeyPrivacy},
+					{Value: testReadKey, KeyType: KeyType2K3DES, Role: SEOSKeyAuth},
+				},
+			},
+		},
+		{
+			name: "Incomplete SEOS key set",
+			params: CreateCredentialProfileParams{
+				Name: "Main Office", AppName: "KEY-ID-seos", Protocol: ProfileProtocolSEOS,
+				Keys: []KeyParam{{Value: testMasterKey, Role: SEOSKeyPrivacy}, {Value: testReadKey, Role: SEOSKeyPrivacy}},
+			},
+			wantErr: "keys[1] repeats the privacy role of keys[0]; the SEOS key set has no auth key",
+		},
+		{
+			name: "SEOS 3K3DES key",
+			params: CreateCredentialProfileParams{
+				Name: "Main Office", AppName: "KEY-ID-seos", Protocol: ProfileProtocolSEOS,
+				Keys: []KeyParam{
+					{Value: testMasterKey + "0011223344556677", KeyType: KeyType3K3DES, Role: SEOSKeyPrivacy},
+					{Value: testReadKey, Role: SEOSKeyAuth},
+				},
+			},
+			wantErr: "keys[0]: SEOS keys must be aes128 or 2k3des",
+		},
+		{
+			name: "SEOS file ID",
+			params: CreateCredentialProfileParams{
+				Name: "Main Office", AppName: "KEY-ID-seos", Protocol: ProfileProtocolSEOS, FileID: "00",
+				Keys: []KeyParam{{Value: testMasterKey, Role: SEOSKeyPrivacy}, {Value: testReadKey, Role: SEOSKeyAuth}},
+			},
+			wantErr: "file_id is only used by DESFire profiles",
+		},
+		{
+			name: "Role on a DESFire key",
+			params: CreateCredentialProfileParams{
+				Name: "Main Office", AppName: "KEY-ID-main",
+				Keys: []KeyParam{{Value: testMasterKey, Role: SEOSKeyAuth}},
+			},
+			wantErr: "keys[0]: role is only used by SEOS keys",
+		},
+		{
+			name: "Unknown protocol",
+			params: CreateCredentialProfileParams{
+				Name: "Main Office", AppName: "KEY-ID-main", Protocol: "iclass",
+			},
+			wantErr: `unknown protocol "iclass"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() error = %v, want *ValidationError", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+			for _, key := range tt.params.Keys {
+				if key.Value != "" && strings.Contains(err.Error(), key.Value) {
+					t.Errorf("Validate() error leaks key material: %s", err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateCredentialProfileParams_Validate(t *testing.T) {
+	valid := UpdateCredentialProfileParams{CredentialProfileID: "cp_1", Keys: []KeyParam{{Value: testMasterKey, Version: 2}}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() error = %v, want nil", err)
+	}
+	rotateAuth := UpdateCredentialProfileParams{CredentialProfileID: "cp_1", Keys: []KeyParam{{Value: testReadKey, Role: SEOSKeyAuth}}}
+	if err := rotateAuth.Validate(); err != nil {
+		t.Errorf("Validate() of a SEOS key error = %v, want nil", err)
+	}
+
+	invalid := UpdateCredentialProfileParams{Keys: []KeyParam{{Value: testMasterKey[:30]}, {Value: testReadKey, Role: SEOSKeyAuth}}}
+	err := invalid.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate() error = %v, want *ValidationError", err)
+	}
+	want := []string{
+		"credential_profile_id is required",
+		`keys[0]: role must be privacy or auth, got ""`,
+		"keys[0]: aes128 keys must be 32 hex characters, got 30",
+	}
+	if strings.Join(validationErr.Problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems = %q, want %q", validationErr.Problems, want)
+	}
+}
+
+func TestProvisionParams_Validate(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	valid := ProvisionParams{CardTemplateID: "0xd3adb00b5", StartDate: start, ExpirationDate: start.AddDate(1, 0, 0)}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() error = %v, want nil", err)
+	}
+
+	invalid := ProvisionParams{StartDate: start, ExpirationDate: start, CardNumber: "12"}
+	noFacility := func(p ProvisionParams) []string {
+		if p.SiteCode == "" {
+			return []string{"site_code is required"}
+		}
+		return nil
+	}
+	var validationErr *ValidationError
+	if err := invalid.Validate(noFacility); !errors.As(err, &validationErr) {
+		t.Fatalf("Validate() error = %v, want *ValidationError", err)
+	}
+	want := []string{"card_template_id is required", "expiration_date must be after start_date", "site_code is required"}
+	if strings.Join(validationErr.Problems, ",") != strings.Join(want, ",") {
+		t.Errorf("problems = %q, want %q", validationErr.Problems, want)
+	}
+}
+
+func TestKeyParam_Redacted(t *testing.T) {
+	key := KeyParam{Value: testMasterKey}
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		if out := fmt.Sprintf(format, key); strings.Contains(out, testMasterKey) {
+			t.Errorf("fmt %s leaks key material: %s", format, out)
+		}
+	}
+
+	// The API still receives the key
+	data, _ := json.Marshal(key)
+	if !strings.Contains(string(data), testMasterKey) {
+		t.Errorf("json.Marshal() = %s, want key value", data)
+	}
+}
+
+func TestCredentialProfile_Decode(t *testing.T) {
+	var profile CredentialProfile
+	err := json.Unmarshal([]byte(`{
+		"id": "cp_1",
+		"card_storage": {"protocol": "desfire", "type": "desfire_ev3", "size": 8192, "used": 256},
+		"keys": [{"key_number": 0, "name": "master", "key_type": "aes128", "version": 1}],
+		"files": [{
+			"file_id": "00",
+			"file_type": "standard",
+			"communication_mode": "full",
+			"size": 32,
+			"access_rights": {"read": 1, "write": 15, "read_write": 0, "change": 0}
+		}]
+	}`), &profile)
+	if err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if profile.CardStorage.Used != 256 || profile.Keys[0].KeyType != KeyTypeAES128 {
+		t.Errorf("profile = %+v", profile)
+	}
+	rights := profile.Files[0].AccessRights
+	if rights == nil {
+		t.Fatal("Files[0].AccessRights is nil")
+	}
+	if got := rights.String(); got != "read=key1 write=deny read_write=key0 change=key0" {
+		t.Errorf("AccessRights.String() = %q", got)
+	}
+}
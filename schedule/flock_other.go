@@ -0,0 +1,18 @@
+//go:build !unix
+
+package schedule
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoFlock = errors.New("file locks are not supported on this platform")
+
+func lockFile(file *os.File) (bool, error) {
+	return false, errNoFlock
+}
+
+func unlockFile(file *os.File) error {
+	return errNoFlock
+}
@@ -0,0 +1,145 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/Access-Grid/accessgrid-go/internal/atomicfile"
+)
+
+// HoldStore records the cards a Scheduler suspended to wait for their
+// start date. The scheduler resumes only cards it holds, so a pass
+// suspended by anyone else stays suspended. Implement it on a shared store
+// to run replicas on several hosts.
+type HoldStore interface {
+	// Holds returns the IDs of the held cards
+	Holds(ctx context.Context) (map[string]bool, error)
+	// Hold records a card as held
+	Hold(ctx context.Context, cardID string) error
+	// Release forgets a card. Releasing a card that is not held is not an
+	// error.
+	Release(ctx context.Context, cardID string) error
+}
+
+// MemoryHolds is a HoldStore for a single process. Holds are lost when the
+// process exits, and the passes held then stay suspended past their start
+// date; use FileHolds to keep them.
+type MemoryHolds struct {
+	mu    sync.Mutex
+	holds map[string]bool
+}
+
+// Holds returns a copy of the held card IDs
+func (h *MemoryHolds) Holds(ctx context.Context) (map[string]bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	holds := make(map[string]bool, len(h.holds))
+	for id := range h.holds {
+		holds[id] = true
+	}
+	return holds, nil
+}
+
+// Hold records a card as held
+func (h *MemoryHolds) Hold(ctx context.Context, cardID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.holds == nil {
+		h.holds = map[string]bool{}
+	}
+	h.holds[cardID] = true
+	return nil
+}
+
+// Release forgets a card
+func (h *MemoryHolds) Release(ctx context.Context, cardID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.holds, cardID)
+	return nil
+}
+
+// FileHolds is a HoldStore backed by a JSON file, for schedulers on one
+// host. The file is read on every call, so a scheduler that takes over the
+// lock sees the holds of the one before it, and rewritten atomically on
+// every change.
+type FileHolds struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Holds reads the held card IDs. A missing file holds no cards.
+func (h *FileHolds) Holds(ctx context.Context) (map[string]bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.load()
+}
+
+// Hold records a card as held and saves the file
+func (h *FileHolds) Hold(ctx context.Context, cardID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	holds, err := h.load()
+	if err != nil {
+		return err
+	}
+	if holds[cardID] {
+		return nil
+	}
+	holds[cardID] = true
+	return h.save(holds)
+}
+
+// Release forgets a card and saves the file
+func (h *FileHolds) Release(ctx context.Context, cardID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	holds, err := h.load()
+	if err != nil {
+		return err
+	}
+	if !holds[cardID] {
+		return nil
+	}
+	delete(holds, cardID)
+	return h.save(holds)
+}
+
+// load reads the file. The caller must hold h.mu.
+func (h *FileHolds) load() (map[string]bool, error) {
+	holds := map[string]bool{}
+	data, err := os.ReadFile(h.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return holds, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading holds: %w", err)
+	}
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("error parsing holds %s: %w", h.Path, err)
+	}
+	for _, id := range ids {
+		holds[id] = true
+	}
+	return holds, nil
+}
+
+// save writes the file atomically. The caller must hold h.mu.
+func (h *FileHolds) save(holds map[string]bool) error {
+	ids := make([]string, 0, len(holds))
+	for id := range holds {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if err := atomicfile.WriteJSON(h.Path, ids); err != nil {
+		return fmt.Errorf("error writing holds: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Locker elects the scheduler that runs. Implement it on a shared store,
+// such as a database row or a Redis key, to run several replicas with
+// only one acting at a time.
+type Locker interface {
+	// TryLock acquires or renews the lock for owner until ttl from now and
+	// reports whether owner holds it
+	TryLock(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+	// Unlock releases the lock if owner holds it
+	Unlock(ctx context.Context, owner string) error
+}
+
+// lease is the state of a lock
+type lease struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// MemoryLock is a Locker for schedulers in one process
+type MemoryLock struct {
+	mu    sync.Mutex
+	lease lease
+	now   func() time.Time
+}
+
+// TryLock takes the lock when it is free, expired or already owner's
+func (l *MemoryLock) TryLock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.now != nil {
+		now = l.now()
+	}
+	if l.lease.Owner != "" && l.lease.Owner != owner && now.Before(l.lease.Expires) {
+		return false, nil
+	}
+	l.lease = lease{Owner: owner, Expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Unlock releases the lock
+func (l *MemoryLock) Unlock(ctx context.Context, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lease.Owner == owner {
+		l.lease = lease{}
+	}
+	return nil
+}
+
+// FileLock is a Locker backed by an advisory lock on a file (flock), for
+// schedulers on one host. The lock is held from TryLock until Unlock or
+// until the process exits, so a crashed scheduler never blocks the others;
+// the ttl is not used. The file records the current owner for operators
+// and is never removed. On platforms without flock, TryLock returns an
+// error.
+type FileLock struct {
+	Path string
+
+	mu    sync.Mutex
+	file  *os.File
+	owner string
+}
+
+// TryLock takes the lock when no other process or FileLock holds it, and
+// reports true while owner holds it
+func (l *FileLock) TryLock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		return l.owner == owner, nil
+	}
+
+	file, err := os.OpenFile(l.Path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("error opening lock: %w", err)
+	}
+	held, err := lockFile(file)
+	if err != nil || !held {
+		file.Close()
+		if err != nil {
+			return false, fmt.Errorf("error taking lock: %w", err)
+		}
+		return false, nil
+	}
+	l.file, l.owner = file, owner
+
+	// The owner is informational; failing to record it does not lose the
+	// lock
+	if data, err := json.Marshal(lease{Owner: owner, Expires: time.Now().Add(ttl)}); err == nil {
+		if file.Truncate(0) == nil {
+			file.WriteAt(append(data, '\n'), 0)
+		}
+	}
+	return true, nil
+}
+
+// Unlock releases the lock if owner holds it
+func (l *FileLock) Unlock(ctx context.Context, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil || l.owner != owner {
+		return nil
+	}
+	file := l.file
+	l.file, l.owner = nil, ""
+	file.Truncate(0)
+	if err := unlockFile(file); err != nil {
+		file.Close()
+		return fmt.Errorf("error releasing lock: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error releasing lock: %w", err)
+	}
+	return nil
+}
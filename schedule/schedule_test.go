@@ -0,0 +1,324 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+var now = time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+
+// cardServer lists fixed cards and records the changes made to them
+type cardServer struct {
+	mu       sync.Mutex
+	cards    []models.Card
+	requests []string
+	updates  map[string]models.UpdateParams
+}
+
+func (s *cardServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.cards})
+		return
+	}
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	if r.Method == http.MethodPatch {
+		var params models.UpdateParams
+		json.NewDecoder(r.Body).Decode(&params)
+		s.updates[strings.TrimPrefix(r.URL.Path, "/v1/key-cards/")] = params
+	}
+	w.Write([]byte(`{}`))
+}
+
+func (s *cardServer) changes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes := append([]string(nil), s.requests...)
+	sort.Strings(changes)
+	return changes
+}
+
+func newTestScheduler(t *testing.T, cards []models.Card, config Config) (*Scheduler, *cardServer) {
+	t.Helper()
+	server := &cardServer{cards: cards, updates: map[string]models.UpdateParams{}}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	c, err := client.NewClient("test-account", "test-secret", client.WithBaseURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Now == nil {
+		config.Now = func() time.Time { return now }
+	}
+	s, err := New(services.NewAccessCardsService(c), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, server
+}
+
+func testCards() []models.Card {
+	day := 24 * time.Hour
+	return []models.Card{
+		// Expired and active: suspended
+		{ID: "expired", State: "active", ExpirationDate: now.Add(-time.Hour)},
+		// Expired and already suspended: left alone
+		{ID: "expired-suspended", State: "suspended", ExpirationDate: now.Add(-day)},
+		// Not yet expired
+		{ID: "current", State: "active", ExpirationDate: now.Add(30 * day)},
+		// Flagged for renewal and expiring in three days: extended by ten
+		{ID: "renew", State: "active", ExpirationDate: now.Add(3 * day), Metadata: map[string]interface{}{
+			MetadataAutoRenew: true, MetadataRenewDays: float64(10),
+		}},
+		// Flagged, but its renewals ended: expires normally
+		{ID: "renew-ended", State: "active", ExpirationDate: now.Add(-time.Hour), Metadata: map[string]interface{}{
+			MetadataAutoRenew: "true", MetadataRenewUntil: "2026-06-01",
+		}},
+		// Renewal capped at its end date
+		{ID: "renew-capped", State: "active", ExpirationDate: now.Add(day), Metadata: map[string]interface{}{
+			MetadataAutoRenew: true, MetadataRenewUntil: "2026-06-20",
+		}},
+		// Contractor starting tomorrow: kept suspended
+		{ID: "future", State: "active", StartDate: now.Add(day), ExpirationDate: now.Add(90 * day), Metadata: map[string]interface{}{
+			MetadataActivateOnStart: true,
+		}},
+		// Contractor who started an hour ago, held by the scheduler: resumed
+		{ID: "started", State: "suspended", StartDate: now.Add(-time.Hour), ExpirationDate: now.Add(90 * day), Metadata: map[string]interface{}{
+			MetadataActivateOnStart: "true",
+		}},
+		// Flagged, but suspended by someone else, such as for a lost phone:
+		// left alone
+		{ID: "started-suspended", State: "suspended", StartDate: now.Add(-day), ExpirationDate: now.Add(90 * day), Metadata: map[string]interface{}{
+			MetadataActivateOnStart: true,
+		}},
+		// Held, but replaced by a reissue: left alone and released
+		{ID: "reissued", State: "suspended", StartDate: now.Add(-time.Hour), ExpirationDate: now.Add(90 * day), Metadata: map[string]interface{}{
+			MetadataActivateOnStart: true,
+		}},
+		{ID: "replacement", State: "active", StartDate: now.Add(-time.Hour), ExpirationDate: now.Add(90 * day), Metadata: map[string]interface{}{
+			MetadataActivateOnStart: true, services.MetadataReissuedFrom: "reissued",
+		}},
+		// Held, but resumed by hand since: released
+		{ID: "resumed", State: "active", StartDate: now.Add(-time.Hour), ExpirationDate: now.Add(90 * day), Metadata: map[string]interface{}{
+			MetadataActivateOnStart: true,
+		}},
+		// Suspended by hand without the flag: left alone
+		{ID: "manual", State: "suspended", StartDate: now.Add(-day), ExpirationDate: now.Add(90 * day)},
+		{ID: "gone", State: "deleted", ExpirationDate: now.Add(-day)},
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	ctx := context.Background()
+	holds := &MemoryHolds{}
+	for _, id := range []string{"started", "reissued", "resumed"} {
+		holds.Hold(ctx, id)
+	}
+	s, server := newTestScheduler(t, testCards(), Config{Holds: holds})
+	results, err := s.RunOnce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"PATCH /v1/key-cards/renew",
+		"PATCH /v1/key-cards/renew-capped",
+		"POST /v1/key-cards/expired/suspend",
+		"POST /v1/key-cards/future/suspend",
+		"POST /v1/key-cards/renew-ended/suspend",
+		"POST /v1/key-cards/started/resume",
+	}
+	if got := server.changes(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(results) != len(want) {
+		t.Errorf("got %d results, want %d", len(results), len(want))
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.CardID, r.Err)
+		}
+	}
+
+	if got := server.updates["renew"].ExpirationDate; got == nil || !got.Equal(now.Add(13*24*time.Hour)) {
+		t.Errorf("renewed expiration = %v", got)
+	}
+	capped := time.Date(2026, 6, 20, 23, 59, 59, 0, time.UTC)
+	if got := server.updates["renew-capped"].ExpirationDate; got == nil || !got.Equal(capped) {
+		t.Errorf("capped expiration = %v, want %v", got, capped)
+	}
+	if got, _ := holds.Holds(ctx); len(got) != 1 || !got["future"] {
+		t.Errorf("holds = %v, want only future", got)
+	}
+}
+
+func TestRunOnceResumesOnlyHeldCards(t *testing.T) {
+	ctx := context.Background()
+	day := 24 * time.Hour
+	card := models.Card{ID: "contractor", State: "active", StartDate: now.Add(day), ExpirationDate: now.Add(90 * day), Metadata: map[string]interface{}{
+		MetadataActivateOnStart: true,
+	}}
+	clock := now
+	holds := &FileHolds{Path: filepath.Join(t.TempDir(), "holds.json")}
+	s, server := newTestScheduler(t, []models.Card{card}, Config{Holds: holds, Now: func() time.Time { return clock }})
+	scan := func(state string) []string {
+		t.Helper()
+		server.mu.Lock()
+		server.cards[0].State, server.requests = state, nil
+		server.mu.Unlock()
+		if _, err := s.RunOnce(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return server.changes()
+	}
+
+	// Suspended and held until the start date, then resumed
+	if got := scan("active"); len(got) != 1 || got[0] != "POST /v1/key-cards/contractor/suspend" {
+		t.Fatalf("before start: changes = %v", got)
+	}
+	clock = now.Add(2 * day)
+	if got := scan("suspended"); len(got) != 1 || got[0] != "POST /v1/key-cards/contractor/resume" {
+		t.Fatalf("after start: changes = %v", got)
+	}
+
+	// Suspended again by someone else, for example for a lost phone
+	if got := scan("suspended"); len(got) != 0 {
+		t.Errorf("suspended by someone else: changes = %v", got)
+	}
+	if got, _ := holds.Holds(ctx); len(got) != 0 {
+		t.Errorf("holds = %v, want none", got)
+	}
+}
+
+func TestRunOnceDeletesExpired(t *testing.T) {
+	s, server := newTestScheduler(t, testCards()[:3], Config{ExpiredAction: ActionDelete})
+	if _, err := s.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"POST /v1/key-cards/expired-suspended/delete", "POST /v1/key-cards/expired/delete"}
+	if got := server.changes(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+}
+
+func TestRunOnlyLeaderActs(t *testing.T) {
+	lock := &MemoryLock{}
+	cards := testCards()[:1]
+	a, serverA := newTestScheduler(t, cards, Config{Locker: lock, Owner: "a", Interval: 10 * time.Millisecond})
+	b, serverB := newTestScheduler(t, cards, Config{Locker: lock, Owner: "b", Interval: 10 * time.Millisecond})
+
+	// a takes the lock first
+	if held, _ := lock.TryLock(context.Background(), "a", time.Minute); !held {
+		t.Fatal("a could not take the lock")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, s := range []*Scheduler{a, b} {
+		wg.Add(1)
+		go func(s *Scheduler) {
+			defer wg.Done()
+			s.Run(ctx)
+		}(s)
+	}
+	wg.Wait()
+
+	if len(serverA.changes()) == 0 {
+		t.Error("the leader made no changes")
+	}
+	if len(serverB.changes()) != 0 {
+		t.Errorf("the follower made changes: %v", serverB.changes())
+	}
+	if held, _ := lock.TryLock(context.Background(), "b", time.Minute); !held {
+		t.Error("the lock was not released when the leader stopped")
+	}
+}
+
+func TestMemoryLockExpires(t *testing.T) {
+	clock := now
+	lock := &MemoryLock{now: func() time.Time { return clock }}
+	ctx := context.Background()
+
+	if held, _ := lock.TryLock(ctx, "a", time.Minute); !held {
+		t.Fatal("a should take a free lock")
+	}
+	if held, _ := lock.TryLock(ctx, "b", time.Minute); held {
+		t.Fatal("b should not take a held lock")
+	}
+	clock = clock.Add(2 * time.Minute)
+	if held, _ := lock.TryLock(ctx, "b", time.Minute); !held {
+		t.Fatal("b should take an expired lock")
+	}
+}
+
+func TestFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+	a, b := &FileLock{Path: path}, &FileLock{Path: path}
+	ctx := context.Background()
+
+	if held, err := a.TryLock(ctx, "a", time.Minute); err != nil || !held {
+		t.Fatalf("a: held = %v, err = %v", held, err)
+	}
+	if held, err := b.TryLock(ctx, "b", time.Minute); err != nil || held {
+		t.Fatalf("b: held = %v, err = %v", held, err)
+	}
+	if held, _ := a.TryLock(ctx, "a", time.Minute); !held {
+		t.Fatal("a should renew its own lease")
+	}
+	if err := b.Unlock(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if held, _ := b.TryLock(ctx, "b", time.Minute); held {
+		t.Fatal("unlocking as another owner released the lease")
+	}
+	if err := a.Unlock(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if held, _ := b.TryLock(ctx, "b", time.Minute); !held {
+		t.Fatal("b should take a released lease")
+	}
+}
+
+func TestFileLockExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	holders := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+			held, err := (&FileLock{Path: path}).TryLock(ctx, owner, time.Minute)
+			if err != nil {
+				t.Error(err)
+			}
+			if held {
+				mu.Lock()
+				holders++
+				mu.Unlock()
+			}
+		}(fmt.Sprint("owner-", i))
+	}
+	wg.Wait()
+	if holders != 1 {
+		t.Errorf("%d schedulers hold the lock, want 1", holders)
+	}
+}
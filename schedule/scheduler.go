@@ -0,0 +1,365 @@
+// Package schedule activates, expires and renews access passes on time.
+//
+// A Scheduler scans cards on an interval. Passes past their expiration are
+// suspended or deleted, passes flagged for renewal are extended before they
+// lapse, and passes flagged to activate on their start date are kept
+// suspended until then. Only the scheduler holding the Locker acts, so
+// several replicas can run side by side. A HoldStore records the passes
+// the scheduler suspended to wait for their start date; it never resumes
+// a pass that someone else suspended.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// Metadata keys read from cards
+const (
+	// MetadataActivateOnStart keeps a pass suspended until its StartDate
+	MetadataActivateOnStart = "activate_on_start"
+	// MetadataAutoRenew extends a pass's expiration before it lapses
+	MetadataAutoRenew = "auto_renew"
+	// MetadataRenewDays overrides Config.RenewPeriod, in days
+	MetadataRenewDays = "renew_days"
+	// MetadataRenewUntil is a date after which a pass is no longer renewed
+	MetadataRenewUntil = "renew_until"
+)
+
+// Action is a change the scheduler makes to a card
+type Action string
+
+const (
+	ActionSuspend Action = "suspend"
+	ActionDelete  Action = "delete"
+	ActionResume  Action = "resume"
+	ActionExtend  Action = "extend"
+)
+
+// Result records one change
+type Result struct {
+	CardID     string
+	EmployeeID string
+	Action     Action
+	Reason     string
+	// Expiration is the new expiration of an extended pass
+	Expiration time.Time
+	Err        error
+
+	// untilStart is set when a pass is suspended until its start date
+	untilStart bool
+}
+
+// Config configures a Scheduler
+type Config struct {
+	// TemplateIDs limits the scan to these templates; empty scans every
+	// card
+	TemplateIDs []string
+	// ExpiredAction is ActionSuspend (the default) or ActionDelete
+	ExpiredAction Action
+	// RenewWindow is how long before expiration flagged passes are
+	// renewed. Defaults to 7 days.
+	RenewWindow time.Duration
+	// RenewPeriod is how far a renewal extends a pass. Defaults to 30 days.
+	RenewPeriod time.Duration
+	// Interval between scans. Defaults to 5 minutes.
+	Interval time.Duration
+	// Locker elects the scheduler that acts. Defaults to a MemoryLock,
+	// which suits a single process.
+	Locker Locker
+	// Owner identifies this scheduler to the Locker. Defaults to the host
+	// name and process ID.
+	Owner string
+	// LockTTL is how long a lock is held without renewal. Defaults to
+	// three intervals.
+	LockTTL time.Duration
+	// Holds records the passes suspended until their start date. Defaults
+	// to a MemoryHolds, which forgets them when the process exits.
+	Holds HoldStore
+	// OnResult receives every change, including failed ones
+	OnResult func(Result)
+	// OnError receives scan and lock errors from Run
+	OnError func(error)
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Scheduler applies time-based changes to cards
+type Scheduler struct {
+	cards  *services.AccessCardsService
+	config Config
+}
+
+// New creates a scheduler
+func New(cards *services.AccessCardsService, config Config) (*Scheduler, error) {
+	switch config.ExpiredAction {
+	case "":
+		config.ExpiredAction = ActionSuspend
+	case ActionSuspend, ActionDelete:
+	default:
+		return nil, fmt.Errorf("ExpiredAction must be %s or %s, got %q", ActionSuspend, ActionDelete, config.ExpiredAction)
+	}
+	if config.RenewWindow <= 0 {
+		config.RenewWindow = 7 * 24 * time.Hour
+	}
+	if config.RenewPeriod <= 0 {
+		config.RenewPeriod = 30 * 24 * time.Hour
+	}
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Minute
+	}
+	if config.Locker == nil {
+		config.Locker = &MemoryLock{}
+	}
+	if config.Owner == "" {
+		host, _ := os.Hostname()
+		config.Owner = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if config.LockTTL <= 0 {
+		config.LockTTL = 3 * config.Interval
+	}
+	if config.Holds == nil {
+		config.Holds = &MemoryHolds{}
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Scheduler{cards: cards, config: config}, nil
+}
+
+// Run scans on every interval while this scheduler holds the lock, until
+// ctx is done. The lock is released on return.
+func (s *Scheduler) Run(ctx context.Context) error {
+	defer s.config.Locker.Unlock(context.WithoutCancel(ctx), s.config.Owner)
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		held, err := s.config.Locker.TryLock(ctx, s.config.Owner, s.config.LockTTL)
+		if err != nil {
+			s.reportError(fmt.Errorf("error acquiring lock: %w", err))
+		} else if held {
+			if _, err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+				s.reportError(err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) reportError(err error) {
+	if s.config.OnError != nil {
+		s.config.OnError(err)
+	}
+}
+
+// RunOnce scans the cards once and applies the changes due, without
+// taking the lock. Failed changes are returned in their results; the error
+// reports a failed scan.
+func (s *Scheduler) RunOnce(ctx context.Context) ([]Result, error) {
+	var cards []models.Card
+	if len(s.config.TemplateIDs) == 0 {
+		all, err := s.cards.List(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		cards = all
+	}
+	for _, templateID := range s.config.TemplateIDs {
+		listed, err := s.cards.List(ctx, &models.ListKeysParams{TemplateID: templateID})
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, listed...)
+	}
+
+	holds, err := s.config.Holds.Holds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// A reissued pass keeps its flags, and the card it replaced must stay
+	// suspended
+	replaced := map[string]bool{}
+	for _, card := range cards {
+		if id, ok := card.Metadata[services.MetadataReissuedFrom].(string); ok && id != "" {
+			replaced[id] = true
+		}
+	}
+
+	now := s.config.Now().UTC()
+	var results []Result
+	for _, card := range cards {
+		held := holds[card.ID] && !replaced[card.ID]
+		if holds[card.ID] && (card.State != "suspended" || replaced[card.ID]) {
+			// Resumed, deleted or replaced since it was held
+			if err := s.config.Holds.Release(ctx, card.ID); err != nil {
+				return results, err
+			}
+		}
+
+		result, due := s.plan(card, now, held)
+		if !due {
+			continue
+		}
+		result.Err = s.apply(ctx, result)
+		if s.config.OnResult != nil {
+			s.config.OnResult(result)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// plan decides what, if anything, is due for a card. held reports whether
+// the scheduler suspended the card until its start date.
+func (s *Scheduler) plan(card models.Card, now time.Time, held bool) (Result, bool) {
+	result := Result{CardID: card.ID, EmployeeID: card.EmployeeID}
+	if card.State == "deleted" {
+		return result, false
+	}
+	suspended := card.State == "suspended"
+
+	expiration := card.ExpirationDate
+	if !expiration.IsZero() && metadataBool(card.Metadata, MetadataAutoRenew) && expiration.Sub(now) <= s.config.RenewWindow {
+		if extended, ok := s.renewal(card, now); ok {
+			result.Action, result.Expiration = ActionExtend, extended
+			result.Reason = fmt.Sprintf("renewed before expiring %s", expiration.Format(time.RFC3339))
+			return result, true
+		}
+	}
+
+	if !expiration.IsZero() && !now.Before(expiration) {
+		if s.config.ExpiredAction == ActionSuspend && suspended {
+			return result, false
+		}
+		result.Action = s.config.ExpiredAction
+		result.Reason = fmt.Sprintf("expired %s", expiration.Format(time.RFC3339))
+		return result, true
+	}
+
+	if metadataBool(card.Metadata, MetadataActivateOnStart) && !card.StartDate.IsZero() {
+		switch {
+		case now.Before(card.StartDate) && !suspended:
+			result.Action, result.untilStart = ActionSuspend, true
+			result.Reason = fmt.Sprintf("starts %s", card.StartDate.Format(time.RFC3339))
+			return result, true
+		case !now.Before(card.StartDate) && suspended && held:
+			result.Action = ActionResume
+			result.Reason = fmt.Sprintf("started %s", card.StartDate.Format(time.RFC3339))
+			return result, true
+		}
+	}
+	return result, false
+}
+
+// renewal returns the extended expiration of a flagged card, or false
+// when its renewals have run out
+func (s *Scheduler) renewal(card models.Card, now time.Time) (time.Time, bool) {
+	period := s.config.RenewPeriod
+	if days, ok := metadataInt(card.Metadata, MetadataRenewDays); ok && days > 0 {
+		period = time.Duration(days) * 24 * time.Hour
+	}
+
+	from := card.ExpirationDate
+	if from.Before(now) {
+		from = now
+	}
+	extended := from.Add(period)
+
+	if until, ok := metadataTime(card.Metadata, MetadataRenewUntil); ok {
+		if !until.After(card.ExpirationDate) {
+			return time.Time{}, false
+		}
+		if extended.After(until) {
+			extended = until
+		}
+	}
+	return extended, true
+}
+
+func (s *Scheduler) apply(ctx context.Context, result Result) error {
+	switch result.Action {
+	case ActionSuspend:
+		if err := s.cards.Suspend(ctx, result.CardID); err != nil || !result.untilStart {
+			return err
+		}
+		if err := s.config.Holds.Hold(ctx, result.CardID); err != nil {
+			return fmt.Errorf("card %s was suspended but not held, resume it at its start date: %w", result.CardID, err)
+		}
+		return nil
+	case ActionResume:
+		// Released first, so a pass that fails to resume is left for a
+		// person to resume rather than held through someone else's
+		// suspension
+		if err := s.config.Holds.Release(ctx, result.CardID); err != nil {
+			return err
+		}
+		return s.cards.Resume(ctx, result.CardID)
+	case ActionDelete:
+		return s.cards.Delete(ctx, result.CardID)
+	case ActionExtend:
+		expiration := result.Expiration.UTC()
+		_, err := s.cards.Update(ctx, models.UpdateParams{CardID: result.CardID, ExpirationDate: &expiration})
+		return err
+	default:
+		return errors.New("unknown action")
+	}
+}
+
+// metadataBool reads a flag set as a boolean or a string such as "true"
+func metadataBool(metadata map[string]interface{}, key string) bool {
+	switch v := metadata[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(strings.TrimSpace(v))
+		return b
+	case float64:
+		return v != 0
+	default:
+		return false
+	}
+}
+
+func metadataInt(metadata map[string]interface{}, key string) (int, bool) {
+	switch v := metadata[key].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// metadataTime reads an RFC 3339 time or a date, which means the end of
+// that day in UTC
+func metadataTime(metadata map[string]interface{}, key string) (time.Time, bool) {
+	s, ok := metadata[key].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.Add(24*time.Hour - time.Second), true
+	}
+	return time.Time{}, false
+}
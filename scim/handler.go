@@ -0,0 +1,571 @@
+package scim
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/budget"
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// maxBodySize bounds request bodies
+const maxBodySize = 1 << 20
+
+// Error is a SCIM error response
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("scim: %d %s: %s", e.Status, e.ScimType, e.Detail)
+}
+
+// MarshalJSON encodes the error as a SCIM error message
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		ScimType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail,omitempty"`
+	}{[]string{SchemaError}, strconv.Itoa(e.Status), e.ScimType, e.Detail})
+}
+
+func invalidValue(format string, args ...interface{}) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: "invalidValue", Detail: fmt.Sprintf(format, args...)}
+}
+
+func invalidPath(path string) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: "invalidPath", Detail: fmt.Sprintf("unsupported path %q", path)}
+}
+
+func notFound(id string) *Error {
+	return &Error{Status: http.StatusNotFound, Detail: fmt.Sprintf("user %s not found", id)}
+}
+
+// cardError reports a failed card request
+func cardError(err error) *Error {
+	if errors.Is(err, budget.ErrBudgetExceeded) {
+		return &Error{Status: http.StatusForbidden, Detail: err.Error()}
+	}
+	return &Error{Status: http.StatusBadGateway, Detail: err.Error()}
+}
+
+// Config configures a Handler
+type Config struct {
+	// CardTemplateID is the template passes are provisioned with
+	CardTemplateID string
+	// BearerToken, if set, must be sent by clients in the Authorization
+	// header
+	BearerToken string
+	// BaseURL is the public URL the handler is served at, used in
+	// resource locations. Defaults to the request's scheme and host.
+	BaseURL string
+	// DefaultValidity sets the expiration of new passes. Defaults to one
+	// year.
+	DefaultValidity time.Duration
+	// Provisioner, if set, provisions passes instead of the card service,
+	// for example a budget.Guard
+	Provisioner budget.Provisioner
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Handler serves the SCIM 2.0 Users endpoint. Mount it at the SCIM base
+// path with http.StripPrefix, so that it sees paths such as /Users.
+type Handler struct {
+	cards  *services.AccessCardsService
+	store  Store
+	config Config
+	mux    *http.ServeMux
+
+	// mu guards users and names. It is never held across card requests.
+	mu sync.Mutex
+	// users holds a lock per user ID, so a pass is never changed by two
+	// requests at once
+	users map[string]*userLock
+	// names holds the user names claimed by requests in flight, by lower
+	// case name, so two requests cannot take the same name
+	names map[string]string
+}
+
+// userLock serializes the requests for one user
+type userLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// NewHandler creates a SCIM handler
+func NewHandler(cards *services.AccessCardsService, store Store, config Config) (*Handler, error) {
+	if config.CardTemplateID == "" {
+		return nil, errors.New("CardTemplateID is required")
+	}
+	if store == nil {
+		return nil, errors.New("a store is required")
+	}
+	if config.DefaultValidity <= 0 {
+		config.DefaultValidity = 365 * 24 * time.Hour
+	}
+	if config.Provisioner == nil {
+		config.Provisioner = cards
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	h := &Handler{
+		cards:  cards,
+		store:  store,
+		config: config,
+		mux:    http.NewServeMux(),
+		users:  map[string]*userLock{},
+		names:  map[string]string{},
+	}
+	h.mux.HandleFunc("GET /ServiceProviderConfig", h.serviceProviderConfig)
+	h.mux.HandleFunc("GET /Users", h.list)
+	h.mux.HandleFunc("POST /Users", h.create)
+	h.mux.HandleFunc("GET /Users/{id}", h.get)
+	h.mux.HandleFunc("PUT /Users/{id}", h.replace)
+	h.mux.HandleFunc("PATCH /Users/{id}", h.patch)
+	h.mux.HandleFunc("DELETE /Users/{id}", h.delete)
+	return h, nil
+}
+
+// ServeHTTP authenticates the request and routes it
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.config.BearerToken != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.config.BearerToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			writeError(w, &Error{Status: http.StatusUnauthorized, Detail: "invalid bearer token"})
+			return
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	h.mux.ServeHTTP(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var scimErr *Error
+	if !errors.As(err, &scimErr) {
+		scimErr = &Error{Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	writeJSON(w, scimErr.Status, scimErr)
+}
+
+func decode(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return &Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: fmt.Sprintf("invalid request body: %v", err)}
+	}
+	return nil
+}
+
+// location returns the URL of a user
+func (h *Handler) location(r *http.Request, id string) string {
+	base := h.config.BaseURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return strings.TrimRight(base, "/") + "/Users/" + id
+}
+
+// resource returns a stored user as it is sent to clients
+func (h *Handler) resource(r *http.Request, record *Record) User {
+	user := record.User
+	if user.Meta != nil {
+		meta := *user.Meta
+		meta.Location = h.location(r, user.ID)
+		user.Meta = &meta
+	}
+	return user
+}
+
+// newID returns a random version 4 UUID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func schemasFor(user *User) []string {
+	if user.Enterprise != nil {
+		return []string{SchemaUser, SchemaEnterpriseUser}
+	}
+	return []string{SchemaUser}
+}
+
+// lockUser locks the user with the given ID and returns the function that
+// unlocks it
+func (h *Handler) lockUser(id string) (unlock func()) {
+	h.mu.Lock()
+	l := h.users[id]
+	if l == nil {
+		l = &userLock{}
+		h.users[id] = l
+	}
+	l.refs++
+	h.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		h.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(h.users, id)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// claimUserName validates a user name and claims it for the user with the
+// given ID until release is called. It returns an error if another user
+// has the name or a request in flight claimed it.
+func (h *Handler) claimUserName(ctx context.Context, user *User, id string) (release func(), err error) {
+	if strings.TrimSpace(user.UserName) == "" {
+		return nil, invalidValue("userName is required")
+	}
+	taken := &Error{Status: http.StatusConflict, ScimType: "uniqueness", Detail: fmt.Sprintf("userName %q is taken", user.UserName)}
+	name := strings.ToLower(user.UserName)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if claimant, ok := h.names[name]; ok && claimant != id {
+		return nil, taken
+	}
+	records, err := h.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.User.ID != id && strings.EqualFold(record.User.UserName, user.UserName) {
+			return nil, taken
+		}
+	}
+
+	h.names[name] = id
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.names[name] == id {
+			delete(h.names, name)
+		}
+	}, nil
+}
+
+func (h *Handler) serviceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	supported := func(ok bool) map[string]bool { return map[string]bool{"supported": ok} }
+	config := map[string]interface{}{
+		"schemas":        []string{SchemaServiceConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": 1000},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]string{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Authentication with a static bearer token",
+		}},
+	}
+	writeJSON(w, http.StatusOK, config)
+}
+
+// list serves GET /Users, filtered by userName, externalId or id
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var match func(*User) bool
+	if expr := query.Get("filter"); expr != "" {
+		f, err := parseFilter(expr)
+		if err != nil {
+			writeError(w, &Error{Status: http.StatusBadRequest, ScimType: "invalidFilter", Detail: err.Error()})
+			return
+		}
+		switch strings.ToLower(f.attr) {
+		case "username":
+			match = func(u *User) bool { return strings.EqualFold(u.UserName, f.value) }
+		case "externalid":
+			match = func(u *User) bool { return u.ExternalID == f.value }
+		case "id":
+			match = func(u *User) bool { return u.ID == f.value }
+		default:
+			writeError(w, &Error{Status: http.StatusBadRequest, ScimType: "invalidFilter", Detail: fmt.Sprintf("filtering on %s is not supported", f.attr)})
+			return
+		}
+	}
+
+	startIndex, count := 1, 1000
+	if s := query.Get("startIndex"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 1 {
+			startIndex = n
+		}
+	}
+	if s := query.Get("count"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 && n < count {
+			count = n
+		}
+	}
+
+	records, err := h.store.List(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var matched []User
+	for _, record := range records {
+		if match == nil || match(&record.User) {
+			matched = append(matched, h.resource(r, record))
+		}
+	}
+
+	page := []User{}
+	if start := startIndex - 1; start < len(matched) {
+		page = matched[start:min(start+count, len(matched))]
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"schemas":      []string{SchemaListResponse},
+		"totalResults": len(matched),
+		"startIndex":   startIndex,
+		"itemsPerPage": len(page),
+		"Resources":    page,
+	})
+}
+
+// create serves POST /Users by provisioning a pass
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	var user User
+	if err := decode(r, &user); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ctx := r.Context()
+	release, err := h.claimUserName(ctx, &user, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer release()
+	now := h.config.Now().UTC()
+	user.ID = id
+	user.Schemas = schemasFor(&user)
+	user.Meta = &Meta{ResourceType: "User", Created: now, LastModified: now}
+
+	card, err := h.config.Provisioner.Provision(ctx, provisionParams(&user, h.config.CardTemplateID, now, now.Add(h.config.DefaultValidity)))
+	if err != nil {
+		writeError(w, cardError(err))
+		return
+	}
+	// Undo the provision if the user cannot be fully created, so a retry
+	// does not leave a stray pass behind
+	undo := func() { h.cards.Delete(context.WithoutCancel(ctx), card.ID) }
+
+	if !user.IsActive() {
+		if err := h.cards.Suspend(ctx, card.ID); err != nil {
+			undo()
+			writeError(w, cardError(err))
+			return
+		}
+	}
+
+	record := &Record{CardID: card.ID, User: user}
+	if err := h.store.Put(ctx, record); err != nil {
+		undo()
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", h.location(r, id))
+	writeJSON(w, http.StatusCreated, h.resource(r, record))
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	record, err := h.store.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, notFound(r.PathValue("id")))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.resource(r, record))
+}
+
+// lookup returns the record of the request's user. The caller must hold
+// the user's lock.
+func (h *Handler) lookup(r *http.Request) (*Record, error) {
+	id := r.PathValue("id")
+	record, err := h.store.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, notFound(id)
+	}
+	return record, err
+}
+
+// replace serves PUT /Users/{id}
+func (h *Handler) replace(w http.ResponseWriter, r *http.Request) {
+	var user User
+	if err := decode(r, &user); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	defer h.lockUser(r.PathValue("id"))()
+	record, err := h.lookup(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.save(r.Context(), record, &user); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.resource(r, record))
+}
+
+// patch serves PATCH /Users/{id}
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request) {
+	var request PatchRequest
+	if err := decode(r, &request); err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(request.Operations) == 0 {
+		writeError(w, invalidValue("no patch operations"))
+		return
+	}
+
+	defer h.lockUser(r.PathValue("id"))()
+	record, err := h.lookup(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	user, err := applyPatch(&record.User, request.Operations)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.save(r.Context(), record, user); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.resource(r, record))
+}
+
+// save makes the pass match a new version of the user and stores it. On
+// success record holds the new version. If a step fails, the pass changes
+// already made are undone, as far as an update can undo them, so the pass
+// keeps matching the stored user. The caller must hold the user's lock.
+func (h *Handler) save(ctx context.Context, record *Record, user *User) error {
+	old := record.User
+	release, err := h.claimUserName(ctx, user, old.ID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	user.ID = old.ID
+	user.Schemas = schemasFor(user)
+	now := h.config.Now().UTC()
+	user.Meta = &Meta{ResourceType: "User", Created: now, LastModified: now}
+	if old.Meta != nil {
+		user.Meta.Created = old.Meta.Created
+	}
+
+	var undo []func()
+	rollback := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+	undoCtx := context.WithoutCancel(ctx)
+
+	if params, changed := updateParams(record.CardID, &old, user); changed {
+		if _, err := h.cards.Update(ctx, params); err != nil {
+			return cardError(err)
+		}
+		undo = append(undo, func() {
+			if params, changed := updateParams(record.CardID, user, &old); changed {
+				h.cards.Update(undoCtx, params)
+			}
+		})
+	}
+	if old.IsActive() != user.IsActive() {
+		setActive := func(ctx context.Context, active bool) error {
+			if active {
+				return h.cards.Resume(ctx, record.CardID)
+			}
+			return h.cards.Suspend(ctx, record.CardID)
+		}
+		if err := setActive(ctx, user.IsActive()); err != nil {
+			rollback()
+			return cardError(err)
+		}
+		undo = append(undo, func() { setActive(undoCtx, old.IsActive()) })
+	}
+
+	updated := &Record{CardID: record.CardID, User: *user}
+	if err := h.store.Put(ctx, updated); err != nil {
+		rollback()
+		return err
+	}
+	*record = *updated
+	return nil
+}
+
+// delete serves DELETE /Users/{id} by deleting the pass
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	defer h.lockUser(r.PathValue("id"))()
+	record, err := h.lookup(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.cards.Delete(ctx, record.CardID); err != nil {
+		// A pass that is already gone need not block the user's removal
+		var apiErr *client.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+			writeError(w, cardError(err))
+			return
+		}
+	}
+	if err := h.store.Delete(ctx, record.User.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,307 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PatchRequest is the body of a PATCH request
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is one operation of a PATCH request
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// attributeNames maps attribute names, which SCIM compares without case,
+// to the names used in User's JSON
+var attributeNames = map[string]string{}
+
+func init() {
+	for _, name := range []string{
+		"userName", "externalId", "name", "displayName", "title", "active", "emails", "phoneNumbers",
+		"formatted", "givenName", "familyName", "value", "type", "primary",
+		"employeeNumber", "department", SchemaEnterpriseUser,
+	} {
+		attributeNames[strings.ToLower(name)] = name
+	}
+}
+
+// canonicalKey returns the key of an attribute in a JSON object: an
+// existing key that matches without case, else the known spelling
+func canonicalKey(object map[string]interface{}, name string) string {
+	for key := range object {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	if known, ok := attributeNames[strings.ToLower(name)]; ok {
+		return known
+	}
+	return name
+}
+
+// filter is an equality filter, the only kind supported
+type filter struct {
+	attr  string
+	value string
+}
+
+// parseFilter parses `attr eq "value"`
+func parseFilter(expr string) (filter, error) {
+	attr, rest, ok := strings.Cut(strings.TrimSpace(expr), " ")
+	if !ok {
+		return filter{}, fmt.Errorf("unsupported filter %q", expr)
+	}
+	op, value, ok := strings.Cut(strings.TrimSpace(rest), " ")
+	if !ok || !strings.EqualFold(op, "eq") {
+		return filter{}, fmt.Errorf("unsupported filter %q: only eq is supported", expr)
+	}
+
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, `"`) {
+		if err := json.Unmarshal([]byte(value), &value); err != nil {
+			return filter{}, fmt.Errorf("invalid filter value in %q", expr)
+		}
+	}
+	return filter{attr: attr, value: value}, nil
+}
+
+// matches reports whether an element of a multi-valued attribute matches
+func (f filter) matches(element interface{}) bool {
+	object, ok := element.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	value, ok := object[canonicalKey(object, f.attr)]
+	return ok && strings.EqualFold(fmt.Sprint(value), f.value)
+}
+
+// applyPatch returns the user with the operations applied
+func applyPatch(user *User, operations []PatchOperation) (*User, error) {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	for _, operation := range operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			return nil, invalidValue("unsupported patch op %q", operation.Op)
+		}
+
+		var value interface{}
+		if len(operation.Value) > 0 {
+			if err := json.Unmarshal(operation.Value, &value); err != nil {
+				return nil, &Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: "invalid patch value"}
+			}
+		}
+
+		if operation.Path != "" {
+			if err := applyPath(doc, op, operation.Path, value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if op == "remove" {
+			return nil, &Error{Status: http.StatusBadRequest, ScimType: "noTarget", Detail: "remove requires a path"}
+		}
+		attributes, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, invalidValue("a patch without a path needs an object value")
+		}
+		for path, v := range attributes {
+			if err := applyPath(doc, op, path, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Some identity providers send active as a string
+	if s, ok := doc["active"].(string); ok {
+		active, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, invalidValue("invalid active value %q", s)
+		}
+		doc["active"] = active
+	}
+
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var patched User
+	if err := json.Unmarshal(data, &patched); err != nil {
+		return nil, invalidValue("patched user is invalid: %v", err)
+	}
+	return &patched, nil
+}
+
+// applyPath applies one operation to the attribute at path, which is
+// `attr`, `attr.sub` or `attr[filter].sub`, optionally prefixed with a
+// schema URN
+func applyPath(doc map[string]interface{}, op, path string, value interface{}) error {
+	container := doc
+	if rest, ok := cutPrefixFold(path, SchemaUser+":"); ok {
+		path = rest
+	}
+	if rest, ok := cutPrefixFold(path, SchemaEnterpriseUser+":"); ok {
+		key := canonicalKey(doc, SchemaEnterpriseUser)
+		extension, _ := doc[key].(map[string]interface{})
+		if extension == nil {
+			if op == "remove" {
+				return nil
+			}
+			extension = map[string]interface{}{}
+			doc[key] = extension
+		}
+		container, path = extension, rest
+	}
+
+	var attr, filterExpr, sub string
+	switch {
+	case strings.EqualFold(path, SchemaEnterpriseUser):
+		attr = path
+	case strings.Contains(path, "["):
+		open, end := strings.Index(path, "["), strings.Index(path, "]")
+		if end < open {
+			return invalidPath(path)
+		}
+		attr, filterExpr = path[:open], path[open+1:end]
+		if rest := path[end+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ".") {
+				return invalidPath(path)
+			}
+			sub = rest[1:]
+		}
+	default:
+		attr, sub, _ = strings.Cut(path, ".")
+	}
+	if attr == "" {
+		return invalidPath(path)
+	}
+	key := canonicalKey(container, attr)
+
+	if filterExpr != "" {
+		f, err := parseFilter(filterExpr)
+		if err != nil {
+			return &Error{Status: http.StatusBadRequest, ScimType: "invalidFilter", Detail: err.Error()}
+		}
+		return applyFiltered(container, key, op, f, sub, value)
+	}
+
+	if sub != "" {
+		child, ok := container[key].(map[string]interface{})
+		if !ok {
+			if container[key] != nil {
+				return invalidPath(path)
+			}
+			if op == "remove" {
+				return nil
+			}
+			child = map[string]interface{}{}
+			container[key] = child
+		}
+		subKey := canonicalKey(child, sub)
+		if op == "remove" {
+			delete(child, subKey)
+		} else {
+			child[subKey] = value
+		}
+		return nil
+	}
+
+	switch {
+	case op == "remove":
+		delete(container, key)
+	case isObject(value) && isObject(container[key]):
+		// Sub-attributes missing from the value are left unchanged
+		merge(container[key].(map[string]interface{}), value.(map[string]interface{}))
+	case op == "add" && isArray(value) && isArray(container[key]):
+		container[key] = append(container[key].([]interface{}), value.([]interface{})...)
+	default:
+		container[key] = value
+	}
+	return nil
+}
+
+// applyFiltered applies an operation to the elements of a multi-valued
+// attribute that match a filter. add and replace create an element when
+// none matches.
+func applyFiltered(container map[string]interface{}, key, op string, f filter, sub string, value interface{}) error {
+	elements, _ := container[key].([]interface{})
+	var kept []interface{}
+	matched := false
+	for _, element := range elements {
+		if !f.matches(element) {
+			kept = append(kept, element)
+			continue
+		}
+		matched = true
+		object := element.(map[string]interface{})
+		switch {
+		case op == "remove" && sub == "":
+			continue
+		case op == "remove":
+			delete(object, canonicalKey(object, sub))
+		case sub != "":
+			object[canonicalKey(object, sub)] = value
+		case isObject(value):
+			merge(object, value.(map[string]interface{}))
+		default:
+			return invalidValue("%s elements must be objects", key)
+		}
+		kept = append(kept, object)
+	}
+
+	if !matched && op != "remove" {
+		object := map[string]interface{}{canonicalKey(nil, f.attr): f.value}
+		switch {
+		case sub != "":
+			object[canonicalKey(nil, sub)] = value
+		case isObject(value):
+			merge(object, value.(map[string]interface{}))
+		default:
+			return invalidValue("%s elements must be objects", key)
+		}
+		kept = append(kept, object)
+	}
+	container[key] = kept
+	return nil
+}
+
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		dst[canonicalKey(dst, k)] = v
+	}
+}
+
+func isObject(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
+
+func isArray(v interface{}) bool {
+	_, ok := v.([]interface{})
+	return ok
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
@@ -0,0 +1,439 @@
+package scim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// cardServer records the card requests made by the handler
+type cardServer struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   []map[string]interface{}
+	next     int
+	// fail lists requests, as "METHOD path", that return an error
+	fail map[string]bool
+	// hold lists requests that wait until their channel is closed
+	hold map[string]chan struct{}
+}
+
+func (s *cardServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request := r.Method + " " + r.URL.Path
+	s.mu.Lock()
+	wait := s.hold[request]
+	s.mu.Unlock()
+	if wait != nil {
+		<-wait
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	s.requests = append(s.requests, request)
+	s.bodies = append(s.bodies, body)
+
+	if s.fail[request] {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"message": "card service unavailable"}`))
+		return
+	}
+
+	if r.Method == http.MethodPost && r.URL.Path == "/v1/key-cards" {
+		s.next++
+		json.NewEncoder(w).Encode(models.CardProvisionResponse{ID: fmt.Sprintf("card_%d", s.next)})
+		return
+	}
+	w.Write([]byte(`{}`))
+}
+
+func (s *cardServer) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests, s.bodies = nil, nil
+}
+
+// scimClient is a minimal SCIM client for the tests
+type scimClient struct {
+	t     *testing.T
+	url   string
+	token string
+}
+
+func (c *scimClient) do(method, path string, body interface{}, out interface{}) int {
+	c.t.Helper()
+	var reader *bytes.Reader
+	if s, ok := body.(string); ok {
+		reader = bytes.NewReader([]byte(s))
+	} else {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.url+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/scim+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			c.t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// clock returns a clock that starts at 2026-03-01 12:00 UTC and advances
+// a second per call, so users sort by creation
+func clock() func() time.Time {
+	var mu sync.Mutex
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(time.Second)
+		return now.Add(-time.Second)
+	}
+}
+
+func newTestHandler(t *testing.T, store Store) (*scimClient, *cardServer) {
+	t.Helper()
+	cards := &cardServer{}
+	cardsServer := httptest.NewServer(cards)
+	t.Cleanup(cardsServer.Close)
+
+	c, err := client.NewClient("test-account", "test-secret", client.WithBaseURL(cardsServer.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := NewHandler(services.NewAccessCardsService(c), store, Config{
+		CardTemplateID: "tmpl_1",
+		BearerToken:    "secret-token",
+		Now:            clock(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/scim/v2/", http.StripPrefix("/scim/v2", handler))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return &scimClient{t: t, url: server.URL + "/scim/v2", token: "secret-token"}, cards
+}
+
+const adaJSON = `{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"],
+	"userName": "ada@example.com",
+	"externalId": "00u1",
+	"name": {"givenName": "Ada", "familyName": "Lovelace"},
+	"title": "Analyst",
+	"active": true,
+	"emails": [{"value": "ada@example.com", "type": "work", "primary": true}],
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"employeeNumber": "E1001", "department": "Engineering"}
+}`
+
+func createAda(t *testing.T, c *scimClient) User {
+	t.Helper()
+	var user User
+	if status := c.do(http.MethodPost, "/Users", adaJSON, &user); status != http.StatusCreated {
+		t.Fatalf("create status = %d", status)
+	}
+	return user
+}
+
+func TestCreateProvisionsPass(t *testing.T) {
+	c, cards := newTestHandler(t, NewMemoryStore())
+	user := createAda(t, c)
+
+	if user.ID == "" || user.Meta == nil || !strings.HasSuffix(user.Meta.Location, "/Users/"+user.ID) {
+		t.Errorf("user = %+v", user)
+	}
+	if len(cards.requests) != 1 || cards.requests[0] != "POST /v1/key-cards" {
+		t.Fatalf("card requests = %v", cards.requests)
+	}
+	body := cards.bodies[0]
+	for field, want := range map[string]string{
+		"card_template_id": "tmpl_1",
+		"employee_id":      "E1001",
+		"full_name":        "Ada Lovelace",
+		"email":            "ada@example.com",
+		"title":            "Analyst",
+		"department":       "Engineering",
+		"expiration_date":  "2027-03-01T12:00:00Z",
+	} {
+		if body[field] != want {
+			t.Errorf("%s = %v, want %q", field, body[field], want)
+		}
+	}
+
+	var got User
+	if status := c.do(http.MethodGet, "/Users/"+user.ID, nil, &got); status != http.StatusOK || got.UserName != "ada@example.com" {
+		t.Errorf("get status = %d, user = %+v", status, got)
+	}
+
+	var scimErr map[string]interface{}
+	if status := c.do(http.MethodPost, "/Users", adaJSON, &scimErr); status != http.StatusConflict || scimErr["scimType"] != "uniqueness" {
+		t.Errorf("duplicate create status = %d, body = %v", status, scimErr)
+	}
+}
+
+func TestCreateInactiveSuspends(t *testing.T) {
+	c, cards := newTestHandler(t, NewMemoryStore())
+	body := `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "grace", "active": false}`
+	if status := c.do(http.MethodPost, "/Users", body, nil); status != http.StatusCreated {
+		t.Fatalf("status = %d", status)
+	}
+	want := []string{"POST /v1/key-cards", "POST /v1/key-cards/card_1/suspend"}
+	if strings.Join(cards.requests, ",") != strings.Join(want, ",") {
+		t.Errorf("card requests = %v, want %v", cards.requests, want)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	c, _ := newTestHandler(t, NewMemoryStore())
+	ada := createAda(t, c)
+	c.do(http.MethodPost, "/Users", `{"userName": "grace@example.com", "externalId": "00u2"}`, nil)
+
+	var list struct {
+		TotalResults int    `json:"totalResults"`
+		Resources    []User `json:"Resources"`
+	}
+	c.do(http.MethodGet, `/Users?filter=userName+eq+%22ADA@example.com%22`, nil, &list)
+	if list.TotalResults != 1 || list.Resources[0].ID != ada.ID {
+		t.Errorf("userName filter = %+v", list)
+	}
+	c.do(http.MethodGet, `/Users?filter=externalId+eq+%2200u2%22`, nil, &list)
+	if list.TotalResults != 1 || list.Resources[0].UserName != "grace@example.com" {
+		t.Errorf("externalId filter = %+v", list)
+	}
+	c.do(http.MethodGet, `/Users?filter=userName+eq+%22nobody%22`, nil, &list)
+	if list.TotalResults != 0 || list.Resources == nil {
+		t.Errorf("empty filter = %+v", list)
+	}
+	c.do(http.MethodGet, `/Users?startIndex=2&count=1`, nil, &list)
+	if list.TotalResults != 2 || len(list.Resources) != 1 || list.Resources[0].UserName != "grace@example.com" {
+		t.Errorf("page = %+v", list)
+	}
+
+	var scimErr map[string]interface{}
+	if status := c.do(http.MethodGet, `/Users?filter=title+eq+%22x%22`, nil, &scimErr); status != http.StatusBadRequest || scimErr["scimType"] != "invalidFilter" {
+		t.Errorf("unsupported filter status = %d, body = %v", status, scimErr)
+	}
+}
+
+func TestPatchDeactivatesAndUpdates(t *testing.T) {
+	c, cards := newTestHandler(t, NewMemoryStore())
+	ada := createAda(t, c)
+	cards.reset()
+
+	// The style some identity providers use: string booleans and value
+	// filters in paths
+	patch := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "active", "value": "False"},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "ada@new.example.com"}
+		]
+	}`
+	var user User
+	if status := c.do(http.MethodPatch, "/Users/"+ada.ID, patch, &user); status != http.StatusOK {
+		t.Fatalf("patch status = %d", status)
+	}
+	if user.IsActive() || user.Email() != "ada@new.example.com" {
+		t.Errorf("patched user = %+v", user)
+	}
+	want := []string{"PATCH /v1/key-cards/card_1", "POST /v1/key-cards/card_1/suspend"}
+	if strings.Join(cards.requests, ",") != strings.Join(want, ",") {
+		t.Fatalf("card requests = %v, want %v", cards.requests, want)
+	}
+	if update := cards.bodies[0]; update["email"] != "ada@new.example.com" || update["full_name"] != nil {
+		t.Errorf("update = %v", update)
+	}
+
+	cards.reset()
+	c.do(http.MethodPatch, "/Users/"+ada.ID, `{"Operations": [{"op": "replace", "value": {"active": true}}]}`, nil)
+	if len(cards.requests) != 1 || cards.requests[0] != "POST /v1/key-cards/card_1/resume" {
+		t.Errorf("card requests = %v", cards.requests)
+	}
+}
+
+func TestReplaceAndDelete(t *testing.T) {
+	c, cards := newTestHandler(t, NewMemoryStore())
+	ada := createAda(t, c)
+	cards.reset()
+
+	replacement := strings.Replace(adaJSON, `"Analyst"`, `"Director"`, 1)
+	var user User
+	if status := c.do(http.MethodPut, "/Users/"+ada.ID, replacement, &user); status != http.StatusOK {
+		t.Fatalf("replace status = %d", status)
+	}
+	if user.Title != "Director" || !user.Meta.Created.Equal(ada.Meta.Created) {
+		t.Errorf("replaced user = %+v", user)
+	}
+	if len(cards.requests) != 1 || cards.bodies[0]["title"] != "Director" || cards.bodies[0]["email"] != nil {
+		t.Errorf("card requests = %v %v", cards.requests, cards.bodies)
+	}
+
+	cards.reset()
+	if status := c.do(http.MethodDelete, "/Users/"+ada.ID, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete status = %d", status)
+	}
+	if len(cards.requests) != 1 || cards.requests[0] != "POST /v1/key-cards/card_1/delete" {
+		t.Errorf("card requests = %v", cards.requests)
+	}
+	if status := c.do(http.MethodGet, "/Users/"+ada.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("get after delete status = %d", status)
+	}
+}
+
+func TestPatchRollsBackOnFailure(t *testing.T) {
+	c, cards := newTestHandler(t, NewMemoryStore())
+	ada := createAda(t, c)
+	cards.reset()
+	cards.fail = map[string]bool{"POST /v1/key-cards/card_1/suspend": true}
+
+	patch := `{"Operations": [{"op": "replace", "value": {"active": false, "title": "Director"}}]}`
+	if status := c.do(http.MethodPatch, "/Users/"+ada.ID, patch, nil); status != http.StatusBadGateway {
+		t.Fatalf("patch status = %d, want 502", status)
+	}
+	want := []string{"PATCH /v1/key-cards/card_1", "POST /v1/key-cards/card_1/suspend", "PATCH /v1/key-cards/card_1"}
+	if strings.Join(cards.requests, ",") != strings.Join(want, ",") {
+		t.Fatalf("card requests = %v, want %v", cards.requests, want)
+	}
+	if title := cards.bodies[2]["title"]; title != "Analyst" {
+		t.Errorf("rollback title = %v, want Analyst", title)
+	}
+
+	var user User
+	c.do(http.MethodGet, "/Users/"+ada.ID, nil, &user)
+	if user.Title != "Analyst" || !user.IsActive() {
+		t.Errorf("stored user = %+v", user)
+	}
+}
+
+func TestUsersLockedSeparately(t *testing.T) {
+	c, cards := newTestHandler(t, NewMemoryStore())
+	ada := createAda(t, c)
+	var grace User
+	c.do(http.MethodPost, "/Users", `{"userName": "grace@example.com", "title": "Analyst"}`, &grace)
+
+	// Ada's update waits on the card service while Grace's goes through
+	release := make(chan struct{})
+	cards.mu.Lock()
+	cards.hold = map[string]chan struct{}{"PATCH /v1/key-cards/card_1": release}
+	cards.mu.Unlock()
+
+	done := make(chan int)
+	go func() {
+		done <- c.do(http.MethodPatch, "/Users/"+ada.ID, `{"Operations": [{"op": "replace", "value": {"userName": "lovelace@example.com", "title": "Director"}}]}`, nil)
+	}()
+	if status := c.do(http.MethodPatch, "/Users/"+grace.ID, `{"Operations": [{"op": "replace", "path": "title", "value": "Director"}]}`, nil); status != http.StatusOK {
+		t.Errorf("grace patch status = %d", status)
+	}
+	// A request in flight holds the user name it is taking
+	if status := c.do(http.MethodPost, "/Users", `{"userName": "Lovelace@example.com"}`, nil); status != http.StatusConflict {
+		t.Errorf("duplicate create status = %d, want 409", status)
+	}
+	close(release)
+	if status := <-done; status != http.StatusOK {
+		t.Errorf("ada patch status = %d", status)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	c, cards := newTestHandler(t, NewMemoryStore())
+	c.token = "wrong"
+	if status := c.do(http.MethodPost, "/Users", adaJSON, nil); status != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", status)
+	}
+	if len(cards.requests) != 0 {
+		t.Errorf("card requests = %v", cards.requests)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	user := &User{
+		UserName: "ada",
+		Name:     &Name{GivenName: "Ada", FamilyName: "Lovelace"},
+		Emails:   []MultiValue{{Value: "ada@example.com", Type: "work"}, {Value: "ada@home.example.com", Type: "home"}},
+	}
+	patched, err := applyPatch(user, []PatchOperation{
+		{Op: "add", Value: json.RawMessage(`{"name.givenName": "Augusta", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"employeeNumber": "E7"}}`)},
+		{Op: "add", Path: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", Value: json.RawMessage(`"Math"`)},
+		{Op: "remove", Path: `emails[type eq "home"]`},
+		{Op: "replace", Path: "Title", Value: json.RawMessage(`"Countess"`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.FullName() != "Augusta Lovelace" || patched.Title != "Countess" {
+		t.Errorf("patched = %+v", patched)
+	}
+	if patched.Enterprise == nil || patched.Enterprise.EmployeeNumber != "E7" || patched.Enterprise.Department != "Math" {
+		t.Errorf("enterprise = %+v", patched.Enterprise)
+	}
+	if len(patched.Emails) != 1 || patched.Emails[0].Type != "work" {
+		t.Errorf("emails = %+v", patched.Emails)
+	}
+	if user.Name.GivenName != "Ada" {
+		t.Error("applyPatch modified its input")
+	}
+
+	if _, err := applyPatch(user, []PatchOperation{{Op: "move", Path: "title"}}); err == nil {
+		t.Error("expected an error for an unknown op")
+	}
+	if _, err := applyPatch(user, []PatchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`"maybe"`)}}); err == nil {
+		t.Error("expected an error for an invalid active value")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scim.json")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := newTestHandler(t, store)
+	ada := createAda(t, c)
+
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := reopened.Get(context.Background(), ada.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.CardID != "card_1" || record.User.UserName != "ada@example.com" {
+		t.Errorf("record = %+v", record)
+	}
+
+	if err := reopened.Delete(context.Background(), ada.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Get(context.Background(), ada.ID); err != ErrNotFound {
+		t.Errorf("get after delete = %v, want ErrNotFound", err)
+	}
+}
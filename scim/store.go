@@ -0,0 +1,183 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/Access-Grid/accessgrid-go/internal/atomicfile"
+)
+
+// ErrNotFound is returned by a Store for an unknown SCIM ID
+var ErrNotFound = errors.New("scim: user not found")
+
+// Record maps a SCIM user to its pass
+type Record struct {
+	CardID string `json:"card_id"`
+	// User is the last accepted version of the user, with ID and Meta set
+	User User `json:"user"`
+}
+
+// Store keeps the records of a Handler. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Get returns the record of a SCIM ID, or ErrNotFound
+	Get(ctx context.Context, id string) (*Record, error)
+	// Put adds or replaces a record
+	Put(ctx context.Context, record *Record) error
+	// Delete removes a record. Deleting an unknown ID is not an error.
+	Delete(ctx context.Context, id string) error
+	// List returns every record
+	List(ctx context.Context) ([]*Record, error)
+}
+
+// MemoryStore is a Store that keeps records in memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+// Get returns a copy of the record
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+// Put stores a copy of the record
+func (s *MemoryStore) Put(ctx context.Context, record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.User.ID] = *record
+	return nil
+}
+
+// Delete removes the record
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, id)
+	return nil
+}
+
+// List returns copies of the records sorted by creation time
+func (s *MemoryStore) List(ctx context.Context) ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedRecords(s.records), nil
+}
+
+// FileStore is a Store that keeps records in a JSON file, rewritten
+// atomically on every change
+type FileStore struct {
+	path    string
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// OpenFileStore loads a FileStore. A missing file yields an empty store.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, records: map[string]Record{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading SCIM store: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.records); err != nil {
+		return nil, fmt.Errorf("error parsing SCIM store %s: %w", path, err)
+	}
+	if s.records == nil {
+		s.records = map[string]Record{}
+	}
+	return s, nil
+}
+
+// Get returns a copy of the record
+func (s *FileStore) Get(ctx context.Context, id string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+// Put stores the record and saves the file
+func (s *FileStore) Put(ctx context.Context, record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, existed := s.records[record.User.ID]
+	s.records[record.User.ID] = *record
+	if err := s.save(); err != nil {
+		if existed {
+			s.records[record.User.ID] = previous
+		} else {
+			delete(s.records, record.User.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes the record and saves the file
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, existed := s.records[id]
+	if !existed {
+		return nil
+	}
+	delete(s.records, id)
+	if err := s.save(); err != nil {
+		s.records[id] = previous
+		return err
+	}
+	return nil
+}
+
+// List returns copies of the records sorted by creation time
+func (s *FileStore) List(ctx context.Context) ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedRecords(s.records), nil
+}
+
+// save writes the file atomically. The caller must hold s.mu.
+func (s *FileStore) save() error {
+	if err := atomicfile.WriteJSON(s.path, s.records); err != nil {
+		return fmt.Errorf("error writing SCIM store: %w", err)
+	}
+	return nil
+}
+
+func sortedRecords(records map[string]Record) []*Record {
+	list := make([]*Record, 0, len(records))
+	for _, record := range records {
+		record := record
+		list = append(list, &record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].User, list[j].User
+		if a.Meta != nil && b.Meta != nil && !a.Meta.Created.Equal(b.Meta.Created) {
+			return a.Meta.Created.Before(b.Meta.Created)
+		}
+		return a.ID < b.ID
+	})
+	return list
+}
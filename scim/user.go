@@ -0,0 +1,173 @@
+// Package scim serves SCIM 2.0 Users backed by access passes.
+//
+// A Handler lets an identity provider drive pass lifecycle directly:
+// creating a user provisions a pass, replacing or patching it updates the
+// pass, deactivating it suspends the pass and deleting it deletes the pass.
+// The mapping between SCIM IDs and card IDs is kept in a Store.
+package scim
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// Schema URNs
+const (
+	SchemaUser           = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaEnterpriseUser = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	SchemaListResponse   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp        = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError          = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceConfig  = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// Name is the components of a user's name
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// MultiValue is an entry of a multi-valued attribute such as emails
+type MultiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// EnterpriseUser is the enterprise user extension
+type EnterpriseUser struct {
+	EmployeeNumber string `json:"employeeNumber,omitempty"`
+	Department     string `json:"department,omitempty"`
+}
+
+// Meta is the resource metadata
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+// User is a SCIM user. Attributes without a pass field are not kept.
+type User struct {
+	Schemas      []string        `json:"schemas"`
+	ID           string          `json:"id,omitempty"`
+	ExternalID   string          `json:"externalId,omitempty"`
+	UserName     string          `json:"userName"`
+	Name         *Name           `json:"name,omitempty"`
+	DisplayName  string          `json:"displayName,omitempty"`
+	Title        string          `json:"title,omitempty"`
+	Active       *bool           `json:"active,omitempty"`
+	Emails       []MultiValue    `json:"emails,omitempty"`
+	PhoneNumbers []MultiValue    `json:"phoneNumbers,omitempty"`
+	Enterprise   *EnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta         *Meta           `json:"meta,omitempty"`
+}
+
+// IsActive reports whether the user is active. Users are active unless
+// they say otherwise.
+func (u *User) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+// EmployeeID is the pass's employee ID: the enterprise employee number,
+// else the external ID, else the user name
+func (u *User) EmployeeID() string {
+	if u.Enterprise != nil && u.Enterprise.EmployeeNumber != "" {
+		return u.Enterprise.EmployeeNumber
+	}
+	if u.ExternalID != "" {
+		return u.ExternalID
+	}
+	return u.UserName
+}
+
+// FullName is the name printed on the pass
+func (u *User) FullName() string {
+	if u.Name != nil {
+		if u.Name.Formatted != "" {
+			return u.Name.Formatted
+		}
+		if full := strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName); full != "" {
+			return full
+		}
+	}
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.UserName
+}
+
+// Email is the primary email, else the first, else a user name that looks
+// like an email address
+func (u *User) Email() string {
+	if email := primaryValue(u.Emails); email != "" {
+		return email
+	}
+	if strings.Contains(u.UserName, "@") {
+		return u.UserName
+	}
+	return ""
+}
+
+// PhoneNumber is the primary phone number, else the first
+func (u *User) PhoneNumber() string {
+	return primaryValue(u.PhoneNumbers)
+}
+
+func (u *User) department() string {
+	if u.Enterprise != nil {
+		return u.Enterprise.Department
+	}
+	return ""
+}
+
+func primaryValue(values []MultiValue) string {
+	for _, v := range values {
+		if v.Primary {
+			return v.Value
+		}
+	}
+	if len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
+
+// provisionParams builds the pass of a new user
+func provisionParams(u *User, templateID string, start, expiration time.Time) models.ProvisionParams {
+	return models.ProvisionParams{
+		CardTemplateID: templateID,
+		EmployeeID:     u.EmployeeID(),
+		FullName:       u.FullName(),
+		Email:          u.Email(),
+		PhoneNumber:    u.PhoneNumber(),
+		Title:          u.Title,
+		Department:     u.department(),
+		StartDate:      start.UTC(),
+		ExpirationDate: expiration.UTC(),
+	}
+}
+
+// updateParams holds the pass fields that changed between two versions of
+// a user. ok is false when nothing changed. Cleared attributes are left on
+// the pass, since an update cannot clear a field.
+func updateParams(cardID string, old, new *User) (params models.UpdateParams, ok bool) {
+	params.CardID = cardID
+	set := func(field *string, oldValue, newValue string) {
+		if newValue != "" && newValue != oldValue {
+			*field = newValue
+			ok = true
+		}
+	}
+	set(&params.EmployeeID, old.EmployeeID(), new.EmployeeID())
+	set(&params.FullName, old.FullName(), new.FullName())
+	set(&params.Email, old.Email(), new.Email())
+	set(&params.PhoneNumber, old.PhoneNumber(), new.PhoneNumber())
+	set(&params.Title, old.Title, new.Title)
+	set(&params.Department, old.department(), new.department())
+	return params, ok
+}
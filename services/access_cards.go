@@ -2,9 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
 
 	"github.com/Access-Grid/accessgrid-go/client"
 	"github.com/Access-Grid/accessgrid-go/models"
@@ -128,3 +130,117 @@ func (s *AccessCardsService) Delete(ctx context.Context, cardID string) error {
 	}
 	return nil
 }
+
+// ListDevices retrieves the devices an access pass is installed on
+func (s *AccessCardsService) ListDevices(ctx context.Context, cardID string) ([]models.Device, error) {
+	var response struct {
+		Devices []models.Device `json:"devices"`
+	}
+	path := fmt.Sprintf("/v1/key-cards/%s/devices", url.PathEscape(cardID))
+	err := s.client.Request(ctx, http.MethodGet, path, nil, &response)
+	if err != nil {
+		return nil, fmt.Errorf("error listing devices: %w", err)
+	}
+	return response.Devices, nil
+}
+
+// GetDevice retrieves a single device of an access pass
+func (s *AccessCardsService) GetDevice(ctx context.Context, cardID, deviceID string) (*models.Device, error) {
+	var device models.Device
+	path := fmt.Sprintf("/v1/key-cards/%s/devices/%s", url.PathEscape(cardID), url.PathEscape(deviceID))
+	err := s.client.Request(ctx, http.MethodGet, path, nil, &device)
+	if err != nil {
+		return nil, fmt.Errorf("error getting device: %w", err)
+	}
+	return &device, nil
+}
+
+// RemoveDevice removes an access pass from a single device, leaving it
+// installed on any other devices
+func (s *AccessCardsService) RemoveDevice(ctx context.Context, cardID, deviceID string) error {
+	path := fmt.Sprintf("/v1/key-cards/%s/devices/%s", url.PathEscape(cardID), url.PathEscape(deviceID))
+	err := s.client.Request(ctx, http.MethodDelete, path, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error removing device: %w", err)
+	}
+	return nil
+}
+
+// EnforceDeviceLimit keeps at most maxDevices devices across all access passes
+// of an employee by removing the oldest ones. Passes whose template does not
+// allow multiple devices are limited to a single device regardless of
+// maxDevices. It returns the devices that were removed.
+func (s *AccessCardsService) EnforceDeviceLimit(ctx context.Context, employeeID string, maxDevices int) ([]models.Device, error) {
+	if employeeID == "" {
+		return nil, errors.New("employeeID is required")
+	}
+	if maxDevices < 0 {
+		return nil, fmt.Errorf("maxDevices must not be negative, got %d", maxDevices)
+	}
+
+	cards, err := s.List(ctx, &models.ListKeysParams{EmployeeID: employeeID})
+	if err != nil {
+		return nil, err
+	}
+
+	console := NewConsoleService(s.client)
+	allowMultiple := map[string]bool{}
+
+	type cardDevice struct {
+		cardID string
+		device models.Device
+	}
+	var kept []cardDevice
+	var removed []models.Device
+
+	for _, card := range cards {
+		devices, err := s.ListDevices(ctx, card.ID)
+		if err != nil {
+			return removed, err
+		}
+		if len(devices) == 0 {
+			continue
+		}
+		sortDevicesNewestFirst(devices)
+
+		allowed, ok := allowMultiple[card.CardTemplateID]
+		if !ok {
+			template, err := console.ReadTemplate(ctx, card.CardTemplateID)
+			if err != nil {
+				return removed, err
+			}
+			allowed = template.AllowOnMultipleDevices
+			allowMultiple[card.CardTemplateID] = allowed
+		}
+
+		for i, device := range devices {
+			if !allowed && i > 0 {
+				if err := s.RemoveDevice(ctx, card.ID, device.ID); err != nil {
+					return removed, err
+				}
+				removed = append(removed, device)
+				continue
+			}
+			kept = append(kept, cardDevice{cardID: card.ID, device: device})
+		}
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].device.CreatedAt.After(kept[j].device.CreatedAt)
+	})
+	for i := maxDevices; i < len(kept); i++ {
+		if err := s.RemoveDevice(ctx, kept[i].cardID, kept[i].device.ID); err != nil {
+			return removed, err
+		}
+		removed = append(removed, kept[i].device)
+	}
+
+	return removed, nil
+}
+
+// sortDevicesNewestFirst orders devices by creation time, most recent first
+func sortDevicesNewestFirst(devices []models.Device) {
+	sort.SliceStable(devices, func(i, j int) bool {
+		return devices[i].CreatedAt.After(devices[j].CreatedAt)
+	})
+}
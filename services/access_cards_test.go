@@ -427,3 +427,125 @@ func TestAccessCardsService_ErrorPropagation(t *testing.T) {
 		t.Errorf("Message = %q, want %q", apiErr.Message, "Invalid credentials")
 	}
 }
+
+func TestAccessCardsService_Devices(t *testing.T) {
+	var capturedMethod, capturedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedMethod = r.Method
+		capturedPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/v1/key-cards/0xc4rd1d/devices":
+			w.Write([]byte(`{"devices": [
+				{"id": "dev_1", "platform": "apple", "device_type": "phone", "status": "active", "created_at": "2025-01-01T00:00:00Z"},
+				{"id": "dev_2", "platform": "apple", "device_type": "watch", "status": "active", "created_at": "2025-02-01T00:00:00Z"}
+			]}`))
+		case "/v1/key-cards/0xc4rd1d/devices/dev_1":
+			if r.Method == http.MethodGet {
+				w.Write([]byte(`{"id": "dev_1", "platform": "apple", "device_type": "phone", "status": "active"}`))
+				return
+			}
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+	service := NewAccessCardsService(c)
+	ctx := context.Background()
+
+	devices, err := service.ListDevices(ctx, "0xc4rd1d")
+	if err != nil {
+		t.Fatalf("ListDevices() error = %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("ListDevices() got %d devices, want 2", len(devices))
+	}
+	if devices[1].DeviceType != models.DeviceTypeWatch {
+		t.Errorf("devices[1].DeviceType = %v, want %v", devices[1].DeviceType, models.DeviceTypeWatch)
+	}
+
+	device, err := service.GetDevice(ctx, "0xc4rd1d", "dev_1")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	if device.Platform != models.DevicePlatformApple {
+		t.Errorf("device.Platform = %v, want %v", device.Platform, models.DevicePlatformApple)
+	}
+
+	if err := service.RemoveDevice(ctx, "0xc4rd1d", "dev_1"); err != nil {
+		t.Fatalf("RemoveDevice() error = %v", err)
+	}
+	if capturedMethod != http.MethodDelete {
+		t.Errorf("RemoveDevice() method = %s, want DELETE", capturedMethod)
+	}
+	if capturedPath != "/v1/key-cards/0xc4rd1d/devices/dev_1" {
+		t.Errorf("RemoveDevice() path = %s", capturedPath)
+	}
+}
+
+func TestAccessCardsService_EnforceDeviceLimit(t *testing.T) {
+	var removedPaths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method == http.MethodDelete {
+			removedPaths = append(removedPaths, r.URL.Path)
+			w.Write([]byte(`{}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/key-cards":
+			if r.URL.Query().Get("employee_id") != "emp_1" {
+				t.Errorf("expected employee_id filter, got %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"keys": [
+				{"id": "card_multi", "card_template_id": "tmpl_multi"},
+				{"id": "card_single", "card_template_id": "tmpl_single"}
+			]}`))
+		case "/v1/console/card-templates/tmpl_multi":
+			w.Write([]byte(`{"id": "tmpl_multi", "allow_on_multiple_devices": true}`))
+		case "/v1/console/card-templates/tmpl_single":
+			w.Write([]byte(`{"id": "tmpl_single", "allow_on_multiple_devices": false}`))
+		case "/v1/key-cards/card_multi/devices":
+			w.Write([]byte(`{"devices": [
+				{"id": "m_old", "created_at": "2025-01-01T00:00:00Z"},
+				{"id": "m_new", "created_at": "2025-03-01T00:00:00Z"}
+			]}`))
+		case "/v1/key-cards/card_single/devices":
+			w.Write([]byte(`{"devices": [
+				{"id": "s_old", "created_at": "2025-01-15T00:00:00Z"},
+				{"id": "s_new", "created_at": "2025-02-15T00:00:00Z"}
+			]}`))
+		}
+	}))
+	defer server.Close()
+
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+	service := NewAccessCardsService(c)
+
+	removed, err := service.EnforceDeviceLimit(context.Background(), "emp_1", 2)
+	if err != nil {
+		t.Fatalf("EnforceDeviceLimit() error = %v", err)
+	}
+
+	// s_old goes because its template is single-device, m_old because it is
+	// the oldest of the remaining three devices
+	want := []string{
+		"/v1/key-cards/card_single/devices/s_old",
+		"/v1/key-cards/card_multi/devices/m_old",
+	}
+	if len(removedPaths) != len(want) {
+		t.Fatalf("removed %v, want %v", removedPaths, want)
+	}
+	for i := range want {
+		if removedPaths[i] != want[i] {
+			t.Errorf("removedPaths[%d] = %s, want %s", i, removedPaths[i], want[i])
+		}
+	}
+	if len(removed) != 2 || removed[0].ID != "s_old" || removed[1].ID != "m_old" {
+		t.Errorf("removed = %+v", removed)
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// accountEventLogConcurrency caps the event log requests in flight
+const accountEventLogConcurrency = 8
+
+// AccountEventLog retrieves the event logs of several templates, or of
+// every template in the account, and merges them oldest first. Logs are
+// fetched concurrently; the first failure cancels the remaining requests.
+func (s *ConsoleService) AccountEventLog(ctx context.Context, filters models.AccountEventLogFilters) ([]models.Event, error) {
+	var mu sync.Mutex
+	var events []models.Event
+	err := s.eachTemplateLog(ctx, filters, func(ctx context.Context, templateID string) error {
+		logs, err := s.EventLog(ctx, templateID, filters.EventLogFilters)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, event := range logs {
+			if event.TemplateID == "" {
+				event.TemplateID = templateID
+			}
+			if matchesAccountEventFilters(event, filters) {
+				events = append(events, event)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events, nil
+}
+
+// NormalizedAccountEventLog is AccountEventLog with events decoded
+// tolerantly, as NormalizedEventLog does, so one malformed entry does not
+// fail the whole log
+func (s *ConsoleService) NormalizedAccountEventLog(ctx context.Context, filters models.AccountEventLogFilters) ([]models.NormalizedEvent, error) {
+	var mu sync.Mutex
+	var events []models.NormalizedEvent
+	err := s.eachTemplateLog(ctx, filters, func(ctx context.Context, templateID string) error {
+		logs, err := s.NormalizedEventLog(ctx, templateID, filters.EventLogFilters)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, event := range logs {
+			if event.TemplateID == "" {
+				event.TemplateID = templateID
+			}
+			if matchesNormalizedAccountEventFilters(event, filters) {
+				events = append(events, event)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events, nil
+}
+
+// eachTemplateLog calls fetch concurrently for each template the filters
+// select, or every template in the account. The first failure cancels the
+// remaining calls and is returned.
+func (s *ConsoleService) eachTemplateLog(ctx context.Context, filters models.AccountEventLogFilters, fetch func(ctx context.Context, templateID string) error) error {
+	templateIDs := filters.TemplateIDs
+	if len(templateIDs) == 0 {
+		templates, err := s.ListTemplates(ctx)
+		if err != nil {
+			return err
+		}
+		for _, template := range templates {
+			templateIDs = append(templateIDs, template.ID)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	sem := make(chan struct{}, accountEventLogConcurrency)
+	for _, templateID := range templateIDs {
+		wg.Add(1)
+		go func(templateID string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if err := fetch(ctx, templateID); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("template %s: %w", templateID, err)
+					cancel()
+				}
+			}
+		}(templateID)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// matchesAccountEventFilters applies the filters the event log endpoint
+// does not support
+func matchesAccountEventFilters(event models.Event, filters models.AccountEventLogFilters) bool {
+	if filters.CardID != "" && event.CardID != filters.CardID {
+		return false
+	}
+	if filters.UserID != "" && event.UserID != filters.UserID {
+		return false
+	}
+	if filters.EventType != "" && event.Type != filters.EventType && event.Event != filters.EventType {
+		return false
+	}
+	return true
+}
+
+func matchesNormalizedAccountEventFilters(event models.NormalizedEvent, filters models.AccountEventLogFilters) bool {
+	if filters.CardID != "" && event.CardID != filters.CardID {
+		return false
+	}
+	if filters.UserID != "" && event.UserID != filters.UserID {
+		return false
+	}
+	if filters.EventType != "" && string(event.Type) != filters.EventType {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+func accountEventsHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/console/card-templates":
+			w.Write([]byte(`[{"id": "tmpl_a", "name": "A"}, {"id": "tmpl_b", "name": "B"}]`))
+		case "/v1/console/card-templates/tmpl_a/logs":
+			if got := r.URL.Query().Get("event_type"); got != "" && got != "install" {
+				t.Errorf("event_type = %q", got)
+			}
+			w.Write([]byte(`{"logs": [
+				{"id": "a1", "type": "install", "card_id": "card_1", "user_id": "usr_1", "timestamp": "2026-05-01T09:00:00Z"},
+				{"id": "a2", "type": "install", "card_id": "card_2", "user_id": "usr_2", "timestamp": "2026-05-01T11:00:00Z"}
+			]}`))
+		case "/v1/console/card-templates/tmpl_b/logs":
+			w.Write([]byte(`{"logs": [
+				{"id": "b1", "type": "install", "card_id": "card_3", "user_id": "usr_1", "timestamp": "2026-05-01T10:00:00Z"}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "not found"}`))
+		}
+	})
+}
+
+func eventIDs(events []models.Event) string {
+	var ids []string
+	for _, event := range events {
+		ids = append(ids, event.ID.(string))
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestConsoleService_AccountEventLog(t *testing.T) {
+	service := newTailTestService(t, accountEventsHandler(t))
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		filters models.AccountEventLogFilters
+		want    string
+	}{
+		{"all templates merged by time", models.AccountEventLogFilters{}, "a1,b1,a2"},
+		{"chosen templates", models.AccountEventLogFilters{TemplateIDs: []string{"tmpl_b"}}, "b1"},
+		{"by user", models.AccountEventLogFilters{UserID: "usr_1"}, "a1,b1"},
+		{"by card", models.AccountEventLogFilters{CardID: "card_2"}, "a2"},
+		{"by event type", models.AccountEventLogFilters{EventLogFilters: models.EventLogFilters{EventType: "install"}}, "a1,b1,a2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := service.AccountEventLog(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("AccountEventLog() error = %v", err)
+			}
+			if got := eventIDs(events); got != tt.want {
+				t.Errorf("AccountEventLog() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	events, _ := service.AccountEventLog(ctx, models.AccountEventLogFilters{TemplateIDs: []string{"tmpl_b"}})
+	if len(events) == 1 && events[0].TemplateID != "tmpl_b" {
+		t.Errorf("TemplateID = %q, want tmpl_b", events[0].TemplateID)
+	}
+}
+
+func TestConsoleService_AccountEventLog_Error(t *testing.T) {
+	service := newTailTestService(t, accountEventsHandler(t))
+
+	_, err := service.AccountEventLog(context.Background(), models.AccountEventLogFilters{TemplateIDs: []string{"tmpl_a", "missing"}})
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("AccountEventLog() error = %v, want error naming the failed template", err)
+	}
+}
+
+func TestConsoleService_NormalizedAccountEventLog(t *testing.T) {
+	service := newTailTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/console/card-templates/tmpl_a/logs":
+			w.Write([]byte(`{"logs": [
+				{"id": 7, "type": "install", "user_id": "usr_1", "timestamp": "2026-05-01T09:00:00Z"},
+				{"id": "a2", "type": "install", "user_id": "usr_1", "timestamp": "yesterday"}
+			]}`))
+		case "/v1/console/card-templates/tmpl_b/logs":
+			w.Write([]byte(`{"logs": [{"id": "b1", "type": "suspend", "user_id": "usr_2", "timestamp": "2026-05-01T10:00:00Z"}]}`))
+		}
+	}))
+	ctx := context.Background()
+	filters := models.AccountEventLogFilters{TemplateIDs: []string{"tmpl_a", "tmpl_b"}}
+
+	// The original decoder fails on the malformed timestamp
+	if _, err := service.AccountEventLog(ctx, filters); err == nil {
+		t.Error("AccountEventLog() error = nil, want a decoding error")
+	}
+
+	events, err := service.NormalizedAccountEventLog(ctx, filters)
+	if err != nil {
+		t.Fatalf("NormalizedAccountEventLog() error = %v", err)
+	}
+	var ids []string
+	for _, event := range events {
+		ids = append(ids, string(event.ID)+"@"+event.TemplateID)
+	}
+	if got := strings.Join(ids, ","); got != "a2@tmpl_a,7@tmpl_a,b1@tmpl_b" {
+		t.Errorf("NormalizedAccountEventLog() = %s", got)
+	}
+
+	filters.UserID = "usr_1"
+	filters.EventType = "install"
+	if events, _ := service.NormalizedAccountEventLog(ctx, filters); len(events) != 2 {
+		t.Errorf("filtered events = %d, want 2", len(events))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/Access-Grid/accessgrid-go/client"
@@ -224,6 +225,88 @@ func (s *ConsoleService) CreatePassTemplatePair(ctx context.Context, params mode
 	return &response, nil
 }
 
+// GetPassTemplatePair retrieves a pass template pair by ID
+func (s *ConsoleService) GetPassTemplatePair(ctx context.Context, pairID string) (*models.PassTemplatePair, error) {
+	var pair models.PassTemplatePair
+	path := fmt.Sprintf("/v1/console/card-template-pairs/%s", url.PathEscape(pairID))
+	err := s.client.Request(ctx, http.MethodGet, path, nil, &pair)
+	if err != nil {
+		return nil, fmt.Errorf("error getting pass template pair: %w", err)
+	}
+	return &pair, nil
+}
+
+// UpdatePassTemplatePair renames a pass template pair or swaps its Apple or
+// Google template. Replacement templates are subject to the same
+// constraints as CreatePassTemplatePair.
+func (s *ConsoleService) UpdatePassTemplatePair(ctx context.Context, params models.UpdatePassTemplatePairParams) (*models.PassTemplatePair, error) {
+	var pair models.PassTemplatePair
+	path := fmt.Sprintf("/v1/console/card-template-pairs/%s", url.PathEscape(params.PassTemplatePairID))
+	err := s.client.Request(ctx, http.MethodPut, path, params, &pair)
+	if err != nil {
+		return nil, fmt.Errorf("error updating pass template pair: %w", err)
+	}
+	return &pair, nil
+}
+
+// DeletePassTemplatePair deletes a pass template pair. The paired
+// templates are not deleted.
+func (s *ConsoleService) DeletePassTemplatePair(ctx context.Context, pairID string) error {
+	path := fmt.Sprintf("/v1/console/card-template-pairs/%s", url.PathEscape(pairID))
+	err := s.client.Request(ctx, http.MethodDelete, path, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error deleting pass template pair: %w", err)
+	}
+	return nil
+}
+
+// PassTemplatePairError lists the reasons two templates cannot be paired
+type PassTemplatePairError struct {
+	Problems []string
+}
+
+// Error implements the error interface
+func (e *PassTemplatePairError) Error() string {
+	return "templates cannot be paired: " + strings.Join(e.Problems, "; ")
+}
+
+// PreflightPassTemplatePair reads both templates of a pair and checks that
+// the first is an Apple template, the second a Google template, both are
+// published and both use the same protocol. It returns a
+// *PassTemplatePairError describing every violated constraint, so problems
+// surface before CreatePassTemplatePair or UpdatePassTemplatePair is called.
+func (s *ConsoleService) PreflightPassTemplatePair(ctx context.Context, appleTemplateID, googleTemplateID string) error {
+	apple, err := s.ReadTemplate(ctx, appleTemplateID)
+	if err != nil {
+		return err
+	}
+	google, err := s.ReadTemplate(ctx, googleTemplateID)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	if apple.Platform != "apple" {
+		problems = append(problems, fmt.Sprintf("Apple template %s has platform %q, want apple", apple.ID, apple.Platform))
+	}
+	if google.Platform != "android" && google.Platform != "google" {
+		problems = append(problems, fmt.Sprintf("Google template %s has platform %q, want android", google.ID, google.Platform))
+	}
+	for _, t := range []*models.Template{apple, google} {
+		if t.Status != "ready" {
+			problems = append(problems, fmt.Sprintf("template %s is not published (status %q, want ready)", t.ID, t.Status))
+		}
+	}
+	if apple.Protocol != google.Protocol {
+		problems = append(problems, fmt.Sprintf("protocols differ: Apple template uses %q, Google template uses %q", apple.Protocol, google.Protocol))
+	}
+
+	if len(problems) > 0 {
+		return &PassTemplatePairError{Problems: problems}
+	}
+	return nil
+}
+
 // ListLedgerItems retrieves billing ledger items
 func (s *ConsoleService) ListLedgerItems(ctx context.Context, params models.ListLedgerItemsParams) (*models.LedgerItemsResponse, error) {
 	var response models.LedgerItemsResponse
@@ -285,6 +368,27 @@ func (s *ConsoleService) UpdateLandingPage(ctx context.Context, params models.Up
 	return &page, nil
 }
 
+// GetLandingPage retrieves a landing page by ID
+func (s *ConsoleService) GetLandingPage(ctx context.Context, landingPageID string) (*models.LandingPage, error) {
+	var page models.LandingPage
+	path := fmt.Sprintf("/v1/console/landing-pages/%s", url.PathEscape(landingPageID))
+	err := s.client.Request(ctx, http.MethodGet, path, nil, &page)
+	if err != nil {
+		return nil, fmt.Errorf("error getting landing page: %w", err)
+	}
+	return &page, nil
+}
+
+// DeleteLandingPage deletes a landing page
+func (s *ConsoleService) DeleteLandingPage(ctx context.Context, landingPageID string) error {
+	path := fmt.Sprintf("/v1/console/landing-pages/%s", url.PathEscape(landingPageID))
+	err := s.client.Request(ctx, http.MethodDelete, path, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error deleting landing page: %w", err)
+	}
+	return nil
+}
+
 // CredentialProfilesService handles credential profile operations
 type CredentialProfilesService struct {
 	client *client.Client
@@ -305,8 +409,14 @@ func (s *CredentialProfilesService) List(ctx context.Context) ([]models.Credenti
 	return profiles, nil
 }
 
-// Create creates a new credential profile
+// Create creates a new credential profile. The params are validated first,
+// and a *models.ValidationError is returned without calling the API if
+// they are invalid.
 func (s *CredentialProfilesService) Create(ctx context.Context, params models.CreateCredentialProfileParams) (*models.CredentialProfile, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
 	var profile models.CredentialProfile
 	err := s.client.Request(ctx, http.MethodPost, "/v1/console/credential-profiles", params, &profile)
 	if err != nil {
@@ -315,12 +425,75 @@ func (s *CredentialProfilesService) Create(ctx context.Context, params models.Cr
 	return &profile, nil
 }
 
+// Get retrieves a credential profile by ID
+func (s *CredentialProfilesService) Get(ctx context.Context, profileID string) (*models.CredentialProfile, error) {
+	var profile models.CredentialProfile
+	path := fmt.Sprintf("/v1/console/credential-profiles/%s", url.PathEscape(profileID))
+	err := s.client.Request(ctx, http.MethodGet, path, nil, &profile)
+	if err != nil {
+		return nil, fmt.Errorf("error getting credential profile: %w", err)
+	}
+	return &profile, nil
+}
+
+// Update updates an existing credential profile. Replacement keys are
+// validated like Create's, and a *models.ValidationError is returned without
+// calling the API if they are invalid.
+func (s *CredentialProfilesService) Update(ctx context.Context, params models.UpdateCredentialProfileParams) (*models.CredentialProfile, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	var profile models.CredentialProfile
+	path := fmt.Sprintf("/v1/console/credential-profiles/%s", url.PathEscape(params.CredentialProfileID))
+	err := s.client.Request(ctx, http.MethodPut, path, params, &profile)
+	if err != nil {
+		return nil, fmt.Errorf("error updating credential profile: %w", err)
+	}
+	return &profile, nil
+}
+
+// Delete deletes a credential profile
+func (s *CredentialProfilesService) Delete(ctx context.Context, profileID string) error {
+	path := fmt.Sprintf("/v1/console/credential-profiles/%s", url.PathEscape(profileID))
+	err := s.client.Request(ctx, http.MethodDelete, path, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error deleting credential profile: %w", err)
+	}
+	return nil
+}
+
 // EventLog retrieves event logs for a specific template
 func (s *ConsoleService) EventLog(ctx context.Context, templateID string, filters models.EventLogFilters) ([]models.Event, error) {
 	var response struct {
 		Logs []models.Event `json:"logs"`
 	}
 
+	err := s.client.Request(ctx, http.MethodGet, eventLogPath(templateID, filters), nil, &response)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching event log: %w", err)
+	}
+
+	return response.Logs, nil
+}
+
+// NormalizedEventLog retrieves event logs for a specific template as
+// normalized events
+func (s *ConsoleService) NormalizedEventLog(ctx context.Context, templateID string, filters models.EventLogFilters) ([]models.NormalizedEvent, error) {
+	var response struct {
+		Logs []models.NormalizedEvent `json:"logs"`
+	}
+
+	err := s.client.Request(ctx, http.MethodGet, eventLogPath(templateID, filters), nil, &response)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching event log: %w", err)
+	}
+
+	return response.Logs, nil
+}
+
+// eventLogPath builds the event log URL for a template
+func eventLogPath(templateID string, filters models.EventLogFilters) string {
 	// Build query parameters
 	query := url.Values{}
 	if filters.Device != "" {
@@ -345,12 +518,5 @@ func (s *ConsoleService) EventLog(ctx context.Context, templateID string, filter
 		u.RawQuery = query.Encode()
 	}
 
-	path := u.String()
-
-	err := s.client.Request(ctx, http.MethodGet, path, nil, &response)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching event log: %w", err)
-	}
-
-	return response.Logs, nil
+	return u.String()
 }
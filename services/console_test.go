@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -466,6 +467,102 @@ func TestConsoleService_CreatePassTemplatePair(t *testing.T) {
 	}
 }
 
+func TestConsoleService_PassTemplatePairCRUD(t *testing.T) {
+	var capturedMethod, capturedPath, capturedBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		capturedMethod, capturedPath, capturedBody = r.Method, r.URL.Path, string(body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodDelete {
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.Write([]byte(`{
+			"id": "pair_1",
+			"name": "Renamed Pair",
+			"ios_template": {"id": "tmpl_ios_2", "platform": "apple"},
+			"android_template": {"id": "tmpl_android_1", "platform": "android"}
+		}`))
+	}))
+	defer server.Close()
+
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+	service := NewConsoleService(c)
+	ctx := context.Background()
+
+	pair, err := service.GetPassTemplatePair(ctx, "pair_1")
+	if err != nil {
+		t.Fatalf("GetPassTemplatePair() error = %v", err)
+	}
+	if pair.ID != "pair_1" || capturedPath != "/v1/console/card-template-pairs/pair_1" {
+		t.Errorf("GetPassTemplatePair() pair.ID = %v, path = %v", pair.ID, capturedPath)
+	}
+
+	pair, err = service.UpdatePassTemplatePair(ctx, models.UpdatePassTemplatePairParams{
+		PassTemplatePairID:  "pair_1",
+		Name:                "Renamed Pair",
+		AppleCardTemplateID: "tmpl_ios_2",
+	})
+	if err != nil {
+		t.Fatalf("UpdatePassTemplatePair() error = %v", err)
+	}
+	if capturedMethod != http.MethodPut {
+		t.Errorf("UpdatePassTemplatePair() method = %s, want PUT", capturedMethod)
+	}
+	if !strings.Contains(capturedBody, `"apple_card_template_id":"tmpl_ios_2"`) {
+		t.Errorf("expected apple_card_template_id in request body, got %s", capturedBody)
+	}
+	if strings.Contains(capturedBody, "google_card_template_id") {
+		t.Errorf("expected google_card_template_id to be omitted, got %s", capturedBody)
+	}
+	if pair.IOSTemplate.ID != "tmpl_ios_2" {
+		t.Errorf("pair.IOSTemplate.ID = %v, want tmpl_ios_2", pair.IOSTemplate.ID)
+	}
+
+	if err := service.DeletePassTemplatePair(ctx, "pair_1"); err != nil {
+		t.Fatalf("DeletePassTemplatePair() error = %v", err)
+	}
+	if capturedMethod != http.MethodDelete {
+		t.Errorf("DeletePassTemplatePair() method = %s, want DELETE", capturedMethod)
+	}
+}
+
+func TestConsoleService_PreflightPassTemplatePair(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/console/card-templates/tmpl_ios":
+			w.Write([]byte(`{"id": "tmpl_ios", "platform": "apple", "protocol": "desfire", "status": "ready"}`))
+		case "/v1/console/card-templates/tmpl_android":
+			w.Write([]byte(`{"id": "tmpl_android", "platform": "android", "protocol": "desfire", "status": "ready"}`))
+		case "/v1/console/card-templates/tmpl_android_draft":
+			w.Write([]byte(`{"id": "tmpl_android_draft", "platform": "android", "protocol": "seos", "status": "draft"}`))
+		}
+	}))
+	defer server.Close()
+
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+	service := NewConsoleService(c)
+	ctx := context.Background()
+
+	if err := service.PreflightPassTemplatePair(ctx, "tmpl_ios", "tmpl_android"); err != nil {
+		t.Errorf("PreflightPassTemplatePair() error = %v, want nil", err)
+	}
+
+	err := service.PreflightPassTemplatePair(ctx, "tmpl_android", "tmpl_android_draft")
+	var pairErr *PassTemplatePairError
+	if !errors.As(err, &pairErr) {
+		t.Fatalf("PreflightPassTemplatePair() error = %v, want *PassTemplatePairError", err)
+	}
+	// Wrong Apple platform, draft status and mismatched protocols
+	if len(pairErr.Problems) != 3 {
+		t.Errorf("got %d problems, want 3: %v", len(pairErr.Problems), pairErr.Problems)
+	}
+	if !strings.Contains(err.Error(), "is not published") {
+		t.Errorf("expected publish problem, got: %s", err.Error())
+	}
+}
+
 // --- Ledger Items ---
 
 func TestConsoleService_ListLedgerItems(t *testing.T) {
@@ -1079,6 +1176,38 @@ func TestConsoleService_UpdateLandingPage(t *testing.T) {
 	}
 }
 
+func TestConsoleService_GetAndDeleteLandingPage(t *testing.T) {
+	var capturedMethod string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedMethod = r.Method
+		if r.URL.Path != "/v1/console/landing-pages/lp_123" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "lp_123", "name": "Miami Office", "kind": "universal", "password_protected": true}`))
+	}))
+	defer server.Close()
+
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+	service := NewConsoleService(c)
+
+	ctx := context.Background()
+	page, err := service.GetLandingPage(ctx, "lp_123")
+	if err != nil {
+		t.Fatalf("GetLandingPage() error = %v", err)
+	}
+	if page.Name != "Miami Office" || !page.PasswordProtected {
+		t.Errorf("GetLandingPage() page = %+v", page)
+	}
+
+	if err := service.DeleteLandingPage(ctx, "lp_123"); err != nil {
+		t.Fatalf("DeleteLandingPage() error = %v", err)
+	}
+	if capturedMethod != http.MethodDelete {
+		t.Errorf("DeleteLandingPage() method = %s, want DELETE", capturedMethod)
+	}
+}
+
 // --- Credential Profiles ---
 
 func TestCredentialProfilesService_List(t *testing.T) {
@@ -1161,8 +1290,8 @@ func TestCredentialProfilesService_Create(t *testing.T) {
 		Name:    "Main Office Profile",
 		AppName: "KEY-ID-main",
 		Keys: []models.KeyParam{
-			{Value: "your_32_char_hex_master_key_here"},
-			{Value: "your_32_char_hex__read_key__here"},
+			{Value: "00112233445566778899aabbccddeeff"},
+			{Value: "ffeeddccbbaa99887766554433221100"},
 		},
 	})
 	if err != nil {
@@ -1180,6 +1309,97 @@ func TestCredentialProfilesService_Create(t *testing.T) {
 	}
 }
 
+func TestCredentialProfilesService_Validates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+	service := NewCredentialProfilesService(c)
+
+	_, err := service.Create(context.Background(), models.CreateCredentialProfileParams{
+		Name:    "Main Office Profile",
+		AppName: "KEY-ID-main",
+		Keys:    []models.KeyParam{{Value: "not-hex"}},
+	})
+	var validationErr *models.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Create() error = %v, want *models.ValidationError", err)
+	}
+
+	_, err = service.Update(context.Background(), models.UpdateCredentialProfileParams{
+		CredentialProfileID: "cp_1",
+		Keys:                []models.KeyParam{{Value: "not-hex"}},
+	})
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Update() error = %v, want *models.ValidationError", err)
+	}
+}
+
+func TestCredentialProfilesService_GetUpdateDelete(t *testing.T) {
+	var capturedMethod, capturedBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		capturedMethod, capturedBody = r.Method, string(body)
+		if r.URL.Path != "/v1/console/credential-profiles/cp_1" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "cp_1",
+			"name": "Main Office",
+			"aid": "AID001",
+			"card_storage": {"type": "desfire_ev3", "size": 8192},
+			"keys": [
+				{"key_number": 0, "name": "master", "version": 1},
+				{"key_number": 1, "name": "read", "version": 2}
+			],
+			"files": [{"file_id": "00", "size": 32}]
+		}`))
+	}))
+	defer server.Close()
+
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+	service := NewCredentialProfilesService(c)
+	ctx := context.Background()
+
+	profile, err := service.Get(ctx, "cp_1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if profile.CardStorage == nil || profile.CardStorage.Type != "desfire_ev3" || profile.CardStorage.Size != 8192 {
+		t.Errorf("profile.CardStorage = %+v", profile.CardStorage)
+	}
+	if len(profile.Keys) != 2 || profile.Keys[1].Name != "read" || profile.Keys[1].Version != 2 {
+		t.Errorf("profile.Keys = %+v", profile.Keys)
+	}
+	if len(profile.Files) != 1 || profile.Files[0].FileID != "00" {
+		t.Errorf("profile.Files = %+v", profile.Files)
+	}
+
+	_, err = service.Update(ctx, models.UpdateCredentialProfileParams{
+		CredentialProfileID: "cp_1",
+		Name:                "Main Office",
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if capturedMethod != http.MethodPut {
+		t.Errorf("Update() method = %s, want PUT", capturedMethod)
+	}
+	if !strings.Contains(capturedBody, `"name":"Main Office"`) || strings.Contains(capturedBody, `"keys"`) {
+		t.Errorf("unexpected request body %s", capturedBody)
+	}
+
+	if err := service.Delete(ctx, "cp_1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if capturedMethod != http.MethodDelete {
+		t.Errorf("Delete() method = %s, want DELETE", capturedMethod)
+	}
+}
+
 func TestConsoleService_ErrorPropagation(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -1217,3 +1437,22 @@ func TestConsoleService_ErrorPropagation(t *testing.T) {
 		t.Errorf("Message = %q, want %q", apiErr.Message, "Resource not found")
 	}
 }
+
+func TestConsoleService_NormalizedEventLog(t *testing.T) {
+	server, service := setupConsoleTestServer()
+	defer server.Close()
+
+	events, err := service.NormalizedEventLog(context.Background(), "0xd3adb00b5", models.EventLogFilters{})
+	if err != nil {
+		t.Fatalf("NormalizedEventLog() error = %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("NormalizedEventLog() got %v events, want 1", len(events))
+	}
+	if events[0].ID != "evt_123" || events[0].Type != models.EventTypeInstall {
+		t.Errorf("NormalizedEventLog() events[0] = %+v", events[0])
+	}
+	if events[0].Extra != nil {
+		t.Errorf("NormalizedEventLog() events[0].Extra = %v, want nil", events[0].Extra)
+	}
+}
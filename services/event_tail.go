@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/internal/atomicfile"
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+const (
+	defaultTailPollInterval = 30 * time.Second
+	defaultTailMaxBackoff   = 5 * time.Minute
+)
+
+// EventCursor records how far an event tail has read. The watermark is the
+// timestamp of the newest delivered event; SeenIDs holds the events already
+// delivered at or after the watermark, since the API filters on whole
+// seconds and returns them again on the next poll.
+type EventCursor struct {
+	Watermark time.Time `json:"watermark"`
+	SeenIDs   []string  `json:"seen_ids,omitempty"`
+}
+
+// CursorStore persists an event tail cursor between runs
+type CursorStore interface {
+	// LoadCursor returns the saved cursor, or nil if there is none
+	LoadCursor() (*EventCursor, error)
+	SaveCursor(cursor *EventCursor) error
+}
+
+// FileCursorStore keeps an event tail cursor in a JSON file
+type FileCursorStore struct {
+	Path string
+}
+
+// LoadCursor reads the cursor file. A missing file returns a nil cursor.
+func (f FileCursorStore) LoadCursor() (*EventCursor, error) {
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading cursor: %w", err)
+	}
+
+	var cursor EventCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("error parsing cursor %s: %w", f.Path, err)
+	}
+	return &cursor, nil
+}
+
+// SaveCursor replaces the cursor file atomically
+func (f FileCursorStore) SaveCursor(cursor *EventCursor) error {
+	if err := atomicfile.WriteJSON(f.Path, cursor); err != nil {
+		return fmt.Errorf("error writing cursor: %w", err)
+	}
+	return nil
+}
+
+// TailOptions configures TailEvents
+type TailOptions struct {
+	// PollInterval is the time between polls. Defaults to 30 seconds.
+	PollInterval time.Duration
+	// MaxBackoff caps the delay between retries after failed polls.
+	// Defaults to 5 minutes.
+	MaxBackoff time.Duration
+	// CursorStore, if set, supplies the starting cursor and is updated
+	// after each batch of events is delivered
+	CursorStore CursorStore
+	// OnError is called with errors the tail recovers from, such as
+	// failed polls and cursor saves
+	OnError func(error)
+}
+
+// EventTail streams new events from a template's event log
+type EventTail struct {
+	events chan models.Event
+	err    error
+}
+
+// Events returns the channel of new events, oldest first. It is closed
+// when the context is cancelled or the tail stops on an error.
+func (t *EventTail) Events() <-chan models.Event {
+	return t.events
+}
+
+// Err returns the error that stopped the tail. It is only valid once the
+// events channel is closed, and is nil if the context was cancelled.
+func (t *EventTail) Err() error {
+	return t.err
+}
+
+// TailEvents polls a template's event log and streams events as they
+// arrive. Without a saved cursor it starts from filters.StartDate, or from
+// now if that is unset. Failed polls are retried with exponential backoff;
+// the tail only stops on client errors that retrying cannot fix, such as an
+// unknown template or bad credentials.
+func (s *ConsoleService) TailEvents(ctx context.Context, templateID string, filters models.EventLogFilters, opts TailOptions) (*EventTail, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultTailPollInterval
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultTailMaxBackoff
+	}
+
+	var cursor *EventCursor
+	if opts.CursorStore != nil {
+		saved, err := opts.CursorStore.LoadCursor()
+		if err != nil {
+			return nil, err
+		}
+		cursor = saved
+	}
+	if cursor == nil {
+		cursor = &EventCursor{Watermark: time.Now().UTC()}
+		if filters.StartDate != nil {
+			cursor.Watermark = *filters.StartDate
+		}
+	}
+
+	tail := &EventTail{events: make(chan models.Event)}
+	go func() {
+		defer close(tail.events)
+		tail.err = s.tail(ctx, templateID, filters, opts, newTailState(cursor), tail.events)
+	}()
+	return tail, nil
+}
+
+// tailState tracks the watermark and the events delivered at or after it
+type tailState struct {
+	watermark time.Time
+	seen      map[string]time.Time
+}
+
+func newTailState(cursor *EventCursor) *tailState {
+	state := &tailState{watermark: cursor.Watermark, seen: map[string]time.Time{}}
+	for _, id := range cursor.SeenIDs {
+		state.seen[id] = cursor.Watermark
+	}
+	return state
+}
+
+// deliver records an event as delivered and advances the watermark
+func (t *tailState) deliver(id string, at time.Time) {
+	t.seen[id] = at
+	if at.After(t.watermark) {
+		t.watermark = at
+	}
+}
+
+// prune forgets events the next poll can no longer return, so the seen
+// set stays as small as the events of one second
+func (t *tailState) prune() {
+	floor := t.watermark.Truncate(time.Second)
+	for id, at := range t.seen {
+		if at.Before(floor) {
+			delete(t.seen, id)
+		}
+	}
+}
+
+// cursor snapshots the state
+func (t *tailState) cursor() *EventCursor {
+	cursor := &EventCursor{Watermark: t.watermark}
+	for id := range t.seen {
+		cursor.SeenIDs = append(cursor.SeenIDs, id)
+	}
+	sort.Strings(cursor.SeenIDs)
+	return cursor
+}
+
+func (s *ConsoleService) tail(ctx context.Context, templateID string, filters models.EventLogFilters, opts TailOptions, state *tailState, out chan<- models.Event) error {
+	delay := time.Duration(0)
+	failures := 0
+
+	for {
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+		}
+
+		since := state.watermark
+		filters.StartDate = &since
+		events, err := s.EventLog(ctx, templateID, filters)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !retryable(err) {
+				return err
+			}
+			if opts.OnError != nil {
+				opts.OnError(err)
+			}
+			failures++
+			delay = backoff(opts.PollInterval, opts.MaxBackoff, failures)
+			continue
+		}
+		failures = 0
+		delay = opts.PollInterval
+
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		})
+
+		floor := since.Truncate(time.Second)
+		delivered := false
+		for _, event := range events {
+			id := eventKey(event)
+			if _, ok := state.seen[id]; ok || event.Timestamp.Before(floor) {
+				continue
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				state.prune()
+				s.saveCursor(opts, state, delivered)
+				return nil
+			}
+			state.deliver(id, event.Timestamp)
+			delivered = true
+		}
+		if delivered {
+			state.prune()
+		}
+		s.saveCursor(opts, state, delivered)
+	}
+}
+
+func (s *ConsoleService) saveCursor(opts TailOptions, state *tailState, changed bool) {
+	if opts.CursorStore == nil || !changed {
+		return
+	}
+	if err := opts.CursorStore.SaveCursor(state.cursor()); err != nil && opts.OnError != nil {
+		opts.OnError(err)
+	}
+}
+
+// eventKey identifies an event for deduplication, the same way as
+// models.NormalizedEvent.Key
+func eventKey(event models.Event) string {
+	return event.Normalize().Key()
+}
+
+// retryable reports whether a failed request may succeed if repeated
+func retryable(err error) bool {
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == http.StatusRequestTimeout
+}
+
+// backoff doubles the delay for each consecutive failure up to max
+func backoff(base, max time.Duration, failures int) time.Duration {
+	delay := base
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
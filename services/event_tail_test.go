@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// eventLogServer serves a scripted sequence of event log responses, one
+// per poll, repeating the last one
+type eventLogServer struct {
+	mu        sync.Mutex
+	responses []string
+	polls     int
+	starts    []string
+}
+
+func (e *eventLogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.starts = append(e.starts, r.URL.Query().Get("start_date"))
+	body := e.responses[min(e.polls, len(e.responses)-1)]
+	e.polls++
+
+	w.Header().Set("Content-Type", "application/json")
+	switch body {
+	case "500":
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"message": "upstream unavailable"}`))
+	case "404":
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "template not found"}`))
+	default:
+		w.Write([]byte(body))
+	}
+}
+
+func logsResponse(ids ...string) string {
+	body := `{"logs": [`
+	for i, id := range ids {
+		if i > 0 {
+			body += ","
+		}
+		body += fmt.Sprintf(`{"id": %q, "type": "install", "card_id": "0xc4rd1d", "timestamp": "2026-05-01T12:00:0%d.500Z"}`, id, i)
+	}
+	return body + `]}`
+}
+
+func newTailTestService(t *testing.T, handler http.Handler) *ConsoleService {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, _ := client.NewClient("test-account", "test-secret", client.WithBaseURL(server.URL))
+	return NewConsoleService(c)
+}
+
+func collectEvents(t *testing.T, tail *EventTail, n int) []string {
+	t.Helper()
+	var ids []string
+	timeout := time.After(5 * time.Second)
+	for len(ids) < n {
+		select {
+		case event, ok := <-tail.Events():
+			if !ok {
+				t.Fatalf("tail stopped after %v: %v", ids, tail.Err())
+			}
+			ids = append(ids, fmt.Sprint(event.ID))
+		case <-timeout:
+			t.Fatalf("timed out after %v", ids)
+		}
+	}
+	return ids
+}
+
+func TestConsoleService_TailEvents(t *testing.T) {
+	server := &eventLogServer{responses: []string{
+		logsResponse("evt_1"),
+		"500",
+		logsResponse("evt_1", "evt_2"),
+		logsResponse("evt_1", "evt_2"),
+	}}
+	service := newTailTestService(t, server)
+	store := FileCursorStore{Path: filepath.Join(t.TempDir(), "cursor.json")}
+
+	var errs []error
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	ctx, cancel := context.WithCancel(context.Background())
+	tail, err := service.TailEvents(ctx, "0xd3adb00b5", models.EventLogFilters{StartDate: &start}, TailOptions{
+		PollInterval: time.Millisecond,
+		CursorStore:  store,
+		OnError:      func(err error) { errs = append(errs, err) },
+	})
+	if err != nil {
+		t.Fatalf("TailEvents() error = %v", err)
+	}
+
+	ids := collectEvents(t, tail, 2)
+	if ids[0] != "evt_1" || ids[1] != "evt_2" {
+		t.Errorf("events = %v, want [evt_1 evt_2]", ids)
+	}
+
+	// Let another poll run so a duplicate would show up
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	for event := range tail.Events() {
+		t.Errorf("unexpected duplicate event %v", event.ID)
+	}
+	if tail.Err() != nil {
+		t.Errorf("Err() = %v, want nil after cancel", tail.Err())
+	}
+	if len(errs) == 0 {
+		t.Error("expected the failed poll to be reported to OnError")
+	}
+
+	server.mu.Lock()
+	if server.starts[0] != "2026-05-01T00:00:00Z" || server.starts[2] != "2026-05-01T12:00:00Z" {
+		t.Errorf("start_date not advanced: %v", server.starts[:3])
+	}
+	server.mu.Unlock()
+
+	cursor, err := store.LoadCursor()
+	if err != nil || cursor == nil {
+		t.Fatalf("LoadCursor() = %v, %v", cursor, err)
+	}
+	if want := time.Date(2026, 5, 1, 12, 0, 1, 500_000_000, time.UTC); !cursor.Watermark.Equal(want) {
+		t.Errorf("Watermark = %v, want %v", cursor.Watermark, want)
+	}
+
+	// A restarted consumer resumes from the saved cursor
+	resumed := &eventLogServer{responses: []string{logsResponse("evt_1", "evt_2", "evt_3")}}
+	service = newTailTestService(t, resumed)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	tail, err = service.TailEvents(ctx, "0xd3adb00b5", models.EventLogFilters{}, TailOptions{PollInterval: time.Millisecond, CursorStore: store})
+	if err != nil {
+		t.Fatalf("TailEvents() error = %v", err)
+	}
+	if ids := collectEvents(t, tail, 1); ids[0] != "evt_3" {
+		t.Errorf("resumed event = %v, want evt_3", ids[0])
+	}
+}
+
+func TestConsoleService_TailEvents_StopsOnClientError(t *testing.T) {
+	service := newTailTestService(t, &eventLogServer{responses: []string{"404"}})
+
+	tail, err := service.TailEvents(context.Background(), "missing", models.EventLogFilters{}, TailOptions{PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("TailEvents() error = %v", err)
+	}
+
+	select {
+	case _, ok := <-tail.Events():
+		if ok {
+			t.Fatal("unexpected event")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tail did not stop on a 404")
+	}
+	if tail.Err() == nil {
+		t.Error("Err() = nil, want the 404 error")
+	}
+}
+
+func TestTailStatePrunes(t *testing.T) {
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	state := newTailState(&EventCursor{Watermark: start})
+	for i := 0; i < 100; i++ {
+		state.deliver(fmt.Sprint(i), start.Add(time.Duration(i)*time.Second))
+		state.prune()
+	}
+	if len(state.seen) != 1 {
+		t.Errorf("seen = %d events, want only those of the last second", len(state.seen))
+	}
+}
+
+func TestEventKey(t *testing.T) {
+	event := models.Event{ID: float64(1234567)}
+	if got, want := eventKey(event), event.Normalize().Key(); got != "1234567" || got != want {
+		t.Errorf("eventKey() = %q, want 1234567 like NormalizedEvent.Key() %q", got, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := time.Second, 10*time.Second
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := backoff(base, max, i+1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/internal/fetch"
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// Metadata keys recording the lineage of a reissued pass
+const (
+	// MetadataReissuedFrom is the ID of the card a pass replaced
+	MetadataReissuedFrom = "reissued_from"
+	// MetadataOriginalCardID is the first card of a chain of reissues
+	MetadataOriginalCardID = "original_card_id"
+	// MetadataReissueCount counts the reissues since the original card
+	MetadataReissueCount = "reissue_count"
+	// MetadataReissuedAt is when the pass was reissued, in RFC 3339
+	MetadataReissuedAt = "reissued_at"
+	// MetadataReissueReason is ReissueOptions.Reason
+	MetadataReissueReason = "reissue_reason"
+)
+
+// CardNumberAllocator hands out card numbers and site codes for new passes
+type CardNumberAllocator interface {
+	// Allocate reserves an unused card number and site code for a template
+	Allocate(ctx context.Context, templateID string) (cardNumber, siteCode string, err error)
+	// Release frees a reserved card number that was not used
+	Release(ctx context.Context, templateID, cardNumber, siteCode string) error
+}
+
+// RetireAction is how Reissue retires the old card
+type RetireAction string
+
+const (
+	RetireDelete  RetireAction = "delete"
+	RetireSuspend RetireAction = "suspend"
+)
+
+// ReissueOptions controls how a pass is reissued
+type ReissueOptions struct {
+	// RotateCardNumber gives the replacement a new card number and site code
+	// from Allocator. Otherwise the old card's are carried over and the old
+	// card is deleted before the replacement is provisioned.
+	RotateCardNumber bool
+	// Allocator is typically a *cardnumber.Allocator
+	Allocator CardNumberAllocator
+	// Retire is RetireDelete (the default) or RetireSuspend, which keeps the
+	// old card suspended and requires RotateCardNumber
+	Retire RetireAction
+	// Reason is recorded in the replacement's metadata, such as "lost phone"
+	Reason string
+}
+
+// ReissueResult holds the cards involved in a reissue
+type ReissueResult struct {
+	// Previous is the old card as read before it was retired
+	Previous *models.Card
+	// Card is the replacement
+	Card *models.CardProvisionResponse
+}
+
+// Reissue replaces a pass, for example after a phone is lost. The
+// replacement is provisioned with the old card's holder, dates, photo and
+// metadata, and its metadata records the card it replaced and the original
+// card of the chain.
+//
+// With RotateCardNumber the old card is suspended first so it stops working
+// right away, the replacement is provisioned and the old card is retired.
+// If provisioning fails the old card stays suspended and the allocated card
+// number is released. If retiring fails the result is returned with the
+// error, as the replacement already exists.
+//
+// Otherwise the replacement carries over the old card's number, and two
+// live cards must never share one, so the old card is deleted before the
+// replacement is provisioned and RetireSuspend is refused. If provisioning
+// then fails, the result holds the deleted card so it can be provisioned
+// again.
+func (s *AccessCardsService) Reissue(ctx context.Context, cardID string, opts ReissueOptions) (*ReissueResult, error) {
+	switch opts.Retire {
+	case "":
+		opts.Retire = RetireDelete
+	case RetireDelete, RetireSuspend:
+	default:
+		return nil, fmt.Errorf("Retire must be %s or %s, got %q", RetireDelete, RetireSuspend, opts.Retire)
+	}
+	if opts.RotateCardNumber && opts.Allocator == nil {
+		return nil, errors.New("an Allocator is required to rotate the card number")
+	}
+
+	old, err := s.Get(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if old.State == "deleted" {
+		return nil, fmt.Errorf("card %s is deleted", cardID)
+	}
+
+	carryOver := !opts.RotateCardNumber && old.CardNumber != ""
+	if carryOver && opts.Retire == RetireSuspend {
+		return nil, fmt.Errorf("card %s cannot be kept suspended without RotateCardNumber: its replacement would share card number %s", old.ID, old.CardNumber)
+	}
+
+	params, err := s.reissueParams(ctx, old, opts.Reason)
+	if err != nil {
+		return nil, err
+	}
+	if carryOver {
+		if err := s.Delete(ctx, old.ID); err != nil {
+			return nil, err
+		}
+		card, err := s.Provision(ctx, params)
+		if err != nil {
+			return &ReissueResult{Previous: old}, fmt.Errorf("card %s was deleted but not replaced: %w", old.ID, err)
+		}
+		return &ReissueResult{Previous: old, Card: card}, nil
+	}
+	if opts.RotateCardNumber {
+		params.CardNumber, params.SiteCode, err = opts.Allocator.Allocate(ctx, old.CardTemplateID)
+		if err != nil {
+			return nil, fmt.Errorf("error allocating card number: %w", err)
+		}
+	}
+
+	if old.State != "suspended" {
+		if err := s.Suspend(ctx, old.ID); err != nil {
+			s.releaseCardNumber(ctx, opts, params)
+			return nil, err
+		}
+	}
+
+	card, err := s.Provision(ctx, params)
+	if err != nil {
+		s.releaseCardNumber(ctx, opts, params)
+		return nil, fmt.Errorf("card %s was suspended but not replaced: %w", old.ID, err)
+	}
+	result := &ReissueResult{Previous: old, Card: card}
+
+	if opts.Retire == RetireDelete {
+		if err := s.Delete(ctx, old.ID); err != nil {
+			return result, fmt.Errorf("card %s was replaced by %s but not deleted: %w", old.ID, card.ID, err)
+		}
+	}
+	return result, nil
+}
+
+// reissueParams copies a card into the parameters of its replacement
+func (s *AccessCardsService) reissueParams(ctx context.Context, old *models.Card, reason string) (models.ProvisionParams, error) {
+	// Photos served by URL are downloaded
+	photo, err := fetch.Base64(ctx, s.client.HTTPClient, old.EmployeePhoto)
+	if err != nil {
+		return models.ProvisionParams{}, fmt.Errorf("error copying photo: %w", err)
+	}
+
+	metadata := make(map[string]interface{}, len(old.Metadata)+5)
+	for key, value := range old.Metadata {
+		metadata[key] = value
+	}
+	delete(metadata, MetadataReissueReason)
+
+	original, _ := old.Metadata[MetadataOriginalCardID].(string)
+	if original == "" {
+		original = old.ID
+	}
+	count := 0
+	if n, ok := old.Metadata[MetadataReissueCount].(float64); ok {
+		count = int(n)
+	}
+	metadata[MetadataReissuedFrom] = old.ID
+	metadata[MetadataOriginalCardID] = original
+	metadata[MetadataReissueCount] = count + 1
+	metadata[MetadataReissuedAt] = time.Now().UTC().Format(time.RFC3339)
+	if reason != "" {
+		metadata[MetadataReissueReason] = reason
+	}
+
+	return models.ProvisionParams{
+		CardTemplateID: old.CardTemplateID,
+		EmployeeID:     old.EmployeeID,
+		CardNumber:     old.CardNumber,
+		SiteCode:       old.SiteCode,
+		FullName:       old.FullName,
+		Email:          old.Email,
+		PhoneNumber:    old.PhoneNumber,
+		Classification: old.Classification,
+		Title:          old.Title,
+		StartDate:      old.StartDate,
+		ExpirationDate: old.ExpirationDate,
+		EmployeePhoto:  photo,
+		Temporary:      old.Temporary,
+		Metadata:       metadata,
+	}, nil
+}
+
+func (s *AccessCardsService) releaseCardNumber(ctx context.Context, opts ReissueOptions, params models.ProvisionParams) {
+	if opts.RotateCardNumber {
+		opts.Allocator.Release(context.WithoutCancel(ctx), params.CardTemplateID, params.CardNumber, params.SiteCode)
+	}
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// reissueServer serves one card and records the requests made
+type reissueServer struct {
+	mu            sync.Mutex
+	card          models.Card
+	provisioned   *models.ProvisionParams
+	failProvision bool
+	requests      []string
+}
+
+func (s *reissueServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Path == "/photo.jpg":
+		w.Write([]byte("jpeg bytes"))
+		return
+	case r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(s.card)
+		return
+	}
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	if r.Method == http.MethodPost && r.URL.Path == "/v1/key-cards" {
+		if s.failProvision {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"message": "card number taken"}`))
+			return
+		}
+		var params models.ProvisionParams
+		json.NewDecoder(r.Body).Decode(&params)
+		s.provisioned = &params
+		w.Write([]byte(`{"id": "0xn3w", "state": "active", "install_url": "https://accessgrid.com/install/0xn3w"}`))
+		return
+	}
+	w.Write([]byte(`{}`))
+}
+
+// fakeAllocator hands out sequential card numbers
+type fakeAllocator struct {
+	next     int
+	released []string
+}
+
+func (a *fakeAllocator) Allocate(ctx context.Context, templateID string) (string, string, error) {
+	a.next++
+	return fmt.Sprint(a.next), "42", nil
+}
+
+func (a *fakeAllocator) Release(ctx context.Context, templateID, cardNumber, siteCode string) error {
+	a.released = append(a.released, siteCode+":"+cardNumber)
+	return nil
+}
+
+func setupReissueTestServer(t *testing.T, card models.Card) (*reissueServer, *AccessCardsService) {
+	t.Helper()
+	server := &reissueServer{card: card}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	if card.EmployeePhoto == "url" {
+		server.card.EmployeePhoto = ts.URL + "/photo.jpg"
+	}
+	c, err := client.NewClient("test-account", "test-secret", client.WithBaseURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, NewAccessCardsService(c)
+}
+
+func reissueCard() models.Card {
+	return models.Card{
+		ID:             "0xc4rd1d",
+		CardTemplateID: "0xd3adb00b5",
+		EmployeeID:     "E100",
+		CardNumber:     "12345",
+		SiteCode:       "7",
+		FullName:       "Employee name",
+		Email:          "employee@example.com",
+		Title:          "Engineer",
+		StartDate:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExpirationDate: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		EmployeePhoto:  "url",
+		State:          "active",
+		Metadata:       map[string]interface{}{"department": "R&D"},
+	}
+}
+
+func TestAccessCardsService_Reissue(t *testing.T) {
+	server, service := setupReissueTestServer(t, reissueCard())
+
+	result, err := service.Reissue(context.Background(), "0xc4rd1d", ReissueOptions{Reason: "lost phone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Card.ID != "0xn3w" || result.Previous.ID != "0xc4rd1d" {
+		t.Errorf("result = %+v", result)
+	}
+
+	// The card number is carried over, so the old card goes first
+	want := "POST /v1/key-cards/0xc4rd1d/delete,POST /v1/key-cards"
+	if got := strings.Join(server.requests, ","); got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+
+	params := server.provisioned
+	if params.CardNumber != "12345" || params.SiteCode != "7" || params.EmployeeID != "E100" || params.Title != "Engineer" {
+		t.Errorf("params = %+v", params)
+	}
+	if !params.ExpirationDate.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expiration = %v", params.ExpirationDate)
+	}
+	if params.EmployeePhoto != base64.StdEncoding.EncodeToString([]byte("jpeg bytes")) {
+		t.Errorf("photo = %q", params.EmployeePhoto)
+	}
+	for key, want := range map[string]interface{}{
+		"department":           "R&D",
+		MetadataReissuedFrom:   "0xc4rd1d",
+		MetadataOriginalCardID: "0xc4rd1d",
+		MetadataReissueCount:   float64(1),
+		MetadataReissueReason:  "lost phone",
+	} {
+		if params.Metadata[key] != want {
+			t.Errorf("metadata %s = %v, want %v", key, params.Metadata[key], want)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, params.Metadata[MetadataReissuedAt].(string)); err != nil {
+		t.Errorf("reissued_at: %v", err)
+	}
+}
+
+func TestAccessCardsService_ReissueRotatesCardNumber(t *testing.T) {
+	card := reissueCard()
+	card.State = "suspended"
+	card.Metadata = map[string]interface{}{
+		MetadataReissuedFrom:   "0xf1rst",
+		MetadataOriginalCardID: "0x0r1g",
+		MetadataReissueCount:   float64(2),
+		MetadataReissueReason:  "broken phone",
+	}
+	server, service := setupReissueTestServer(t, card)
+	allocator := &fakeAllocator{next: 99}
+
+	_, err := service.Reissue(context.Background(), "0xc4rd1d", ReissueOptions{
+		RotateCardNumber: true,
+		Allocator:        allocator,
+		Retire:           RetireSuspend,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Already suspended and kept: only the replacement is provisioned
+	if got := strings.Join(server.requests, ","); got != "POST /v1/key-cards" {
+		t.Errorf("requests = %s", got)
+	}
+	params := server.provisioned
+	if params.CardNumber != "100" || params.SiteCode != "42" {
+		t.Errorf("card number = %s/%s, want 42/100", params.SiteCode, params.CardNumber)
+	}
+	if params.Metadata[MetadataOriginalCardID] != "0x0r1g" || params.Metadata[MetadataReissueCount] != float64(3) {
+		t.Errorf("lineage = %v", params.Metadata)
+	}
+	if _, ok := params.Metadata[MetadataReissueReason]; ok {
+		t.Error("the previous reissue reason was carried over")
+	}
+}
+
+func TestAccessCardsService_ReissueProvisionFails(t *testing.T) {
+	server, service := setupReissueTestServer(t, reissueCard())
+	server.failProvision = true
+	allocator := &fakeAllocator{}
+
+	_, err := service.Reissue(context.Background(), "0xc4rd1d", ReissueOptions{RotateCardNumber: true, Allocator: allocator})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an API error", err)
+	}
+
+	// The old card stays suspended and the number is released
+	want := "POST /v1/key-cards/0xc4rd1d/suspend,POST /v1/key-cards"
+	if got := strings.Join(server.requests, ","); got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+	if strings.Join(allocator.released, ",") != "42:1" {
+		t.Errorf("released = %v", allocator.released)
+	}
+}
+
+func TestAccessCardsService_ReissueCarriedOverNumberProvisionFails(t *testing.T) {
+	server, service := setupReissueTestServer(t, reissueCard())
+	server.failProvision = true
+
+	result, err := service.Reissue(context.Background(), "0xc4rd1d", ReissueOptions{})
+	if err == nil || !strings.Contains(err.Error(), "was deleted but not replaced") {
+		t.Fatalf("err = %v", err)
+	}
+	if result == nil || result.Previous.ID != "0xc4rd1d" || result.Card != nil {
+		t.Errorf("result = %+v", result)
+	}
+	want := "POST /v1/key-cards/0xc4rd1d/delete,POST /v1/key-cards"
+	if got := strings.Join(server.requests, ","); got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+}
+
+func TestAccessCardsService_ReissueValidates(t *testing.T) {
+	_, service := setupReissueTestServer(t, reissueCard())
+	if _, err := service.Reissue(context.Background(), "0xc4rd1d", ReissueOptions{RotateCardNumber: true}); err == nil {
+		t.Error("expected an error rotating without an allocator")
+	}
+	if _, err := service.Reissue(context.Background(), "0xc4rd1d", ReissueOptions{Retire: "unlink"}); err == nil {
+		t.Error("expected an error for an unknown retire action")
+	}
+
+	// Keeping the old card would leave two live cards with one number
+	server, service := setupReissueTestServer(t, reissueCard())
+	if _, err := service.Reissue(context.Background(), "0xc4rd1d", ReissueOptions{Retire: RetireSuspend}); err == nil || !strings.Contains(err.Error(), "share card number 12345") {
+		t.Errorf("err = %v, want a shared card number error", err)
+	}
+	if len(server.requests) != 0 {
+		t.Errorf("requests = %v", server.requests)
+	}
+}
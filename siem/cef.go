@@ -0,0 +1,86 @@
+// Package siem forwards access pass events to security tooling as CEF
+// messages over syslog.
+//
+// Events are formatted as ArcSight Common Event Format bodies inside RFC
+// 5424 syslog messages and sent over UDP, TCP or TLS. A Forwarder buffers
+// events and retries delivery while the collector is unavailable.
+package siem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+const (
+	cefVendor  = "AccessGrid"
+	cefProduct = "AccessGrid Go SDK"
+	cefVersion = "1.0"
+)
+
+// cefSeverity rates events that remove or block access above the rest
+func cefSeverity(eventType models.EventType) int {
+	switch eventType {
+	case models.EventTypeSuspend, models.EventTypeUnlink, models.EventTypeDelete:
+		return 6
+	default:
+		return 3
+	}
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// FormatCEF formats an event as a CEF message
+func FormatCEF(event models.NormalizedEvent) string {
+	eventType := string(event.Type)
+	if eventType == "" {
+		eventType = "unknown"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeaderEscaper.Replace(cefVendor),
+		cefHeaderEscaper.Replace(cefProduct),
+		cefHeaderEscaper.Replace(cefVersion),
+		cefHeaderEscaper.Replace(eventType),
+		cefHeaderEscaper.Replace("Access pass "+eventType),
+		cefSeverity(event.Type),
+	)
+
+	var extensions []string
+	add := func(key, value string) {
+		if value != "" {
+			extensions = append(extensions, key+"="+cefExtensionEscaper.Replace(value))
+		}
+	}
+	add("externalId", string(event.ID))
+	if !event.Timestamp.IsZero() {
+		add("rt", strconv.FormatInt(event.Timestamp.UnixMilli(), 10))
+	}
+	add("suser", event.UserID)
+	add("src", event.IPAddress)
+	add("requestClientApplication", event.UserAgent)
+	if event.CardID != "" {
+		add("cs1Label", "cardId")
+		add("cs1", event.CardID)
+	}
+	if event.TemplateID != "" {
+		add("cs2Label", "templateId")
+		add("cs2", event.TemplateID)
+	}
+	if event.Device != "" {
+		add("cs3Label", "device")
+		add("cs3", event.Device)
+	}
+	if text, ok := event.Details.(*models.TextEventDetails); ok {
+		add("msg", text.Text)
+	}
+
+	b.WriteString(strings.Join(extensions, " "))
+	return b.String()
+}
@@ -0,0 +1,233 @@
+package siem
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// Transport networks supported by a Forwarder
+const (
+	NetworkUDP = "udp"
+	NetworkTCP = "tcp"
+	NetworkTLS = "tls"
+)
+
+var (
+	// ErrBufferFull is returned by Forward when the collector has fallen
+	// too far behind
+	ErrBufferFull = errors.New("siem: forward buffer is full")
+	// ErrClosed is returned by Forward after Close
+	ErrClosed = errors.New("siem: forwarder is closed")
+)
+
+// Config configures a Forwarder
+type Config struct {
+	// Network is udp, tcp or tls
+	Network string
+	// Address is the collector's host:port
+	Address string
+	// TLSConfig is used for the tls network
+	TLSConfig *tls.Config
+	// Facility defaults to FacilityLogAudit
+	Facility int
+	// Hostname defaults to the local hostname
+	Hostname string
+	// AppName defaults to "accessgrid"
+	AppName string
+	// BufferSize is the number of events held while the collector is
+	// unavailable. Defaults to 1000.
+	BufferSize int
+	// RetryInterval is the first delay after a failed send, doubled on
+	// each retry up to MaxBackoff. Defaults to 1 second and 30 seconds.
+	RetryInterval time.Duration
+	MaxBackoff    time.Duration
+	// DialTimeout defaults to 10 seconds
+	DialTimeout time.Duration
+	// OnError is called with each failed send before it is retried
+	OnError func(error)
+}
+
+// Forwarder sends events to a syslog collector in the background
+type Forwarder struct {
+	config Config
+	queue  chan string
+	abort  chan struct{}
+	done   chan struct{}
+	// conn is only used by the run goroutine
+	conn net.Conn
+
+	mu        sync.Mutex
+	closed    bool
+	abortOnce sync.Once
+}
+
+// NewForwarder starts a forwarder. The collector is dialed on the first
+// send, so it does not need to be reachable yet.
+func NewForwarder(config Config) (*Forwarder, error) {
+	switch config.Network {
+	case NetworkUDP, NetworkTCP, NetworkTLS:
+	default:
+		return nil, fmt.Errorf("unsupported network %q", config.Network)
+	}
+	if config.Address == "" {
+		return nil, errors.New("collector address is required")
+	}
+	if config.Facility == 0 {
+		config.Facility = FacilityLogAudit
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.AppName == "" {
+		config.AppName = "accessgrid"
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = 1000
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 10 * time.Second
+	}
+
+	f := &Forwarder{
+		config: config,
+		queue:  make(chan string, config.BufferSize),
+		abort:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go f.run()
+	return f, nil
+}
+
+// Forward queues an event for delivery. It does not block; if the buffer
+// is full the event is dropped and ErrBufferFull is returned.
+func (f *Forwarder) Forward(event models.NormalizedEvent) error {
+	message := FormatSyslog(event, f.config.Facility, f.config.Hostname, f.config.AppName)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrClosed
+	}
+	select {
+	case f.queue <- message:
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
+
+// ForwardEvent queues an event decoded into the original Event struct,
+// such as one received by a webhook
+func (f *Forwarder) ForwardEvent(event models.Event) error {
+	return f.Forward(event.Normalize())
+}
+
+// Close stops accepting events and waits for the buffer to drain. If ctx
+// ends first, undelivered events are dropped and ctx's error is returned.
+func (f *Forwarder) Close(ctx context.Context) error {
+	f.mu.Lock()
+	if !f.closed {
+		f.closed = true
+		close(f.queue)
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return nil
+	case <-ctx.Done():
+		f.abortOnce.Do(func() { close(f.abort) })
+		<-f.done
+		return ctx.Err()
+	}
+}
+
+func (f *Forwarder) run() {
+	defer close(f.done)
+	defer f.disconnect()
+
+	for message := range f.queue {
+		if !f.deliver(message) {
+			return
+		}
+	}
+}
+
+// deliver sends a message, retrying until it succeeds or the forwarder is
+// aborted
+func (f *Forwarder) deliver(message string) bool {
+	delay := f.config.RetryInterval
+	for {
+		err := f.send(message)
+		if err == nil {
+			return true
+		}
+		f.disconnect()
+		if f.config.OnError != nil {
+			f.config.OnError(err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-f.abort:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		delay *= 2
+		if delay > f.config.MaxBackoff {
+			delay = f.config.MaxBackoff
+		}
+	}
+}
+
+func (f *Forwarder) send(message string) error {
+	if f.conn == nil {
+		conn, err := f.dial()
+		if err != nil {
+			return fmt.Errorf("error connecting to %s: %w", f.config.Address, err)
+		}
+		f.conn = conn
+	}
+
+	// UDP sends one message per datagram; stream transports use octet
+	// counting framing (RFC 6587, RFC 5425)
+	frame := message
+	if f.config.Network != NetworkUDP {
+		frame = fmt.Sprintf("%d %s", len(message), message)
+	}
+	f.conn.SetWriteDeadline(time.Now().Add(f.config.DialTimeout))
+	if _, err := f.conn.Write([]byte(frame)); err != nil {
+		return fmt.Errorf("error sending to %s: %w", f.config.Address, err)
+	}
+	return nil
+}
+
+func (f *Forwarder) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: f.config.DialTimeout}
+	if f.config.Network == NetworkTLS {
+		return tls.DialWithDialer(dialer, "tcp", f.config.Address, f.config.TLSConfig)
+	}
+	return dialer.Dial(f.config.Network, f.config.Address)
+}
+
+func (f *Forwarder) disconnect() {
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+}
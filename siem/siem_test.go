@@ -0,0 +1,220 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+func testEvent(t *testing.T) models.NormalizedEvent {
+	t.Helper()
+	var event models.NormalizedEvent
+	data := `{"id": "evt_1", "type": "suspend", "user_id": "usr|1", "card_id": "0xc4rd1d", "template_id": "0xd3adb00b5",
+		"ip_address": "10.0.0.1", "timestamp": "2026-05-01T12:00:00Z", "details": "reason=lost\nbadge"}`
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestFormatCEF(t *testing.T) {
+	got := FormatCEF(testEvent(t))
+	want := `CEF:0|AccessGrid|AccessGrid Go SDK|1.0|suspend|Access pass suspend|6|` +
+		`externalId=evt_1 rt=1777636800000 suser=usr|1 src=10.0.0.1 cs1Label=cardId cs1=0xc4rd1d ` +
+		`cs2Label=templateId cs2=0xd3adb00b5 msg=reason\=lost\nbadge`
+	if got != want {
+		t.Errorf("FormatCEF() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+var syslogPattern = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) (\S+) - (\S+) - (CEF:0\|.*)$`)
+
+func TestFormatSyslog(t *testing.T) {
+	message := FormatSyslog(testEvent(t), FacilityLogAudit, "collector host", "")
+	match := syslogPattern.FindStringSubmatch(message)
+	if match == nil {
+		t.Fatalf("FormatSyslog() = %q is not RFC 5424", message)
+	}
+	if match[1] != strconv.Itoa(FacilityLogAudit*8+severityWarning) {
+		t.Errorf("PRI = %s", match[1])
+	}
+	if match[2] != "2026-05-01T12:00:00.000000Z" {
+		t.Errorf("TIMESTAMP = %s", match[2])
+	}
+	if match[3] != "collector_host" || match[4] != "-" || match[5] != "suspend" {
+		t.Errorf("HOSTNAME, APP-NAME, MSGID = %s, %s, %s", match[3], match[4], match[5])
+	}
+}
+
+func newTestForwarder(t *testing.T, config Config) *Forwarder {
+	t.Helper()
+	config.Hostname = "test-host"
+	config.RetryInterval = 10 * time.Millisecond
+	f, err := NewForwarder(config)
+	if err != nil {
+		t.Fatalf("NewForwarder() error = %v", err)
+	}
+	return f
+}
+
+func closeForwarder(t *testing.T, f *Forwarder) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := f.Close(ctx); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestForwarder_UDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	f := newTestForwarder(t, Config{Network: NetworkUDP, Address: listener.LocalAddr().String()})
+	if err := f.Forward(testEvent(t)); err != nil {
+		t.Fatalf("Forward() error = %v", err)
+	}
+	closeForwarder(t, f)
+
+	buf := make([]byte, 4096)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := listener.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("no datagram received: %v", err)
+	}
+	if !syslogPattern.Match(buf[:n]) {
+		t.Errorf("datagram = %q", buf[:n])
+	}
+}
+
+// readFrames reads octet-counted syslog frames from a stream listener
+func readFrames(t *testing.T, listener net.Listener, n int) []string {
+	t.Helper()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	reader := bufio.NewReader(conn)
+	var frames []string
+	for len(frames) < n {
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			t.Fatalf("error reading frame length: %v", err)
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(length))
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			t.Fatalf("error reading frame: %v", err)
+		}
+		frames = append(frames, string(frame))
+	}
+	return frames
+}
+
+func TestForwarder_TCPRetriesUntilCollectorIsUp(t *testing.T) {
+	// Reserve a port, then free it so the first sends fail
+	reserved, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := reserved.Addr().String()
+	reserved.Close()
+
+	failures := make(chan error, 100)
+	f := newTestForwarder(t, Config{
+		Network: NetworkTCP,
+		Address: address,
+		OnError: func(err error) { failures <- err },
+	})
+	f.Forward(testEvent(t))
+	f.Forward(testEvent(t))
+
+	select {
+	case <-failures:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a failed send to be reported")
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("port %s was taken: %v", address, err)
+	}
+	defer listener.Close()
+
+	frames := readFrames(t, listener, 2)
+	for _, frame := range frames {
+		if !syslogPattern.MatchString(frame) {
+			t.Errorf("frame = %q", frame)
+		}
+	}
+	closeForwarder(t, f)
+}
+
+func TestForwarder_TLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	defer server.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	f := newTestForwarder(t, Config{
+		Network:   NetworkTLS,
+		Address:   listener.Addr().String(),
+		TLSConfig: &tls.Config{RootCAs: roots},
+	})
+	f.ForwardEvent(models.Event{ID: 42, Type: "install", Timestamp: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)})
+
+	frames := readFrames(t, listener, 1)
+	if !strings.Contains(frames[0], "externalId=42") {
+		t.Errorf("frame = %q", frames[0])
+	}
+	closeForwarder(t, f)
+}
+
+func TestForwarder_BufferFullAndClosed(t *testing.T) {
+	f := newTestForwarder(t, Config{Network: NetworkTCP, Address: "127.0.0.1:1", BufferSize: 1})
+
+	var full bool
+	for i := 0; i < 10; i++ {
+		if err := f.Forward(testEvent(t)); err == ErrBufferFull {
+			full = true
+			break
+		}
+	}
+	if !full {
+		t.Error("expected ErrBufferFull")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := f.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close() error = %v, want DeadlineExceeded", err)
+	}
+	if err := f.Forward(testEvent(t)); err != ErrClosed {
+		t.Errorf("Forward() after Close error = %v, want ErrClosed", err)
+	}
+}
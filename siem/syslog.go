@@ -0,0 +1,67 @@
+package siem
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// Syslog facilities commonly used for security events
+const (
+	FacilityAuthPriv = 10
+	FacilityLogAudit = 13
+	FacilityLocal0   = 16
+)
+
+// Syslog severities used for access pass events
+const (
+	severityWarning = 4
+	severityNotice  = 5
+)
+
+// syslogSeverity matches the syslog severity to the CEF severity
+func syslogSeverity(eventType models.EventType) int {
+	if cefSeverity(eventType) > 5 {
+		return severityWarning
+	}
+	return severityNotice
+}
+
+// header replaces an empty or invalid RFC 5424 header field with the nil
+// value and truncates it to its maximum length
+func header(value string, max int) string {
+	if value == "" {
+		return "-"
+	}
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(value) > max {
+		value = value[:max]
+	}
+	return value
+}
+
+// FormatSyslog formats an event as an RFC 5424 syslog message with a CEF
+// body. The message ID is the event type; the timestamp is the event's,
+// or now if it has none.
+func FormatSyslog(event models.NormalizedEvent, facility int, hostname, appName string) string {
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s - %s - %s",
+		facility*8+syslogSeverity(event.Type),
+		timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		header(hostname, 255),
+		header(appName, 48),
+		header(string(event.Type), 32),
+		FormatCEF(event),
+	)
+}
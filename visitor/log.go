@@ -0,0 +1,96 @@
+package visitor
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// LogEntry is one visit of a daily log
+type LogEntry struct {
+	Visit
+	// Installed is when the pass was first installed on a device that day
+	Installed *time.Time `json:"installed,omitempty"`
+	// Events are the pass's events of the day, oldest first
+	Events []models.NormalizedEvent `json:"events,omitempty"`
+}
+
+// DailyLog lists the visits of one day
+type DailyLog struct {
+	Day     time.Time  `json:"day"`
+	Entries []LogEntry `json:"entries"`
+}
+
+// DailyLog returns the visits whose window overlaps the day containing
+// day, in day's location, with their events of that day
+func (s *Service) DailyLog(ctx context.Context, day time.Time) (*DailyLog, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+
+	visits, err := s.Visits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byCard := map[string][]models.NormalizedEvent{}
+	if s.console != nil {
+		startUTC, endUTC := start.UTC(), end.UTC()
+		events, err := s.console.NormalizedEventLog(ctx, s.config.CardTemplateID, models.EventLogFilters{StartDate: &startUTC, EndDate: &endUTC})
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			if event.Timestamp.Before(start) || !event.Timestamp.Before(end) {
+				continue
+			}
+			byCard[event.CardID] = append(byCard[event.CardID], event)
+		}
+	}
+
+	log := &DailyLog{Day: start, Entries: []LogEntry{}}
+	for _, visit := range visits {
+		if !visit.Start.Before(end) || !visit.End.After(start) {
+			continue
+		}
+		entry := LogEntry{Visit: visit, Events: byCard[visit.CardID]}
+		sort.SliceStable(entry.Events, func(i, j int) bool {
+			return entry.Events[i].Timestamp.Before(entry.Events[j].Timestamp)
+		})
+		for _, event := range entry.Events {
+			if event.Type == models.EventTypeInstall {
+				installed := event.Timestamp
+				entry.Installed = &installed
+				break
+			}
+		}
+		log.Entries = append(log.Entries, entry)
+	}
+	return log, nil
+}
+
+// WriteCSV writes one row per visit with a header row. Times are in the
+// location of the log's day.
+func (l *DailyLog) WriteCSV(w io.Writer) error {
+	loc := l.Day.Location()
+	format := func(t *time.Time) string {
+		if t == nil || t.IsZero() {
+			return ""
+		}
+		return t.In(loc).Format(time.RFC3339)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"visitor", "company", "email", "host_employee_id", "host_name", "purpose", "start", "end", "installed", "state", "card_id"})
+	for _, e := range l.Entries {
+		cw.Write([]string{
+			e.FullName, e.Company, e.Email, e.HostEmployeeID, e.HostName, e.Purpose,
+			format(&e.Start), format(&e.End), format(e.Installed), e.State, e.CardID,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
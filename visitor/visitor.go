@@ -0,0 +1,313 @@
+// Package visitor issues temporary passes to visitors.
+//
+// A visitor pass is a temporary pass valid for a visit window, with the
+// visitor's host employee recorded in its metadata. The Service issues
+// passes, lists the visitors on site, expires passes once their window
+// ends and produces a daily visitor log from the cards and the event log.
+package visitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/schedule"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+// Metadata keys of visitor passes
+const (
+	MetadataVisitor        = "visitor"
+	MetadataHostEmployeeID = "host_employee_id"
+	MetadataHostName       = "host_name"
+	MetadataCompany        = "company"
+	MetadataPurpose        = "purpose"
+	MetadataVisitStart     = "visit_start"
+	MetadataVisitEnd       = "visit_end"
+)
+
+// Classification is set on visitor passes
+const Classification = "visitor"
+
+// Params describes a visit
+type Params struct {
+	FullName    string
+	Email       string
+	PhoneNumber string
+	Company     string
+	Purpose     string
+	// HostEmployeeID is the employee receiving the visitor. Required.
+	HostEmployeeID string
+	HostName       string
+	// Start defaults to now
+	Start time.Time
+	End   time.Time
+}
+
+// Visit is a visitor pass
+type Visit struct {
+	CardID         string    `json:"card_id"`
+	FullName       string    `json:"full_name"`
+	Email          string    `json:"email,omitempty"`
+	PhoneNumber    string    `json:"phone_number,omitempty"`
+	Company        string    `json:"company,omitempty"`
+	Purpose        string    `json:"purpose,omitempty"`
+	HostEmployeeID string    `json:"host_employee_id"`
+	HostName       string    `json:"host_name,omitempty"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	State          string    `json:"state"`
+	InstallURL     string    `json:"install_url,omitempty"`
+}
+
+// OnSite reports whether the visit window contains t and the pass is
+// usable
+func (v *Visit) OnSite(t time.Time) bool {
+	return v.State == "active" && !t.Before(v.Start) && t.Before(v.End)
+}
+
+// Config configures a Service
+type Config struct {
+	// CardTemplateID is the template of visitor passes
+	CardTemplateID string
+	// MaxDuration caps a visit window. Defaults to 24 hours.
+	MaxDuration time.Duration
+	// ExpiredAction is applied to passes after their window: ActionSuspend
+	// (the default) or ActionDelete
+	ExpiredAction schedule.Action
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Service manages visitor passes
+type Service struct {
+	cards   *services.AccessCardsService
+	console *services.ConsoleService
+	config  Config
+}
+
+// New creates a visitor service. console is used for the daily log's
+// events and may be nil.
+func New(cards *services.AccessCardsService, console *services.ConsoleService, config Config) (*Service, error) {
+	if config.CardTemplateID == "" {
+		return nil, errors.New("CardTemplateID is required")
+	}
+	if config.MaxDuration <= 0 {
+		config.MaxDuration = 24 * time.Hour
+	}
+	switch config.ExpiredAction {
+	case "":
+		config.ExpiredAction = schedule.ActionSuspend
+	case schedule.ActionSuspend, schedule.ActionDelete:
+	default:
+		return nil, fmt.Errorf("ExpiredAction must be %s or %s, got %q", schedule.ActionSuspend, schedule.ActionDelete, config.ExpiredAction)
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Service{cards: cards, console: console, config: config}, nil
+}
+
+// Issue provisions a temporary pass for the visit window. A pass for a
+// later visit is flagged to activate at its start, which a
+// schedule.Scheduler carries out.
+func (s *Service) Issue(ctx context.Context, params Params) (*Visit, error) {
+	now := s.config.Now()
+	if params.FullName == "" {
+		return nil, errors.New("visitor name is required")
+	}
+	if params.HostEmployeeID == "" {
+		return nil, errors.New("host employee ID is required")
+	}
+	if params.Start.IsZero() {
+		params.Start = now
+	}
+	if !params.End.After(params.Start) {
+		return nil, errors.New("visit must end after it starts")
+	}
+	if !params.End.After(now) {
+		return nil, errors.New("visit has already ended")
+	}
+	if params.End.Sub(params.Start) > s.config.MaxDuration {
+		return nil, fmt.Errorf("visit of %s is longer than the %s allowed", params.End.Sub(params.Start), s.config.MaxDuration)
+	}
+
+	metadata := map[string]interface{}{
+		MetadataVisitor:        true,
+		MetadataHostEmployeeID: params.HostEmployeeID,
+		MetadataVisitStart:     params.Start.UTC().Format(time.RFC3339),
+		MetadataVisitEnd:       params.End.UTC().Format(time.RFC3339),
+	}
+	for key, value := range map[string]string{MetadataHostName: params.HostName, MetadataCompany: params.Company, MetadataPurpose: params.Purpose} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	if params.Start.After(now) {
+		metadata[schedule.MetadataActivateOnStart] = true
+	}
+
+	card, err := s.cards.Provision(ctx, models.ProvisionParams{
+		CardTemplateID: s.config.CardTemplateID,
+		FullName:       params.FullName,
+		Email:          params.Email,
+		PhoneNumber:    params.PhoneNumber,
+		Classification: Classification,
+		StartDate:      params.Start.UTC(),
+		ExpirationDate: params.End.UTC(),
+		Temporary:      true,
+		Metadata:       metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Visit{
+		CardID:         card.ID,
+		FullName:       params.FullName,
+		Email:          params.Email,
+		PhoneNumber:    params.PhoneNumber,
+		Company:        params.Company,
+		Purpose:        params.Purpose,
+		HostEmployeeID: params.HostEmployeeID,
+		HostName:       params.HostName,
+		Start:          params.Start.UTC(),
+		End:            params.End.UTC(),
+		State:          card.State,
+		InstallURL:     card.URL,
+	}, nil
+}
+
+// Visits returns every visitor pass of the template, ordered by start
+func (s *Service) Visits(ctx context.Context) ([]Visit, error) {
+	cards, err := s.cards.List(ctx, &models.ListKeysParams{TemplateID: s.config.CardTemplateID})
+	if err != nil {
+		return nil, err
+	}
+
+	var visits []Visit
+	for _, card := range cards {
+		if visit, ok := visitFromCard(card); ok {
+			visits = append(visits, visit)
+		}
+	}
+	sort.SliceStable(visits, func(i, j int) bool {
+		return visits[i].Start.Before(visits[j].Start)
+	})
+	return visits, nil
+}
+
+// Active returns the visitors on site now
+func (s *Service) Active(ctx context.Context) ([]Visit, error) {
+	visits, err := s.Visits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.config.Now()
+	var active []Visit
+	for _, visit := range visits {
+		if visit.OnSite(now) {
+			active = append(active, visit)
+		}
+	}
+	return active, nil
+}
+
+// Expire suspends or deletes the passes whose visit window has ended and
+// returns them. It carries on past failures and returns the first error.
+func (s *Service) Expire(ctx context.Context) ([]Visit, error) {
+	visits, err := s.Visits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.config.Now()
+	var expired []Visit
+	var firstErr error
+	for _, visit := range visits {
+		if now.Before(visit.End) || visit.State == "deleted" {
+			continue
+		}
+		if s.config.ExpiredAction == schedule.ActionSuspend && visit.State == "suspended" {
+			continue
+		}
+
+		if s.config.ExpiredAction == schedule.ActionDelete {
+			err = s.cards.Delete(ctx, visit.CardID)
+			visit.State = "deleted"
+		} else {
+			err = s.cards.Suspend(ctx, visit.CardID)
+			visit.State = "suspended"
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("visitor %s (%s): %w", visit.FullName, visit.CardID, err)
+			}
+			continue
+		}
+		expired = append(expired, visit)
+	}
+	return expired, firstErr
+}
+
+// Run expires passes on every interval until ctx is done. Errors are
+// passed to onError, which may be nil.
+func (s *Service) Run(ctx context.Context, interval time.Duration, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Expire(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// visitFromCard reads a visitor pass. Cards without the visitor flag are
+// not visits.
+func visitFromCard(card models.Card) (Visit, bool) {
+	switch flag := card.Metadata[MetadataVisitor].(type) {
+	case bool:
+		if !flag {
+			return Visit{}, false
+		}
+	case string:
+		if flag != "true" {
+			return Visit{}, false
+		}
+	default:
+		return Visit{}, false
+	}
+	text := func(key string) string {
+		s, _ := card.Metadata[key].(string)
+		return s
+	}
+	window := func(key string, fallback time.Time) time.Time {
+		if t, err := time.Parse(time.RFC3339, text(key)); err == nil {
+			return t.UTC()
+		}
+		return fallback.UTC()
+	}
+
+	return Visit{
+		CardID:         card.ID,
+		FullName:       card.FullName,
+		Email:          card.Email,
+		PhoneNumber:    card.PhoneNumber,
+		Company:        text(MetadataCompany),
+		Purpose:        text(MetadataPurpose),
+		HostEmployeeID: text(MetadataHostEmployeeID),
+		HostName:       text(MetadataHostName),
+		Start:          window(MetadataVisitStart, card.StartDate),
+		End:            window(MetadataVisitEnd, card.ExpirationDate),
+		State:          card.State,
+		InstallURL:     card.InstallURL,
+	}, true
+}
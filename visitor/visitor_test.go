@@ -0,0 +1,222 @@
+package visitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Access-Grid/accessgrid-go/client"
+	"github.com/Access-Grid/accessgrid-go/models"
+	"github.com/Access-Grid/accessgrid-go/schedule"
+	"github.com/Access-Grid/accessgrid-go/services"
+)
+
+var now = time.Date(2026, 6, 15, 14, 0, 0, 0, time.UTC)
+
+// visitServer serves visitor cards and their events
+type visitServer struct {
+	mu          sync.Mutex
+	cards       []models.Card
+	events      []map[string]interface{}
+	provisioned []models.ProvisionParams
+	requests    []string
+}
+
+func (s *visitServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/key-cards":
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.cards})
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/logs"):
+		json.NewEncoder(w).Encode(map[string]interface{}{"logs": s.events})
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/key-cards":
+		var params models.ProvisionParams
+		json.NewDecoder(r.Body).Decode(&params)
+		s.provisioned = append(s.provisioned, params)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "card_new", "state": "active", "install_url": "https://install.example/abc"})
+	default:
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{}`))
+	}
+}
+
+func visitorCard(id, name, state string, start, end time.Time) models.Card {
+	return models.Card{
+		ID: id, FullName: name, State: state, StartDate: start, ExpirationDate: end,
+		Metadata: map[string]interface{}{
+			MetadataVisitor:        true,
+			MetadataHostEmployeeID: "E100",
+			MetadataHostName:       "Ada Lovelace",
+			MetadataCompany:        "Acme",
+			MetadataVisitStart:     start.Format(time.RFC3339),
+			MetadataVisitEnd:       end.Format(time.RFC3339),
+		},
+	}
+}
+
+func newTestService(t *testing.T, server *visitServer, config Config) *Service {
+	t.Helper()
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	c, err := client.NewClient("test-account", "test-secret", client.WithBaseURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.CardTemplateID = "tmpl_visitors"
+	config.Now = func() time.Time { return now }
+	s, err := New(services.NewAccessCardsService(c), services.NewConsoleService(c), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestIssue(t *testing.T) {
+	server := &visitServer{}
+	s := newTestService(t, server, Config{})
+
+	visit, err := s.Issue(context.Background(), Params{
+		FullName:       "Grace Hopper",
+		Email:          "grace@navy.example",
+		Company:        "US Navy",
+		HostEmployeeID: "E100",
+		HostName:       "Ada Lovelace",
+		Start:          now.Add(time.Hour),
+		End:            now.Add(4 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visit.CardID != "card_new" || visit.InstallURL != "https://install.example/abc" || visit.HostEmployeeID != "E100" {
+		t.Errorf("visit = %+v", visit)
+	}
+
+	params := server.provisioned[0]
+	if !params.Temporary || params.Classification != Classification || params.CardTemplateID != "tmpl_visitors" {
+		t.Errorf("params = %+v", params)
+	}
+	if !params.StartDate.Equal(now.Add(time.Hour)) || !params.ExpirationDate.Equal(now.Add(4*time.Hour)) {
+		t.Errorf("window = %v - %v", params.StartDate, params.ExpirationDate)
+	}
+	for key, want := range map[string]interface{}{
+		MetadataVisitor:                  true,
+		MetadataHostEmployeeID:           "E100",
+		MetadataCompany:                  "US Navy",
+		schedule.MetadataActivateOnStart: true,
+	} {
+		if params.Metadata[key] != want {
+			t.Errorf("metadata %s = %v, want %v", key, params.Metadata[key], want)
+		}
+	}
+}
+
+func TestIssueValidates(t *testing.T) {
+	s := newTestService(t, &visitServer{}, Config{})
+	tests := []Params{
+		{HostEmployeeID: "E1", End: now.Add(time.Hour)},
+		{FullName: "No Host", End: now.Add(time.Hour)},
+		{FullName: "Backwards", HostEmployeeID: "E1", Start: now.Add(2 * time.Hour), End: now.Add(time.Hour)},
+		{FullName: "Past", HostEmployeeID: "E1", Start: now.Add(-3 * time.Hour), End: now.Add(-time.Hour)},
+		{FullName: "Too Long", HostEmployeeID: "E1", End: now.Add(48 * time.Hour)},
+	}
+	for _, params := range tests {
+		if _, err := s.Issue(context.Background(), params); err == nil {
+			t.Errorf("%+v: expected an error", params)
+		}
+	}
+}
+
+func testServer() *visitServer {
+	return &visitServer{
+		cards: []models.Card{
+			visitorCard("on_site", "On Site", "active", now.Add(-2*time.Hour), now.Add(2*time.Hour)),
+			visitorCard("left", "Left Early", "active", now.Add(-5*time.Hour), now.Add(-time.Hour)),
+			visitorCard("tomorrow", "Tomorrow", "active", now.Add(20*time.Hour), now.Add(24*time.Hour)),
+			visitorCard("yesterday", "Yesterday", "suspended", now.Add(-26*time.Hour), now.Add(-24*time.Hour)),
+			{ID: "employee", FullName: "Not A Visitor", State: "active", ExpirationDate: now.Add(-time.Hour)},
+		},
+		events: []map[string]interface{}{
+			{"id": 1, "event": "install", "card_id": "on_site", "timestamp": now.Add(-90 * time.Minute).Format(time.RFC3339)},
+			{"id": 2, "event": "provision", "card_id": "on_site", "timestamp": now.Add(-3 * time.Hour).Format(time.RFC3339)},
+			{"id": 3, "event": "install", "card_id": "yesterday", "timestamp": now.Add(-25 * time.Hour).Format(time.RFC3339)},
+		},
+	}
+}
+
+func TestActiveAndExpire(t *testing.T) {
+	server := testServer()
+	s := newTestService(t, server, Config{})
+
+	active, err := s.Active(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].CardID != "on_site" || active[0].HostName != "Ada Lovelace" {
+		t.Errorf("active = %+v", active)
+	}
+
+	expired, err := s.Expire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].CardID != "left" {
+		t.Errorf("expired = %+v", expired)
+	}
+	if len(server.requests) != 1 || server.requests[0] != "POST /v1/key-cards/left/suspend" {
+		t.Errorf("requests = %v", server.requests)
+	}
+}
+
+func TestExpireDeletes(t *testing.T) {
+	server := testServer()
+	s := newTestService(t, server, Config{ExpiredAction: schedule.ActionDelete})
+	if _, err := s.Expire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := "POST /v1/key-cards/yesterday/delete,POST /v1/key-cards/left/delete"
+	if got := strings.Join(server.requests, ","); got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+}
+
+func TestDailyLog(t *testing.T) {
+	s := newTestService(t, testServer(), Config{})
+	log, err := s.DailyLog(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, e := range log.Entries {
+		ids = append(ids, e.CardID)
+	}
+	if strings.Join(ids, ",") != "left,on_site" {
+		t.Fatalf("entries = %v, want left,on_site", ids)
+	}
+	onSite := log.Entries[1]
+	if onSite.Installed == nil || !onSite.Installed.Equal(now.Add(-90*time.Minute)) {
+		t.Errorf("installed = %v", onSite.Installed)
+	}
+	if len(onSite.Events) != 2 || onSite.Events[0].Type != models.EventTypeProvision {
+		t.Errorf("events = %+v", onSite.Events)
+	}
+
+	var out bytes.Buffer
+	if err := log.WriteCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "visitor,company,") {
+		t.Fatalf("csv:\n%s", out.String())
+	}
+	if want := "On Site,Acme,,E100,Ada Lovelace,,2026-06-15T12:00:00Z,2026-06-15T16:00:00Z,2026-06-15T12:30:00Z,active,on_site"; lines[2] != want {
+		t.Errorf("row = %s\nwant  %s", lines[2], want)
+	}
+}
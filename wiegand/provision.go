@@ -0,0 +1,51 @@
+package wiegand
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Access-Grid/accessgrid-go/budget"
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+var _ budget.Provisioner = (*Provisioner)(nil)
+
+// TemplateFormats maps card template IDs to the format their readers
+// expect
+type TemplateFormats map[string]*Format
+
+// Check validates parameters against the format of their template.
+// Templates without a format pass. It is a models.ProvisionCheck.
+func (t TemplateFormats) Check(p models.ProvisionParams) []string {
+	if f, ok := t[p.CardTemplateID]; ok {
+		return f.Check(p)
+	}
+	return nil
+}
+
+// Provisioner validates parameters against their template's format before
+// provisioning, so a pass is never issued with a number the readers cannot
+// represent
+type Provisioner struct {
+	next    budget.Provisioner
+	formats TemplateFormats
+}
+
+// NewProvisioner wraps next, such as *services.AccessCardsService or a
+// *cardnumber.Allocator, with format validation
+func NewProvisioner(next budget.Provisioner, formats TemplateFormats) *Provisioner {
+	return &Provisioner{next: next, formats: formats}
+}
+
+// Provision validates params and provisions the pass. Invalid parameters
+// return a *models.ValidationError without calling the API.
+func (p *Provisioner) Provision(ctx context.Context, params models.ProvisionParams) (*models.CardProvisionResponse, error) {
+	if p.next == nil {
+		return nil, errors.New("no Provisioner to wrap")
+	}
+	if err := params.Validate(p.formats.Check); err != nil {
+		return nil, fmt.Errorf("error provisioning card: %w", err)
+	}
+	return p.next.Provision(ctx, params)
+}
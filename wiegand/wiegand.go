@@ -0,0 +1,262 @@
+// Package wiegand encodes site codes and card numbers in Wiegand formats.
+//
+// A Format packs a facility code, which AccessGrid calls a site code, and a
+// card number into a credential of a fixed length with parity bits, as
+// physical access control panels read it. Formats check that values fit
+// before encoding, and Check plugs into ProvisionParams.Validate so a pass
+// is never provisioned with a number the readers cannot represent.
+package wiegand
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+// ErrParity is returned when decoding a credential whose parity bits do
+// not match
+var ErrParity = errors.New("parity check failed")
+
+// field is a run of bits, numbered from 0 at the first bit sent
+type field struct {
+	start, bits int
+}
+
+func (f field) max() uint64 {
+	return 1<<f.bits - 1
+}
+
+// parity is a parity bit over other bits of the credential
+type parity struct {
+	position int
+	odd      bool
+	covers   func(position int) bool
+}
+
+// Format is a Wiegand credential layout
+type Format struct {
+	// Name is the format's industry name, such as "H10301"
+	Name string
+	// Length is the number of bits, parity included
+	Length   int
+	facility field
+	card     field
+	// parity bits are computed in order, so a bit may cover earlier ones
+	parity []parity
+}
+
+// between returns a parity coverage of positions first to last
+func between(first, last int) func(int) bool {
+	return func(p int) bool { return p >= first && p <= last }
+}
+
+var (
+	// H10301 is the 26-bit standard format: an 8-bit facility code and a
+	// 16-bit card number
+	H10301 = &Format{
+		Name: "H10301", Length: 26,
+		facility: field{1, 8}, card: field{9, 16},
+		parity: []parity{
+			{0, false, between(1, 12)},
+			{25, true, between(13, 24)},
+		},
+	}
+
+	// H10306 is the 34-bit format with a 16-bit facility code and a 16-bit
+	// card number
+	H10306 = &Format{
+		Name: "H10306", Length: 34,
+		facility: field{1, 16}, card: field{17, 16},
+		parity: []parity{
+			{0, false, between(1, 16)},
+			{33, true, between(17, 32)},
+		},
+	}
+
+	// Corporate1000 is the 35-bit HID Corporate 1000 format: a 12-bit
+	// company ID and a 20-bit card number
+	Corporate1000 = &Format{
+		Name: "C1000", Length: 35,
+		facility: field{2, 12}, card: field{14, 20},
+		parity: []parity{
+			{1, false, func(p int) bool { return p >= 2 && p <= 33 && p%3 != 1 }},
+			{34, true, func(p int) bool { return p >= 1 && p <= 33 && p%3 != 0 }},
+			{0, true, between(1, 34)},
+		},
+	}
+
+	// H10302 is the 37-bit format with a 35-bit card number and no
+	// facility code
+	H10302 = &Format{
+		Name: "H10302", Length: 37,
+		card: field{1, 35},
+		parity: []parity{
+			{0, false, between(1, 18)},
+			{36, true, between(18, 35)},
+		},
+	}
+
+	// H10304 is the 37-bit format with a 16-bit facility code and a 19-bit
+	// card number
+	H10304 = &Format{
+		Name: "H10304", Length: 37,
+		facility: field{1, 16}, card: field{17, 19},
+		parity: []parity{
+			{0, false, between(1, 18)},
+			{36, true, between(18, 35)},
+		},
+	}
+)
+
+// Formats lists the supported formats
+var Formats = []*Format{H10301, H10306, Corporate1000, H10302, H10304}
+
+// Lookup returns the format with a name, ignoring case
+func Lookup(name string) (*Format, bool) {
+	for _, f := range Formats {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// String returns the name and length, such as "H10301 (26-bit)"
+func (f *Format) String() string {
+	return fmt.Sprintf("%s (%d-bit)", f.Name, f.Length)
+}
+
+// HasFacilityCode reports whether the format carries a facility code
+func (f *Format) HasFacilityCode() bool {
+	return f.facility.bits > 0
+}
+
+// MaxFacilityCode returns the largest facility code the format holds, or
+// 0 for formats without one
+func (f *Format) MaxFacilityCode() uint64 {
+	if !f.HasFacilityCode() {
+		return 0
+	}
+	return f.facility.max()
+}
+
+// MaxCardNumber returns the largest card number the format holds
+func (f *Format) MaxCardNumber() uint64 {
+	return f.card.max()
+}
+
+// Fits returns an error if the facility code or card number does not fit
+// the format
+func (f *Format) Fits(facility, card uint64) error {
+	if facility > f.MaxFacilityCode() {
+		if !f.HasFacilityCode() {
+			return fmt.Errorf("%s has no facility code, got %d", f, facility)
+		}
+		return fmt.Errorf("facility code %d does not fit %s, the maximum is %d", facility, f, f.MaxFacilityCode())
+	}
+	if card > f.MaxCardNumber() {
+		return fmt.Errorf("card number %d does not fit %s, the maximum is %d", card, f, f.MaxCardNumber())
+	}
+	return nil
+}
+
+// Encode packs a facility code and card number with their parity bits.
+// The first bit sent is the most significant bit of the result.
+func (f *Format) Encode(facility, card uint64) (uint64, error) {
+	if err := f.Fits(facility, card); err != nil {
+		return 0, err
+	}
+	var value uint64
+	if f.HasFacilityCode() {
+		value = f.set(value, f.facility, facility)
+	}
+	value = f.set(value, f.card, card)
+	for _, p := range f.parity {
+		if f.parityBit(value, p) {
+			value |= 1 << f.shift(p.position)
+		}
+	}
+	return value, nil
+}
+
+// Decode unpacks a credential, checking its length and parity bits
+func (f *Format) Decode(value uint64) (facility, card uint64, err error) {
+	if value>>f.Length != 0 {
+		return 0, 0, fmt.Errorf("credential %#x is longer than %s", value, f)
+	}
+	for _, p := range f.parity {
+		if f.parityBit(value, p) != (value>>f.shift(p.position)&1 == 1) {
+			return 0, 0, fmt.Errorf("%w: bit %d of %s", ErrParity, p.position, f)
+		}
+	}
+	if f.HasFacilityCode() {
+		facility = f.get(value, f.facility)
+	}
+	return facility, f.get(value, f.card), nil
+}
+
+// Binary formats a credential as its bits, first bit sent first
+func (f *Format) Binary(value uint64) string {
+	return fmt.Sprintf("%0*b", f.Length, value)
+}
+
+// Check validates the site code and card number of provisioning
+// parameters against the format. It is a models.ProvisionCheck:
+//
+//	err := params.Validate(wiegand.H10301.Check)
+func (f *Format) Check(p models.ProvisionParams) []string {
+	var problems []string
+	card, err := strconv.ParseUint(strings.TrimSpace(p.CardNumber), 10, 64)
+	switch {
+	case p.CardNumber == "":
+		problems = append(problems, fmt.Sprintf("card_number is required by %s", f))
+	case err != nil:
+		problems = append(problems, fmt.Sprintf("card_number %q is not a number", p.CardNumber))
+	case card > f.MaxCardNumber():
+		problems = append(problems, fmt.Sprintf("card_number %d does not fit %s, the maximum is %d", card, f, f.MaxCardNumber()))
+	}
+
+	facility, err := strconv.ParseUint(strings.TrimSpace(p.SiteCode), 10, 64)
+	switch {
+	case !f.HasFacilityCode():
+		if p.SiteCode != "" && (err != nil || facility != 0) {
+			problems = append(problems, fmt.Sprintf("%s has no site code, got %q", f, p.SiteCode))
+		}
+	case p.SiteCode == "":
+		problems = append(problems, fmt.Sprintf("site_code is required by %s", f))
+	case err != nil:
+		problems = append(problems, fmt.Sprintf("site_code %q is not a number", p.SiteCode))
+	case facility > f.MaxFacilityCode():
+		problems = append(problems, fmt.Sprintf("site_code %d does not fit %s, the maximum is %d", facility, f, f.MaxFacilityCode()))
+	}
+	return problems
+}
+
+// shift converts a bit position to its shift from the least significant
+// bit
+func (f *Format) shift(position int) int {
+	return f.Length - 1 - position
+}
+
+func (f *Format) set(value uint64, fl field, v uint64) uint64 {
+	return value | v<<f.shift(fl.start+fl.bits-1)
+}
+
+func (f *Format) get(value uint64, fl field) uint64 {
+	return value >> f.shift(fl.start+fl.bits-1) & fl.max()
+}
+
+// parityBit returns the value of a parity bit over the bits it covers,
+// ignoring the parity bit itself
+func (f *Format) parityBit(value uint64, p parity) bool {
+	ones := 0
+	for position := 0; position < f.Length; position++ {
+		if position != p.position && p.covers(position) && value>>f.shift(position)&1 == 1 {
+			ones++
+		}
+	}
+	return (ones%2 == 1) != p.odd
+}
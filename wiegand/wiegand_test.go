@@ -0,0 +1,143 @@
+package wiegand
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Access-Grid/accessgrid-go/models"
+)
+
+func TestEncodeKnownValues(t *testing.T) {
+	tests := []struct {
+		format         *Format
+		facility, card uint64
+		want           uint64
+	}{
+		{H10301, 0, 0, 0x1},
+		{H10301, 1, 1, 0x2020002},
+		{H10301, 255, 65535, 0x1ffffff},
+		{Corporate1000, 0, 0, 0x1},
+		{H10304, 1, 1, 0x1000100002},
+		{H10302, 0, 1, 0x2},
+	}
+	for _, tt := range tests {
+		got, err := tt.format.Encode(tt.facility, tt.card)
+		if err != nil {
+			t.Errorf("%s.Encode(%d, %d): %v", tt.format.Name, tt.facility, tt.card, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s.Encode(%d, %d) = %s, want %s", tt.format.Name, tt.facility, tt.card, tt.format.Binary(got), tt.format.Binary(tt.want))
+		}
+	}
+}
+
+func TestRoundTripAndParity(t *testing.T) {
+	for _, f := range Formats {
+		values := [][2]uint64{{0, 0}, {min(1, f.MaxFacilityCode()), 1}, {f.MaxFacilityCode(), f.MaxCardNumber()}, {f.MaxFacilityCode() / 3, f.MaxCardNumber() / 7}}
+		for _, v := range values {
+			encoded, err := f.Encode(v[0], v[1])
+			if err != nil {
+				t.Fatalf("%s.Encode(%d, %d): %v", f.Name, v[0], v[1], err)
+			}
+			if len(f.Binary(encoded)) != f.Length {
+				t.Errorf("%s: %s is not %d bits", f.Name, f.Binary(encoded), f.Length)
+			}
+			facility, card, err := f.Decode(encoded)
+			if err != nil || facility != v[0] || card != v[1] {
+				t.Errorf("%s.Decode(%s) = %d, %d, %v; want %d, %d", f.Name, f.Binary(encoded), facility, card, err, v[0], v[1])
+			}
+
+			// Flipping any single bit breaks a parity check
+			for bit := 0; bit < f.Length; bit++ {
+				if _, _, err := f.Decode(encoded ^ 1<<bit); !errors.Is(err, ErrParity) {
+					t.Errorf("%s: flipping bit %d of %s: err = %v, want ErrParity", f.Name, bit, f.Binary(encoded), err)
+				}
+			}
+		}
+	}
+}
+
+func TestFits(t *testing.T) {
+	if _, err := H10301.Encode(256, 1); err == nil {
+		t.Error("facility code 256 should not fit H10301")
+	}
+	if _, err := H10301.Encode(1, 65536); err == nil {
+		t.Error("card number 65536 should not fit H10301")
+	}
+	if _, err := H10302.Encode(1, 1); err == nil {
+		t.Error("H10302 has no facility code")
+	}
+	if _, _, err := H10301.Decode(1 << 26); err == nil {
+		t.Error("a 27-bit value should not decode as H10301")
+	}
+	if Corporate1000.MaxFacilityCode() != 4095 || Corporate1000.MaxCardNumber() != 1048575 {
+		t.Errorf("C1000 limits = %d, %d", Corporate1000.MaxFacilityCode(), Corporate1000.MaxCardNumber())
+	}
+	if f, ok := Lookup("h10304"); !ok || f != H10304 {
+		t.Errorf("Lookup(h10304) = %v, %v", f, ok)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	params := models.ProvisionParams{CardTemplateID: "0xd3adb00b5", SiteCode: "42", CardNumber: "12345"}
+	if err := params.Validate(H10301.Check); err != nil {
+		t.Errorf("valid params: %v", err)
+	}
+
+	tests := []struct {
+		format     *Format
+		site, card string
+		want       string
+	}{
+		{H10301, "42", "", "card_number is required"},
+		{H10301, "", "1", "site_code is required"},
+		{H10301, "256", "1", "site_code 256 does not fit H10301 (26-bit)"},
+		{H10301, "1", "70000", "card_number 70000 does not fit"},
+		{H10301, "A1", "1", `site_code "A1" is not a number`},
+		{H10302, "7", "1", "has no site code"},
+	}
+	for _, tt := range tests {
+		params := models.ProvisionParams{CardTemplateID: "0xd3adb00b5", SiteCode: tt.site, CardNumber: tt.card}
+		err := params.Validate(tt.format.Check)
+		var validationErr *models.ValidationError
+		if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %s/%s: err = %v, want %q", tt.format.Name, tt.site, tt.card, err, tt.want)
+		}
+	}
+}
+
+// recorder is a Provisioner that records its calls
+type recorder struct {
+	calls int
+}
+
+func (r *recorder) Provision(ctx context.Context, params models.ProvisionParams) (*models.CardProvisionResponse, error) {
+	r.calls++
+	return &models.CardProvisionResponse{ID: "0xc4rd1d"}, nil
+}
+
+func TestProvisioner(t *testing.T) {
+	next := &recorder{}
+	p := NewProvisioner(next, TemplateFormats{"tmpl_26": H10301})
+	ctx := context.Background()
+
+	if _, err := p.Provision(ctx, models.ProvisionParams{CardTemplateID: "tmpl_26", SiteCode: "1", CardNumber: "99999"}); err == nil {
+		t.Error("expected a validation error")
+	}
+	if next.calls != 0 {
+		t.Fatal("an invalid pass was provisioned")
+	}
+	if _, err := p.Provision(ctx, models.ProvisionParams{CardTemplateID: "tmpl_26", SiteCode: "1", CardNumber: "9999"}); err != nil {
+		t.Fatal(err)
+	}
+	// Templates without a format are not checked
+	if _, err := p.Provision(ctx, models.ProvisionParams{CardTemplateID: "tmpl_other"}); err != nil {
+		t.Fatal(err)
+	}
+	if next.calls != 2 {
+		t.Errorf("calls = %d, want 2", next.calls)
+	}
+}